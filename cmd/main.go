@@ -1,9 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
 	"hot-coffee/internal/server"
 	"hot-coffee/pkg/logger"
@@ -22,17 +30,54 @@ import (
 // ? TODO: Validate the path to the cfg correctly. Write in the utils package validateCfg.go (OPTIONAL)
 
 var (
-	configPath string
-	port       string
-	dir        string
+	configPath  string
+	port        string
+	dir         string
+	maxBodySize int64
+	logFormat   string
+	logLevel    string
+	logFile     string
+	logMaxSize  int64
+	logBackups  int
+	corsOrigins string
+	apiKey      string
+	rateLimit   float64
+	rateBurst   int
+	taxRate     float64
+
+	publicMetrics bool
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
 )
 
+// defaultDataDir is used when neither the flag nor the DATA_DIR variable is set
+const defaultDataDir = "./data"
+
+// shutdownTimeout is the time given to in-flight requests to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func init() {
 	flag.StringVar(&port, "port", "8080", "Port number")
-	flag.StringVar(&dir, "dir", "./data", "Path to the directory")
+	flag.StringVar(&dir, "dir", defaultDataDir, "Path to the directory")
+	flag.StringVar(&dir, "data-dir", defaultDataDir, "Path to the data directory (alias of --dir, falls back to $DATA_DIR)")
 	flag.StringVar(&configPath, "cfg", "configs/server.yaml", "Path to the config file")
-
-	logger.InitLogger(true, true)
+	flag.Int64Var(&maxBodySize, "max-body", 1<<20, "Maximum request body size in bytes")
+	flag.StringVar(&logFormat, "log-format", logger.FormatText, "Log output format: text or json")
+	flag.StringVar(&logLevel, "log-level", "info", "Minimum level of the logged messages: error, warn, info or debug")
+	flag.StringVar(&logFile, "log-file", "", "Path of a file the logs are also written to (empty disables file logging)")
+	flag.Int64Var(&logMaxSize, "log-max-size", 10, "Size in megabytes at which the log file is rotated")
+	flag.IntVar(&logBackups, "log-max-backups", 5, "Number of rotated log files to keep")
+	flag.StringVar(&corsOrigins, "cors-origins", "", "Comma separated list of allowed CORS origins")
+	flag.Float64Var(&rateLimit, "rate-limit", 10, "Requests per second allowed for each client IP (0 disables rate limiting)")
+	flag.IntVar(&rateBurst, "rate-burst", 20, "Maximum burst of requests for each client IP")
+	flag.StringVar(&apiKey, "api-key", "", "API key required in the X-API-Key header (falls back to $API_KEY, empty disables auth)")
+	flag.BoolVar(&publicMetrics, "public-metrics", false, "Serve GET /metrics without the API key")
+	flag.Float64Var(&taxRate, "tax-rate", 0, "Tax rate in percent applied to order subtotals (falls back to $TAX_RATE)")
+	flag.DurationVar(&readTimeout, "read-timeout", 10*time.Second, "Maximum time to read a request, including its body (0 disables)")
+	flag.DurationVar(&writeTimeout, "write-timeout", 30*time.Second, "Maximum time to write a response (0 disables)")
+	flag.DurationVar(&idleTimeout, "idle-timeout", 120*time.Second, "Maximum time an idle keep-alive connection is kept open (0 disables)")
 
 	flag.Usage = CustomUsage
 }
@@ -47,25 +92,139 @@ func validate() error {
 	if err != nil {
 		return err
 	}
+	dir = filepath.Clean(dir)
+
+	err = ValidateDirWritable(dir)
+	if err != nil {
+		return err
+	}
+
+	if taxRate < 0 || taxRate > 100 || math.IsNaN(taxRate) {
+		return fmt.Errorf("invalid tax rate: %g must be between 0 and 100", taxRate)
+	}
+
+	if logFormat != logger.FormatText && logFormat != logger.FormatJSON {
+		return fmt.Errorf("invalid log format: '%s' must be '%s' or '%s'", logFormat, logger.FormatText, logger.FormatJSON)
+	}
+
+	if _, err := logger.ParseLevel(logLevel); err != nil {
+		return err
+	}
+
+	if readTimeout < 0 || writeTimeout < 0 || idleTimeout < 0 {
+		return fmt.Errorf("invalid timeout: read %s, write %s and idle %s must not be negative", readTimeout, writeTimeout, idleTimeout)
+	}
+
+	if logMaxSize <= 0 {
+		return fmt.Errorf("invalid log file size: %d must be a positive number of megabytes", logMaxSize)
+	}
+	if logBackups < 0 {
+		return fmt.Errorf("invalid number of log backups: %d must not be negative", logBackups)
+	}
+	return nil
+}
+
+// isFlagSet reports whether any of the named flags was set on the command line.
+func isFlagSet(names ...string) bool {
+	isSet := false
+	flag.Visit(func(f *flag.Flag) {
+		for _, name := range names {
+			if f.Name == name {
+				isSet = true
+			}
+		}
+	})
+	return isSet
+}
+
+// resolveEnv falls back to environment variables for the settings
+// that were not set explicitly with a flag.
+// Returns an error if a variable can not be parsed.
+func resolveEnv() error {
+	if envDir := os.Getenv("DATA_DIR"); !isFlagSet("dir", "data-dir") && envDir != "" {
+		dir = envDir
+	}
+
+	if envKey := os.Getenv("API_KEY"); !isFlagSet("api-key") && envKey != "" {
+		apiKey = envKey
+	}
+
+	if envRate := os.Getenv("TAX_RATE"); !isFlagSet("tax-rate") && envRate != "" {
+		rate, err := strconv.ParseFloat(envRate, 64)
+		if err != nil {
+			return fmt.Errorf("invalid TAX_RATE: '%s' is not a valid number", envRate)
+		}
+		taxRate = rate
+	}
 	return nil
 }
 
 func main() {
 	flag.Parse()
 
-	err := validate()
+	err := resolveEnv()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = validate()
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 	port = ":" + port
 
+	// The level is checked by validate
+	level, _ := logger.ParseLevel(logLevel)
+
+	// Logs go to the console and, if configured, to a rotating file as well
+	logOutputs := []io.Writer{}
+	if logFile != "" {
+		file, err := logger.NewRotatingFile(logFile, logMaxSize<<20, logBackups)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		logOutputs = append(logOutputs, file)
+	}
+	logger.InitLogger(level, logFormat, logOutputs...)
+
 	cfg := server.NewConfig(configPath, port, dir)
+	cfg.SetMaxBodySize(maxBodySize)
+	cfg.SetCORSOrigins(corsOrigins)
+	cfg.SetAPIKey(apiKey)
+	cfg.SetRateLimit(rateLimit, rateBurst)
+	cfg.SetTaxRate(taxRate)
+	cfg.SetPublicMetrics(publicMetrics)
+	cfg.SetTimeouts(readTimeout, writeTimeout, idleTimeout)
 
 	apiServer := server.New(cfg, logger.LOGGER)
-	err = apiServer.Start()
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- apiServer.Start()
+	}()
+
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err = <-serverErr:
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	case sig := <-signalChannel:
+		logger.LOGGER.PrintInfoMsg("Received signal: %s. Shutting down...", sig)
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err = apiServer.Shutdown(ctx); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 	}
 }
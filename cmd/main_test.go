@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hot-coffee/pkg/logger"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     func()
+		wantErr bool
+	}{
+		{name: "defaults", set: func() {}},
+		{name: "port below 1024", set: func() { port = "80" }, wantErr: true},
+		{name: "port not a number", set: func() { port = "http" }, wantErr: true},
+		{name: "empty data directory", set: func() { dir = " " }, wantErr: true},
+		{name: "tax rate above 100", set: func() { taxRate = 101 }, wantErr: true},
+		{name: "tax rate not a number", set: func() { taxRate = math.NaN() }, wantErr: true},
+		{name: "unknown log format", set: func() { logFormat = "xml" }, wantErr: true},
+		{name: "unknown log level", set: func() { logLevel = "trace" }, wantErr: true},
+		{name: "negative timeout", set: func() { idleTimeout = -time.Second }, wantErr: true},
+		{name: "disabled timeouts", set: func() { readTimeout, writeTimeout, idleTimeout = 0, 0, 0 }},
+		{name: "zero log file size", set: func() { logMaxSize = 0 }, wantErr: true},
+		{name: "negative log backups", set: func() { logBackups = -1 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port, dir, taxRate = "8080", filepath.Join(t.TempDir(), "data"), 0
+			logFormat, logLevel, logMaxSize, logBackups = logger.FormatText, "info", 10, 5
+			readTimeout, writeTimeout, idleTimeout = 10*time.Second, 30*time.Second, 120*time.Second
+			tt.set()
+
+			if err := validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package dal
+
+import (
+	"slices"
+	"sync"
+
+	"hot-coffee/models"
+)
+
+// AdjustmentRepository is an append-only log of inventory adjustments.
+type AdjustmentRepository interface {
+	AddAdjustment(a models.InventoryAdjustment) error
+	GetAllAdjustments() ([]models.InventoryAdjustment, error)
+	GetAdjustmentsByItemId(id string) ([]models.InventoryAdjustment, error)
+}
+
+type adjustmentRepository struct {
+	storage Storage
+	key     string
+	cache   *cache[[]models.InventoryAdjustment]
+
+	// writeMu serializes the appends, so concurrent adjustments are all kept
+	writeMu sync.Mutex
+}
+
+// NewAdjustmentRepository creates a repository storing its data in the JSON file at filePath.
+func NewAdjustmentRepository(filePath string) *adjustmentRepository {
+	return NewAdjustmentRepositoryWithStorage(NewFileStorage(), filePath)
+}
+
+// NewAdjustmentRepositoryWithStorage creates a repository storing its data under the key in the given storage.
+func NewAdjustmentRepositoryWithStorage(storage Storage, key string) *adjustmentRepository {
+	return &adjustmentRepository{storage: storage, key: key, cache: newCache(slices.Clone[[]models.InventoryAdjustment])}
+}
+
+// AddAdjustment appends an adjustment to the log. Existing records are never changed.
+func (r *adjustmentRepository) AddAdjustment(a models.InventoryAdjustment) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	adjustments, err := r.GetAllAdjustments()
+	if err != nil {
+		return err
+	}
+
+	adjustments = append(adjustments, a)
+
+	if err := writeJSON(r.storage, r.key, adjustments); err != nil {
+		// The stored data is unknown after a failed write
+		r.cache.invalidate()
+		return err
+	}
+
+	r.cache.set(adjustments)
+	return nil
+}
+
+// GetAllAdjustments returns every adjustment in the order they were recorded.
+func (r *adjustmentRepository) GetAllAdjustments() ([]models.InventoryAdjustment, error) {
+	adjustments, err := r.cache.get(func() ([]models.InventoryAdjustment, error) {
+		adjustments := []models.InventoryAdjustment{}
+		err := readJSON(r.storage, r.key, &adjustments)
+		return adjustments, err
+	})
+	if err != nil {
+		return []models.InventoryAdjustment{}, err
+	}
+
+	return adjustments, nil
+}
+
+// InvalidateCache makes the next read load the adjustments from storage again.
+func (r *adjustmentRepository) InvalidateCache() {
+	r.cache.invalidate()
+}
+
+// LockWrites blocks the writes of the repository until UnlockWrites is called.
+func (r *adjustmentRepository) LockWrites() {
+	r.writeMu.Lock()
+}
+
+// UnlockWrites lets the writes blocked by LockWrites proceed.
+func (r *adjustmentRepository) UnlockWrites() {
+	r.writeMu.Unlock()
+}
+
+// GetAdjustmentsByItemId returns the adjustments of a single inventory item in the order they were recorded.
+func (r *adjustmentRepository) GetAdjustmentsByItemId(id string) ([]models.InventoryAdjustment, error) {
+	adjustments, err := r.GetAllAdjustments()
+	if err != nil {
+		return []models.InventoryAdjustment{}, err
+	}
+
+	itemAdjustments := []models.InventoryAdjustment{}
+	for _, adjustment := range adjustments {
+		if adjustment.IngredientID == id {
+			itemAdjustments = append(itemAdjustments, adjustment)
+		}
+	}
+
+	return itemAdjustments, nil
+}
@@ -0,0 +1,142 @@
+package dal
+
+import (
+	"slices"
+	"sync"
+
+	"hot-coffee/models"
+)
+
+// CacheInvalidator is implemented by the repositories that keep their data in memory
+// between requests. InvalidateCache makes the next read load the data from storage again,
+// e.g. after a data file was edited by hand.
+type CacheInvalidator interface {
+	InvalidateCache()
+}
+
+// WriteLocker is implemented by the repositories that serialize their writes.
+// LockWrites blocks the writes of the repository until UnlockWrites is called,
+// e.g. while its data file is replaced.
+type WriteLocker interface {
+	LockWrites()
+	UnlockWrites()
+}
+
+// cache keeps the parsed data of a repository in memory.
+// Values are cloned on the way in and out, so callers can modify what they read
+// without changing the cached data before it is saved.
+type cache[T any] struct {
+	mu     sync.Mutex
+	loaded bool
+	value  T
+	clone  func(T) T
+}
+
+func newCache[T any](clone func(T) T) *cache[T] {
+	return &cache[T]{clone: clone}
+}
+
+// get returns the cached value, calling load to populate the cache on the first read.
+func (c *cache[T]) get(load func() (T, error)) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.loaded {
+		value, err := load()
+		if err != nil {
+			return value, err
+		}
+		c.value, c.loaded = value, true
+	}
+
+	return c.clone(c.value), nil
+}
+
+// set replaces the cached value after a successful write.
+func (c *cache[T]) set(value T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.value, c.loaded = c.clone(value), true
+}
+
+// invalidate drops the cached value, the next read loads it from storage.
+func (c *cache[T]) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero T
+	c.value, c.loaded = zero, false
+}
+
+func cloneValue[T any](value T) T {
+	return value
+}
+
+func cloneMenuItems(items []models.MenuItem) []models.MenuItem {
+	cloned := slices.Clone(items)
+	for i := range cloned {
+		cloned[i].Ingredients = slices.Clone(cloned[i].Ingredients)
+		cloned[i].Sizes = slices.Clone(cloned[i].Sizes)
+		cloned[i].Components = slices.Clone(cloned[i].Components)
+		for j := range cloned[i].Sizes {
+			cloned[i].Sizes[j].Ingredients = slices.Clone(cloned[i].Sizes[j].Ingredients)
+		}
+	}
+	return cloned
+}
+
+func cloneOrders(orders []models.Order) []models.Order {
+	cloned := slices.Clone(orders)
+	for i := range cloned {
+		cloned[i].Items = slices.Clone(cloned[i].Items)
+		for j := range cloned[i].Items {
+			cloned[i].Items[j].RecipeSnapshot = slices.Clone(cloned[i].Items[j].RecipeSnapshot)
+		}
+		cloned[i].Tags = slices.Clone(cloned[i].Tags)
+		if discount := cloned[i].Discount; discount != nil {
+			discountCopy := *discount
+			cloned[i].Discount = &discountCopy
+		}
+		if taxRate := cloned[i].TaxRate; taxRate != nil {
+			taxRateCopy := *taxRate
+			cloned[i].TaxRate = &taxRateCopy
+		}
+		if seconds := cloned[i].FulfillmentSeconds; seconds != nil {
+			secondsCopy := *seconds
+			cloned[i].FulfillmentSeconds = &secondsCopy
+		}
+	}
+	return cloned
+}
+
+func cloneInventoryItems(items []models.InventoryItem) []models.InventoryItem {
+	cloned := slices.Clone(items)
+	for i := range cloned {
+		if cost := cloned[i].CostPerUnit; cost != nil {
+			costCopy := *cost
+			cloned[i].CostPerUnit = &costCopy
+		}
+	}
+	return cloned
+}
+
+func cloneReservations(reservations []models.InventoryReservation) []models.InventoryReservation {
+	cloned := slices.Clone(reservations)
+	for i := range cloned {
+		cloned[i].Ingredients = slices.Clone(cloned[i].Ingredients)
+	}
+	return cloned
+}
+
+func cloneAuditEntries(entries []models.OrderAuditEntry) []models.OrderAuditEntry {
+	cloned := slices.Clone(entries)
+	for i := range cloned {
+		cloned[i].Changes = slices.Clone(cloned[i].Changes)
+		for j := range cloned[i].Changes {
+			cloned[i].Changes[j].Old = slices.Clone(cloned[i].Changes[j].Old)
+			cloned[i].Changes[j].New = slices.Clone(cloned[i].Changes[j].New)
+		}
+	}
+	return cloned
+}
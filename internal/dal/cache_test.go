@@ -0,0 +1,203 @@
+package dal
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestCloneOrders(t *testing.T) {
+	newOrder := func() models.Order {
+		seconds, taxRate := int64(60), 10.0
+		return models.Order{
+			ID:     "orders1",
+			Status: models.StatusClosed,
+			Items: []models.OrderItem{{
+				ProductID:      "latte",
+				Quantity:       1,
+				RecipeSnapshot: []models.MenuItemIngredient{{IngredientID: "milk", Quantity: 200}},
+			}},
+			Tags:               []string{"vip"},
+			Discount:           &models.Discount{Type: models.DiscountFixed, Value: 1},
+			TaxRate:            &taxRate,
+			FulfillmentSeconds: &seconds,
+		}
+	}
+
+	// Each mutation of the clone changes the original too unless the value was deep-copied
+	tests := []struct {
+		name   string
+		mutate func(o *models.Order)
+		check  func(o models.Order) bool
+	}{
+		{
+			name:   "items",
+			mutate: func(o *models.Order) { o.Items[0].Quantity = 5 },
+			check:  func(o models.Order) bool { return o.Items[0].Quantity == 1 },
+		},
+		{
+			name:   "recipe snapshot",
+			mutate: func(o *models.Order) { o.Items[0].RecipeSnapshot[0].Quantity = 0 },
+			check:  func(o models.Order) bool { return o.Items[0].RecipeSnapshot[0].Quantity == 200 },
+		},
+		{
+			name:   "tags",
+			mutate: func(o *models.Order) { o.Tags[0] = "changed" },
+			check:  func(o models.Order) bool { return o.Tags[0] == "vip" },
+		},
+		{
+			name:   "discount",
+			mutate: func(o *models.Order) { o.Discount.Value = 100 },
+			check:  func(o models.Order) bool { return o.Discount.Value == 1 },
+		},
+		{
+			name:   "tax rate",
+			mutate: func(o *models.Order) { *o.TaxRate = 0 },
+			check:  func(o models.Order) bool { return *o.TaxRate == 10 },
+		},
+		{
+			name:   "fulfillment seconds",
+			mutate: func(o *models.Order) { *o.FulfillmentSeconds = 0 },
+			check:  func(o models.Order) bool { return *o.FulfillmentSeconds == 60 },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := newOrder()
+
+			cloned := cloneOrders([]models.Order{original})
+			tt.mutate(&cloned[0])
+
+			if !tt.check(original) {
+				t.Errorf("changing the %s of the clone changed the original order", tt.name)
+			}
+		})
+	}
+}
+
+// benchmarkOrders returns n closed orders of a few items each, about the size of a busy month.
+func benchmarkOrders(n int) []models.Order {
+	orders := make([]models.Order, n)
+	for i := range orders {
+		orders[i] = models.Order{
+			ID:           fmt.Sprintf("orders%d", i+1),
+			CustomerName: "Customer",
+			Items: []models.OrderItem{
+				{ProductID: "latte", Quantity: 2, Size: "large"},
+				{ProductID: "muffin", Quantity: 1, Instructions: "warm"},
+			},
+			Status:    models.StatusClosed,
+			CreatedAt: "2026-10-14T08:00:00Z",
+			ClosedAt:  "2026-10-14T08:05:00Z",
+			Tags:      []string{"takeaway"},
+		}
+	}
+	return orders
+}
+
+// BenchmarkGetAllOrders compares reading 5000 orders from the data file on every call, as before the cache,
+// with reading them from the cache.
+func BenchmarkGetAllOrders(b *testing.B) {
+	r := NewOrderRepository(filepath.Join(b.TempDir(), "orders.json"))
+	if err := r.SaveOrders(benchmarkOrders(5000)); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.InvalidateCache()
+			if _, err := r.GetAllOrders(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := r.GetAllOrders(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestCachedValuesAreIsolated(t *testing.T) {
+	// Each row changes a value returned by a repository in place, then reads it again
+	tests := []struct {
+		name   string
+		mutate func(t *testing.T) (changed bool)
+	}{
+		{
+			name: "inventory cost per unit",
+			mutate: func(t *testing.T) bool {
+				cost := 0.5
+				r := NewInMemoryInventoryRepository(models.InventoryItem{IngredientID: "milk", Quantity: 1000, Unit: "ml", CostPerUnit: &cost})
+				items, err := r.GetAllItems()
+				if err != nil {
+					t.Fatal(err)
+				}
+				*items[0].CostPerUnit = 9
+
+				item, err := r.GetItemById("milk")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return *item.CostPerUnit != 0.5
+			},
+		},
+		{
+			name: "reserved ingredients",
+			mutate: func(t *testing.T) bool {
+				r := NewInMemoryReservationRepository(models.InventoryReservation{
+					OrderID: "orders1", Ingredients: []models.ReservedIngredient{{IngredientID: "milk", Quantity: 200}},
+				})
+				reservations, err := r.GetAllReservations()
+				if err != nil {
+					t.Fatal(err)
+				}
+				reservations[0].Ingredients[0].Quantity = 0
+
+				reservations, err = r.GetAllReservations()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return reservations[0].Ingredients[0].Quantity != 200
+			},
+		},
+		{
+			name: "audit changes",
+			mutate: func(t *testing.T) bool {
+				r := NewInMemoryOrderAuditRepository()
+				if err := r.AddEntry(models.OrderAuditEntry{
+					OrderID: "orders1", Action: models.AuditUpdated,
+					Changes: []models.OrderFieldChange{{Field: "notes", Old: []byte(`"a"`), New: []byte(`"b"`)}},
+				}); err != nil {
+					t.Fatal(err)
+				}
+				entries, err := r.GetAllEntries()
+				if err != nil {
+					t.Fatal(err)
+				}
+				entries[0].Changes[0].Field = "status"
+				entries[0].Changes[0].New[1] = 'x'
+
+				entries, err = r.GetAllEntries()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return entries[0].Changes[0].Field != "notes" || string(entries[0].Changes[0].New) != `"b"`
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mutate(t) {
+				t.Errorf("changing the returned %s changed the cached value", tt.name)
+			}
+		})
+	}
+}
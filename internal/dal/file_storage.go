@@ -0,0 +1,54 @@
+package dal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"hot-coffee/internal/utils"
+)
+
+// fileStorage is the default Storage, keys are the paths of the data files.
+type fileStorage struct{}
+
+func NewFileStorage() *fileStorage {
+	return &fileStorage{}
+}
+
+// Read returns the content of the file at the key path, or nil if the file does not exist.
+// Failed reads are retried by readJSON.
+func (s *fileStorage) Read(key string) ([]byte, error) {
+	exists, err := utils.FileExists(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	return os.ReadFile(key)
+}
+
+// ModTime returns the modification time of the file at the key path, or the zero time if the file does not exist.
+func (s *fileStorage) ModTime(key string) (time.Time, error) {
+	info, err := os.Stat(key)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// Write replaces the content of the file at the key path.
+// Creates the directory of the file if it does not exist.
+func (s *fileStorage) Write(key string, data []byte) error {
+	dir := filepath.Dir(key)
+	if err := utils.CreateDir(dir); err != nil {
+		return fmt.Errorf("failed to create directory for file %s: %w", dir, err)
+	}
+
+	return os.WriteFile(key, data, 0o644)
+}
@@ -0,0 +1,95 @@
+package dal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestMissingDataFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		count func(path string) (int, bool, error)
+	}{
+		{
+			name: "inventory",
+			count: func(path string) (int, bool, error) {
+				items, err := NewInventoryRepository(path).GetAllItems()
+				return len(items), items == nil, err
+			},
+		},
+		{
+			name: "menu",
+			count: func(path string) (int, bool, error) {
+				items, err := NewMenuRepository(path).GetAllMenuItems()
+				return len(items), items == nil, err
+			},
+		},
+		{
+			name: "orders",
+			count: func(path string) (int, bool, error) {
+				orders, err := NewOrderRepository(path).GetAllOrders()
+				return len(orders), orders == nil, err
+			},
+		},
+		{
+			name: "adjustments",
+			count: func(path string) (int, bool, error) {
+				adjustments, err := NewAdjustmentRepository(path).GetAllAdjustments()
+				return len(adjustments), adjustments == nil, err
+			},
+		},
+		{
+			name: "order history",
+			count: func(path string) (int, bool, error) {
+				entries, err := NewOrderAuditRepository(path).GetAllEntries()
+				return len(entries), entries == nil, err
+			},
+		},
+		{
+			name: "reservations",
+			count: func(path string) (int, bool, error) {
+				reservations, err := NewReservationRepository(path).GetAllReservations()
+				return len(reservations), reservations == nil, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data", "missing.json")
+
+			n, isNil, err := tt.count(path)
+			if err != nil {
+				t.Fatalf("reading a missing file: %v", err)
+			}
+			if n != 0 || isNil {
+				t.Errorf("read %d entries, nil = %v, want an empty slice", n, isNil)
+			}
+
+			// The file is only created by the first write
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("reading created %s", path)
+			}
+		})
+	}
+}
+
+func TestFirstWriteCreatesDataFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "inventory.json")
+	r := NewInventoryRepository(path)
+
+	if err := r.SaveItems([]models.InventoryItem{{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml"}}); err != nil {
+		t.Fatalf("SaveItems() error = %v", err)
+	}
+
+	items, err := NewInventoryRepository(path).GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].IngredientID != "milk" {
+		t.Errorf("GetAllItems() = %+v, want the saved milk", items)
+	}
+}
@@ -1,13 +1,11 @@
 package dal
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
-	"hot-coffee/internal/utils"
 	"hot-coffee/models"
 )
 
@@ -16,18 +14,31 @@ type InventoryRepository interface {
 	GetAllItems() ([]models.InventoryItem, error)
 	GetItemById(id string) (models.InventoryItem, error)
 	SaveItems(inventoryItems []models.InventoryItem) error
+	UpdateItems(update func(items []models.InventoryItem) ([]models.InventoryItem, error)) error
 	ItemExists(i models.InventoryItem) (bool, error)
 	// ItemExistsById(id string) (bool, error)
 	RewriteItem(id string, newItem models.InventoryItem) error
 	DeleteItemByID(id string) error
+	LastModified() (time.Time, error)
 }
 
 type inventoryRepository struct {
-	filePath string
+	storage Storage
+	key     string
+	cache   *cache[[]models.InventoryItem]
+
+	// writeMu serializes the writes, so a read-modify-write never overwrites a concurrent change
+	writeMu sync.Mutex
 }
 
+// NewInventoryRepository creates a repository storing its data in the JSON file at filePath.
 func NewInventoryRepository(filePath string) *inventoryRepository {
-	return &inventoryRepository{filePath: filePath}
+	return NewInventoryRepositoryWithStorage(NewFileStorage(), filePath)
+}
+
+// NewInventoryRepositoryWithStorage creates a repository storing its data under the key in the given storage.
+func NewInventoryRepositoryWithStorage(storage Storage, key string) *inventoryRepository {
+	return &inventoryRepository{storage: storage, key: key, cache: newCache(cloneInventoryItems)}
 }
 
 // AddItem adds a new inventory item to the repository.
@@ -35,6 +46,9 @@ func NewInventoryRepository(filePath string) *inventoryRepository {
 // The following errors may be returned:
 // - An error if there is a failure in retrieving or saving the items.
 func (r *inventoryRepository) AddItem(i models.InventoryItem) (models.InventoryItem, error) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
 	items, err := r.GetAllItems()
 	if err != nil {
 		return models.InventoryItem{}, err
@@ -42,7 +56,7 @@ func (r *inventoryRepository) AddItem(i models.InventoryItem) (models.InventoryI
 
 	items = append(items, i)
 
-	err = r.SaveItems(items)
+	err = r.save(items)
 	if err != nil {
 		return models.InventoryItem{}, err
 	}
@@ -55,28 +69,11 @@ func (r *inventoryRepository) AddItem(i models.InventoryItem) (models.InventoryI
 // The following errors may be returned:
 // - An error if there is a failure in checking file existence or reading the file.
 func (r *inventoryRepository) GetAllItems() ([]models.InventoryItem, error) {
-	inventoryItems := []models.InventoryItem{}
-
-	exists, err := utils.FileExists(r.filePath)
-	if err != nil {
-		return []models.InventoryItem{}, err
-	}
-	if !exists {
-		return []models.InventoryItem{}, nil
-	}
-
-	file, err := os.Open(r.filePath)
-	if err != nil {
-		return []models.InventoryItem{}, err
-	}
-	defer file.Close()
-
-	if utils.FileEmpty(file) {
-		return []models.InventoryItem{}, nil
-	}
-
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&inventoryItems)
+	inventoryItems, err := r.cache.get(func() ([]models.InventoryItem, error) {
+		inventoryItems := []models.InventoryItem{}
+		err := readJSON(r.storage, r.key, &inventoryItems)
+		return inventoryItems, err
+	})
 	if err != nil {
 		return []models.InventoryItem{}, err
 	}
@@ -104,6 +101,9 @@ func (r *inventoryRepository) GetItemById(id string) (models.InventoryItem, erro
 // RewriteItem updates an existing inventory item identified by its ID.
 // Returns an error if updating the repository fails.
 func (r *inventoryRepository) RewriteItem(id string, newItem models.InventoryItem) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
 	items, err := r.GetAllItems()
 	if err != nil {
 		return err
@@ -116,7 +116,7 @@ func (r *inventoryRepository) RewriteItem(id string, newItem models.InventoryIte
 		}
 	}
 
-	err = r.SaveItems(items)
+	err = r.save(items)
 	if err != nil {
 		return err
 	}
@@ -130,38 +130,59 @@ func (r *inventoryRepository) RewriteItem(id string, newItem models.InventoryIte
 // - An error if creating the directory or file fails.
 // - An error if writing to the file fails.
 func (r *inventoryRepository) SaveItems(inventoryItems []models.InventoryItem) error {
-	dir := filepath.Dir(r.filePath)
-	err := utils.CreateDir(dir)
-	if err != nil {
-		return fmt.Errorf("failed to create directory for file %s: %w", dir, err)
-	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
 
-	exists, err := utils.FileExists(r.filePath)
-	if err != nil {
-		return fmt.Errorf("error checking if file exists: %w", err)
-	}
+	return r.save(inventoryItems)
+}
 
-	if !exists {
-		err := utils.CreateFile(r.filePath)
-		if err != nil {
-			return fmt.Errorf("error creating file %s: %w", r.filePath, err)
-		}
-	}
+// UpdateItems passes the current inventory items to update and saves the items it returns.
+// No other write can happen in between, so concurrent updates, such as the deductions of orders
+// closed at the same time, are applied one after the other. Nothing is saved if update returns an error.
+func (r *inventoryRepository) UpdateItems(update func(items []models.InventoryItem) ([]models.InventoryItem, error)) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
 
-	jsonData, err := json.MarshalIndent(inventoryItems, "", " ")
+	items, err := r.GetAllItems()
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(r.filePath, jsonData, 0o644)
+	updatedItems, err := update(items)
 	if err != nil {
 		return err
 	}
 
+	return r.save(updatedItems)
+}
+
+func (r *inventoryRepository) save(inventoryItems []models.InventoryItem) error {
+	if err := writeJSON(r.storage, r.key, inventoryItems); err != nil {
+		// The stored data is unknown after a failed write
+		r.cache.invalidate()
+		return err
+	}
+
+	r.cache.set(inventoryItems)
 	return nil
 }
 
-// ItemExists checks if an inventory item with the same ID already exists in the repository.
+// InvalidateCache makes the next read load the items from storage again.
+func (r *inventoryRepository) InvalidateCache() {
+	r.cache.invalidate()
+}
+
+// LockWrites blocks the writes of the repository until UnlockWrites is called.
+func (r *inventoryRepository) LockWrites() {
+	r.writeMu.Lock()
+}
+
+// UnlockWrites lets the writes blocked by LockWrites proceed.
+func (r *inventoryRepository) UnlockWrites() {
+	r.writeMu.Unlock()
+}
+
+// ItemExists checks if an inventory item with the same ID, ignoring case, already exists in the repository.
 // Returns true if the item exists, false otherwise.
 func (r *inventoryRepository) ItemExists(i models.InventoryItem) (bool, error) {
 	inventoryItems, err := r.GetAllItems()
@@ -170,7 +191,7 @@ func (r *inventoryRepository) ItemExists(i models.InventoryItem) (bool, error) {
 	}
 
 	for _, item := range inventoryItems {
-		if item.IngredientID == i.IngredientID {
+		if strings.EqualFold(item.IngredientID, i.IngredientID) {
 			return true, nil
 		}
 	}
@@ -199,6 +220,9 @@ func (r *inventoryRepository) ItemExists(i models.InventoryItem) (bool, error) {
 // - ErrNoItem if the item with the specified ID is not found.
 // - An error if there is a failure when retrieving or saving items in the repository.
 func (r *inventoryRepository) DeleteItemByID(id string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
 	inventoryItems, err := r.GetAllItems()
 	if err != nil {
 		if err.Error() == "EOF" {
@@ -221,10 +245,15 @@ func (r *inventoryRepository) DeleteItemByID(id string) error {
 		return errors.New("item not found")
 	}
 
-	err = r.SaveItems(inventoryItems)
+	err = r.save(inventoryItems)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// LastModified returns the time the inventory was last saved, or the zero time if it was never saved.
+func (r *inventoryRepository) LastModified() (time.Time, error) {
+	return r.storage.ModTime(r.key)
+}
@@ -0,0 +1,68 @@
+package dal
+
+import "hot-coffee/models"
+
+// Keys of the in-memory repositories, each repository gets its own storage
+const (
+	inMemoryInventoryKey   = "inventory"
+	inMemoryMenuKey        = "menu_items"
+	inMemoryOrderKey       = "orders"
+	inMemoryReportKey      = "report"
+	inMemoryAdjustmentKey  = "inventory_adjustments"
+	inMemoryOrderAuditKey  = "order_history"
+	inMemoryReservationKey = "reservations"
+)
+
+// NewInMemoryInventoryRepository creates an inventory repository kept in memory and seeded with the given items.
+func NewInMemoryInventoryRepository(items ...models.InventoryItem) *inventoryRepository {
+	r := NewInventoryRepositoryWithStorage(NewInMemoryStorage(), inMemoryInventoryKey)
+	if len(items) > 0 {
+		// Encoding plain models to memory can not fail
+		_ = r.SaveItems(items)
+	}
+	return r
+}
+
+// NewInMemoryMenuRepository creates a menu repository kept in memory and seeded with the given items.
+func NewInMemoryMenuRepository(items ...models.MenuItem) *menuRepository {
+	r := NewMenuRepositoryWithStorage(NewInMemoryStorage(), inMemoryMenuKey)
+	if len(items) > 0 {
+		_ = r.SaveMenuItems(items)
+	}
+	return r
+}
+
+// NewInMemoryOrderRepository creates an order repository kept in memory and seeded with the given orders.
+func NewInMemoryOrderRepository(orders ...models.Order) *orderRepository {
+	r := NewOrderRepositoryWithStorage(NewInMemoryStorage(), inMemoryOrderKey)
+	if len(orders) > 0 {
+		_ = r.SaveOrders(orders)
+	}
+	return r
+}
+
+// NewInMemoryReportRepository creates a report repository kept in memory and seeded with the given total sales.
+func NewInMemoryReportRepository(totalSales float64) *reportRepository {
+	r := NewReportRepositoryWithStorage(NewInMemoryStorage(), inMemoryReportKey)
+	_ = r.SetTotalSales(totalSales)
+	return r
+}
+
+// NewInMemoryAdjustmentRepository creates an empty inventory adjustment log kept in memory.
+func NewInMemoryAdjustmentRepository() *adjustmentRepository {
+	return NewAdjustmentRepositoryWithStorage(NewInMemoryStorage(), inMemoryAdjustmentKey)
+}
+
+// NewInMemoryOrderAuditRepository creates an empty order history kept in memory.
+func NewInMemoryOrderAuditRepository() *orderAuditRepository {
+	return NewOrderAuditRepositoryWithStorage(NewInMemoryStorage(), inMemoryOrderAuditKey)
+}
+
+// NewInMemoryReservationRepository creates a reservation repository kept in memory and seeded with the given reservations.
+func NewInMemoryReservationRepository(reservations ...models.InventoryReservation) *reservationRepository {
+	r := NewReservationRepositoryWithStorage(NewInMemoryStorage(), inMemoryReservationKey)
+	for _, reservation := range reservations {
+		_ = r.SaveReservation(reservation)
+	}
+	return r
+}
@@ -0,0 +1,44 @@
+package dal
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// inMemoryStorage is a Storage keeping the data in memory, e.g. for tests.
+type inMemoryStorage struct {
+	mu       sync.RWMutex
+	data     map[string][]byte
+	modTimes map[string]time.Time
+}
+
+func NewInMemoryStorage() *inMemoryStorage {
+	return &inMemoryStorage{data: make(map[string][]byte), modTimes: make(map[string]time.Time)}
+}
+
+// Read returns a copy of the data stored under the key, or nil if nothing is stored yet.
+func (s *inMemoryStorage) Read(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.data[key]), nil
+}
+
+// Write stores a copy of the data under the key.
+func (s *inMemoryStorage) Write(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[key] = slices.Clone(data)
+	s.modTimes[key] = time.Now()
+	return nil
+}
+
+// ModTime returns the time of the last write under the key, or the zero time if nothing is stored yet.
+func (s *inMemoryStorage) ModTime(key string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.modTimes[key], nil
+}
@@ -0,0 +1,107 @@
+package dal
+
+import (
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestInMemoryStorage(t *testing.T) {
+	s := NewInMemoryStorage()
+
+	if data, err := s.Read("missing"); err != nil || data != nil {
+		t.Fatalf("Read() of a missing key = %q, %v, want nil", data, err)
+	}
+	if modTime, err := s.ModTime("missing"); err != nil || !modTime.IsZero() {
+		t.Fatalf("ModTime() of a missing key = %v, %v, want the zero time", modTime, err)
+	}
+
+	data := []byte("[1]")
+	if err := s.Write("key", data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither the written nor the read slice may alias the stored data
+	data[1] = '2'
+	read, _ := s.Read("key")
+	if string(read) != "[1]" {
+		t.Errorf("Read() = %q after changing the written slice, want %q", read, "[1]")
+	}
+	read[1] = '3'
+	if read, _ := s.Read("key"); string(read) != "[1]" {
+		t.Errorf("Read() = %q after changing a read slice, want %q", read, "[1]")
+	}
+
+	if modTime, _ := s.ModTime("key"); modTime.IsZero() {
+		t.Error("ModTime() is zero after a write")
+	}
+}
+
+func TestInMemoryRepositories(t *testing.T) {
+	tests := []struct {
+		name  string
+		count func() (int, error)
+		want  int
+	}{
+		{
+			name: "inventory",
+			count: func() (int, error) {
+				items, err := NewInMemoryInventoryRepository(models.InventoryItem{IngredientID: "milk"}, models.InventoryItem{IngredientID: "sugar"}).GetAllItems()
+				return len(items), err
+			},
+			want: 2,
+		},
+		{
+			name: "menu",
+			count: func() (int, error) {
+				items, err := NewInMemoryMenuRepository(models.MenuItem{ID: "latte"}).GetAllMenuItems()
+				return len(items), err
+			},
+			want: 1,
+		},
+		{
+			name: "orders",
+			count: func() (int, error) {
+				orders, err := NewInMemoryOrderRepository(models.Order{ID: "orders1", Status: models.StatusOpen}).GetAllOrders()
+				return len(orders), err
+			},
+			want: 1,
+		},
+		{
+			name: "reservations",
+			count: func() (int, error) {
+				reservations, err := NewInMemoryReservationRepository(models.InventoryReservation{OrderID: "orders1"}, models.InventoryReservation{OrderID: "orders1"}).GetAllReservations()
+				return len(reservations), err
+			},
+			want: 1,
+		},
+		{
+			name: "empty adjustments",
+			count: func() (int, error) {
+				adjustments, err := NewInMemoryAdjustmentRepository().GetAllAdjustments()
+				return len(adjustments), err
+			},
+			want: 0,
+		},
+		{
+			name: "empty order history",
+			count: func() (int, error) {
+				entries, err := NewInMemoryOrderAuditRepository().GetAllEntries()
+				return len(entries), err
+			},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.count()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("repository holds %d records, want %d", got, tt.want)
+			}
+		})
+	}
+}
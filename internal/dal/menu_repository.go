@@ -1,11 +1,8 @@
 package dal
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
-	"os"
-	"path/filepath"
+	"sync"
 
 	"hot-coffee/internal/utils"
 	"hot-coffee/models"
@@ -16,16 +13,28 @@ type MenuRepository interface {
 	GetAllMenuItems() ([]models.MenuItem, error)
 	GetMenuItemById(id string) (models.MenuItem, error)
 	SaveMenuItems(menuItems []models.MenuItem) error
+	UpdateMenuItems(update func(menuItems []models.MenuItem) ([]models.MenuItem, error)) error
 	MenuItemExists(i models.MenuItem) (bool, error)
 	RewriteMenuItem(id string, newItem models.MenuItem) error
 }
 
 type menuRepository struct {
-	filePath string
+	storage Storage
+	key     string
+	cache   *cache[[]models.MenuItem]
+
+	// writeMu serializes the writes, so a read-modify-write never overwrites a concurrent change
+	writeMu sync.Mutex
 }
 
+// NewMenuRepository creates a repository storing its data in the JSON file at filePath.
 func NewMenuRepository(filePath string) *menuRepository {
-	return &menuRepository{filePath: filePath}
+	return NewMenuRepositoryWithStorage(NewFileStorage(), filePath)
+}
+
+// NewMenuRepositoryWithStorage creates a repository storing its data under the key in the given storage.
+func NewMenuRepositoryWithStorage(storage Storage, key string) *menuRepository {
+	return &menuRepository{storage: storage, key: key, cache: newCache(cloneMenuItems)}
 }
 
 // AddMenuItem adds a new menu item to the repository.
@@ -33,6 +42,9 @@ func NewMenuRepository(filePath string) *menuRepository {
 // then appends the new item to the list, and saves the updated list back to the repository.
 // Returns the added item if successful, or an error if there was a failure during retrieval or saving of the items.
 func (r *menuRepository) AddMenuItem(i models.MenuItem) (models.MenuItem, error) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
 	items, err := r.GetAllMenuItems()
 	if err != nil {
 		return models.MenuItem{}, err
@@ -50,7 +62,7 @@ func (r *menuRepository) AddMenuItem(i models.MenuItem) (models.MenuItem, error)
 
 	items = append(items, i)
 
-	err = r.SaveMenuItems(items)
+	err = r.save(items)
 	if err != nil {
 		return models.MenuItem{}, err
 	}
@@ -62,29 +74,11 @@ func (r *menuRepository) AddMenuItem(i models.MenuItem) (models.MenuItem, error)
 // It checks if the file exists, and if it does, opens it and decodes the list of menu items.
 // Returns the list of menu items if successful, or an empty list and an error if there was an issue reading the file.
 func (r *menuRepository) GetAllMenuItems() ([]models.MenuItem, error) {
-	menuItems := []models.MenuItem{}
-
-	exists, err := utils.FileExists(r.filePath)
-	if err != nil {
-		return []models.MenuItem{}, err
-	}
-	if !exists {
-		return []models.MenuItem{}, nil
-	}
-
-	file, err := os.Open(r.filePath)
-	if err != nil {
-		return []models.MenuItem{}, err
-	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-
-	if utils.FileEmpty(file) {
-		return []models.MenuItem{}, nil
-	}
-
-	err = decoder.Decode(&menuItems)
+	menuItems, err := r.cache.get(func() ([]models.MenuItem, error) {
+		menuItems := []models.MenuItem{}
+		err := readJSON(r.storage, r.key, &menuItems)
+		return menuItems, err
+	})
 	if err != nil {
 		return []models.MenuItem{}, err
 	}
@@ -114,37 +108,59 @@ func (r *menuRepository) GetMenuItemById(id string) (models.MenuItem, error) {
 // It ensures that the file's directory exists, creates the file if necessary,
 // and checks for write permissions before writing the data.
 func (r *menuRepository) SaveMenuItems(menuItems []models.MenuItem) error {
-	dir := filepath.Dir(r.filePath)
-	err := utils.CreateDir(dir)
-	if err != nil {
-		return fmt.Errorf("failed to create directory for file %s: %w", dir, err)
-	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
 
-	exists, err := utils.FileExists(r.filePath)
-	if err != nil {
-		return fmt.Errorf("error checking if file exists: %w", err)
-	}
+	return r.save(menuItems)
+}
 
-	if !exists {
-		err := utils.CreateFile(r.filePath)
-		if err != nil {
-			return fmt.Errorf("error creating file %s: %w", r.filePath, err)
-		}
-	}
+// UpdateMenuItems passes the current menu items to update and saves the items it returns.
+// No other write can happen in between, so concurrent changes of the menu are applied one after the other.
+// Nothing is saved if update returns an error.
+func (r *menuRepository) UpdateMenuItems(update func(menuItems []models.MenuItem) ([]models.MenuItem, error)) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
 
-	jsonData, err := json.MarshalIndent(menuItems, "", " ")
+	menuItems, err := r.GetAllMenuItems()
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(r.filePath, jsonData, 0o644)
+	updatedItems, err := update(menuItems)
 	if err != nil {
 		return err
 	}
 
+	return r.save(updatedItems)
+}
+
+// save writes the menu items and updates the cache, the caller holds writeMu.
+func (r *menuRepository) save(menuItems []models.MenuItem) error {
+	if err := writeJSON(r.storage, r.key, menuItems); err != nil {
+		// The stored data is unknown after a failed write
+		r.cache.invalidate()
+		return err
+	}
+
+	r.cache.set(menuItems)
 	return nil
 }
 
+// InvalidateCache makes the next read load the menu items from storage again.
+func (r *menuRepository) InvalidateCache() {
+	r.cache.invalidate()
+}
+
+// LockWrites blocks the writes of the repository until UnlockWrites is called.
+func (r *menuRepository) LockWrites() {
+	r.writeMu.Lock()
+}
+
+// UnlockWrites lets the writes blocked by LockWrites proceed.
+func (r *menuRepository) UnlockWrites() {
+	r.writeMu.Unlock()
+}
+
 // MenuItemExists checks whether a menu item with the specified ID already exists in the repository.
 // It retrieves all menu items and compares each item's ID with the provided item's ID.
 func (r *menuRepository) MenuItemExists(i models.MenuItem) (bool, error) {
@@ -166,6 +182,9 @@ func (r *menuRepository) MenuItemExists(i models.MenuItem) (bool, error) {
 // It searches for the item by its ID and replaces it with the provided new item.
 // If successful, the updated list of menu items is saved back to the repository.
 func (r *menuRepository) RewriteMenuItem(id string, newItem models.MenuItem) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
 	items, err := r.GetAllMenuItems()
 	if err != nil {
 		return err
@@ -178,7 +197,7 @@ func (r *menuRepository) RewriteMenuItem(id string, newItem models.MenuItem) err
 		}
 	}
 
-	err = r.SaveMenuItems(items)
+	err = r.save(items)
 	if err != nil {
 		return err
 	}
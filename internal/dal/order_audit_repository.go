@@ -0,0 +1,102 @@
+package dal
+
+import (
+	"sync"
+
+	"hot-coffee/models"
+)
+
+// OrderAuditRepository is an append-only log of order changes.
+type OrderAuditRepository interface {
+	AddEntry(e models.OrderAuditEntry) error
+	GetAllEntries() ([]models.OrderAuditEntry, error)
+	GetEntriesByHistoryId(key string) ([]models.OrderAuditEntry, error)
+}
+
+type orderAuditRepository struct {
+	storage Storage
+	key     string
+	cache   *cache[[]models.OrderAuditEntry]
+
+	// writeMu serializes the appends, so concurrent changes of orders are all kept
+	writeMu sync.Mutex
+}
+
+// NewOrderAuditRepository creates a repository storing its data in the JSON file at filePath.
+func NewOrderAuditRepository(filePath string) *orderAuditRepository {
+	return NewOrderAuditRepositoryWithStorage(NewFileStorage(), filePath)
+}
+
+// NewOrderAuditRepositoryWithStorage creates a repository storing its data under the key in the given storage.
+func NewOrderAuditRepositoryWithStorage(storage Storage, key string) *orderAuditRepository {
+	return &orderAuditRepository{storage: storage, key: key, cache: newCache(cloneAuditEntries)}
+}
+
+// AddEntry appends an entry to the log. Existing records are never changed.
+func (r *orderAuditRepository) AddEntry(e models.OrderAuditEntry) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	entries, err := r.GetAllEntries()
+	if err != nil {
+		return err
+	}
+
+	entries = append(entries, e)
+
+	if err := writeJSON(r.storage, r.key, entries); err != nil {
+		// The stored data is unknown after a failed write
+		r.cache.invalidate()
+		return err
+	}
+
+	r.cache.set(entries)
+	return nil
+}
+
+// GetAllEntries returns every entry in the order they were recorded.
+func (r *orderAuditRepository) GetAllEntries() ([]models.OrderAuditEntry, error) {
+	entries, err := r.cache.get(func() ([]models.OrderAuditEntry, error) {
+		entries := []models.OrderAuditEntry{}
+		err := readJSON(r.storage, r.key, &entries)
+		return entries, err
+	})
+	if err != nil {
+		return []models.OrderAuditEntry{}, err
+	}
+
+	return entries, nil
+}
+
+// InvalidateCache makes the next read load the entries from storage again.
+func (r *orderAuditRepository) InvalidateCache() {
+	r.cache.invalidate()
+}
+
+// LockWrites blocks the writes of the repository until UnlockWrites is called.
+func (r *orderAuditRepository) LockWrites() {
+	r.writeMu.Lock()
+}
+
+// UnlockWrites lets the writes blocked by LockWrites proceed.
+func (r *orderAuditRepository) UnlockWrites() {
+	r.writeMu.Unlock()
+}
+
+// GetEntriesByHistoryId returns the entries of a single order history in the order they were recorded.
+// Entries recorded without a history key belong to the history keyed by their order ID.
+func (r *orderAuditRepository) GetEntriesByHistoryId(key string) ([]models.OrderAuditEntry, error) {
+	entries, err := r.GetAllEntries()
+	if err != nil {
+		return []models.OrderAuditEntry{}, err
+	}
+
+	orderEntries := []models.OrderAuditEntry{}
+	for _, entry := range entries {
+		if entry.HistoryID == key || (entry.HistoryID == "" && entry.OrderID == key) {
+			orderEntries = append(orderEntries, entry)
+		}
+	}
+
+	return orderEntries, nil
+}
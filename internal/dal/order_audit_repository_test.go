@@ -0,0 +1,77 @@
+package dal
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestAddEntryConcurrent(t *testing.T) {
+	r := NewInMemoryOrderAuditRepository()
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := r.AddEntry(models.OrderAuditEntry{OrderID: fmt.Sprintf("orders%d", i), Action: models.AuditCreated}); err != nil {
+				t.Errorf("AddEntry() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := r.GetAllEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != writers {
+		t.Errorf("log has %d entries, want %d: a concurrent append was lost", len(entries), writers)
+	}
+}
+
+func TestGetEntriesByHistoryId(t *testing.T) {
+	r := NewInMemoryOrderAuditRepository()
+	for _, entry := range []models.OrderAuditEntry{
+		{OrderID: "orders1", Action: models.AuditCreated},
+		{OrderID: "orders1", HistoryID: "orders1", Action: models.AuditUpdated},
+		{OrderID: "renamed", HistoryID: "orders1", Action: models.AuditUpdated},
+		{OrderID: "orders2", HistoryID: "a1b2", Action: models.AuditCreated},
+		{OrderID: "orders1", HistoryID: "c3d4", Action: models.AuditCreated},
+	} {
+		if err := r.AddEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want []string
+	}{
+		{name: "legacy entries and renames", key: "orders1", want: []string{"orders1", "orders1", "renamed"}},
+		{name: "keyed history", key: "a1b2", want: []string{"orders2"}},
+		{name: "reused order ID", key: "c3d4", want: []string{"orders1"}},
+		{name: "unknown key", key: "missing", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := r.GetEntriesByHistoryId(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.want) {
+				t.Fatalf("GetEntriesByHistoryId(%q) returned %d entries, want %d", tt.key, len(entries), len(tt.want))
+			}
+			for i, entry := range entries {
+				if entry.OrderID != tt.want[i] {
+					t.Errorf("entry %d has order ID %q, want %q", i, entry.OrderID, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -1,11 +1,8 @@
 package dal
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
-	"os"
-	"path/filepath"
+	"sync"
 
 	"hot-coffee/internal/utils"
 	"hot-coffee/models"
@@ -14,25 +11,41 @@ import (
 type OrderRepository interface {
 	AddOrder(order models.Order) (models.Order, error)
 	GetAllOrders() ([]models.Order, error)
-	GetOrdersByStatus(status string) ([]models.Order, error)
+	GetOrdersByStatus(status models.OrderStatus) ([]models.Order, error)
 	GetClosedOrders() ([]models.Order, error)
 	GetOpenOrders() ([]models.Order, error)
 	GetOrderById(id string) (models.Order, error)
 	DeleteOrderById(id string) error
 	SaveOrders(orders []models.Order) error
+	UpdateOrders(update func(orders []models.Order) ([]models.Order, error)) error
 	OrderExists(o models.Order) (bool, error)
 	RewriteOrder(id string, newOrder models.Order) error
+	MigrateStatuses() (int, error)
 }
 
 type orderRepository struct {
-	filePath string
+	storage Storage
+	key     string
+	cache   *cache[[]models.Order]
+
+	// writeMu serializes the writes, so a read-modify-write never overwrites a concurrent change
+	writeMu sync.Mutex
 }
 
+// NewOrderRepository creates a repository storing its data in the JSON file at filePath.
 func NewOrderRepository(filePath string) *orderRepository {
-	return &orderRepository{filePath: filePath}
+	return NewOrderRepositoryWithStorage(NewFileStorage(), filePath)
+}
+
+// NewOrderRepositoryWithStorage creates a repository storing its data under the key in the given storage.
+func NewOrderRepositoryWithStorage(storage Storage, key string) *orderRepository {
+	return &orderRepository{storage: storage, key: key, cache: newCache(cloneOrders)}
 }
 
 func (r *orderRepository) AddOrder(order models.Order) (models.Order, error) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
 	orders, err := r.GetAllOrders()
 	if err != nil {
 		return models.Order{}, err
@@ -50,7 +63,7 @@ func (r *orderRepository) AddOrder(order models.Order) (models.Order, error) {
 
 	orders = append(orders, order)
 
-	err = r.SaveOrders(orders)
+	err = r.save(orders)
 	if err != nil {
 		return models.Order{}, err
 	}
@@ -59,34 +72,59 @@ func (r *orderRepository) AddOrder(order models.Order) (models.Order, error) {
 }
 
 func (r *orderRepository) GetAllOrders() ([]models.Order, error) {
-	orders := []models.Order{}
-
-	exists, err := utils.FileExists(r.filePath)
+	orders, err := r.cache.get(r.loadOrders)
 	if err != nil {
 		return []models.Order{}, err
 	}
-	if !exists {
-		return []models.Order{}, nil
-	}
 
-	file, err := os.Open(r.filePath)
+	return orders, nil
+}
+
+// loadOrders reads the orders from storage. Statuses stored in a legacy casing, e.g. "Open",
+// are read in the canonical form, MigrateStatuses rewrites them in storage.
+func (r *orderRepository) loadOrders() ([]models.Order, error) {
+	orders := []models.Order{}
+
+	err := readJSON(r.storage, r.key, &orders)
 	if err != nil {
 		return []models.Order{}, err
 	}
-	defer file.Close()
 
-	decoder := json.NewDecoder(file)
+	for i := range orders {
+		orders[i].Status = orders[i].Status.Normalize()
+	}
+
+	return orders, nil
+}
+
+// MigrateStatuses rewrites the statuses stored in a legacy casing, e.g. "Open", in the canonical form.
+// It is run once on startup, the stored orders are only written if any status changed.
+// Returns the number of orders migrated.
+func (r *orderRepository) MigrateStatuses() (int, error) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
 
-	if utils.FileEmpty(file) {
-		return []models.Order{}, nil
+	// The stored orders are read as they are, the cached orders are already normalized
+	orders := []models.Order{}
+	if err := readJSON(r.storage, r.key, &orders); err != nil {
+		return 0, err
 	}
 
-	err = decoder.Decode(&orders)
-	if err != nil {
-		return []models.Order{}, err
+	migrated := 0
+	for i := range orders {
+		if status := orders[i].Status.Normalize(); status != orders[i].Status {
+			orders[i].Status = status
+			migrated++
+		}
+	}
+	if migrated == 0 {
+		return 0, nil
 	}
 
-	return orders, nil
+	if err := r.save(orders); err != nil {
+		return 0, err
+	}
+	return migrated, nil
 }
 
 func (r *orderRepository) GetOrderById(id string) (models.Order, error) {
@@ -105,6 +143,9 @@ func (r *orderRepository) GetOrderById(id string) (models.Order, error) {
 }
 
 func (r *orderRepository) DeleteOrderById(id string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
 	orders, err := r.GetAllOrders()
 	if err != nil {
 		if err.Error() == "EOF" {
@@ -126,7 +167,7 @@ func (r *orderRepository) DeleteOrderById(id string) error {
 		return errors.New("order not found")
 	}
 
-	err = r.SaveOrders(orders)
+	err = r.save(orders)
 	if err != nil {
 		return err
 	}
@@ -135,40 +176,69 @@ func (r *orderRepository) DeleteOrderById(id string) error {
 }
 
 func (r *orderRepository) SaveOrders(orders []models.Order) error {
-	// Checking the existence of a directory for a file
-	dir := filepath.Dir(r.filePath)
-	err := utils.CreateDir(dir)
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	return r.save(orders)
+}
+
+// UpdateOrders passes the current orders to update and saves the orders it returns.
+// No other write can happen in between, so an order created or closed concurrently is not lost
+// or overwritten. Nothing is saved if update returns an error.
+func (r *orderRepository) UpdateOrders(update func(orders []models.Order) ([]models.Order, error)) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	orders, err := r.GetAllOrders()
 	if err != nil {
 		return err
 	}
 
-	// Checking file write permissions
-	exists, err := utils.FileExists(r.filePath)
+	updatedOrders, err := update(orders)
 	if err != nil {
 		return err
 	}
 
-	if !exists {
-		// If the file does not exist, create it
-		err := utils.CreateFile(r.filePath)
-		if err != nil {
-			return fmt.Errorf("error creating file %s: %w", r.filePath, err)
-		}
-	}
+	return r.save(updatedOrders)
+}
 
-	jsonData, err := json.MarshalIndent(orders, "", " ")
-	if err != nil {
-		return err
+func (r *orderRepository) save(orders []models.Order) error {
+	// Derived fields are not persisted, statuses are stored in the canonical form
+	storedOrders := make([]models.Order, len(orders))
+	for i, order := range orders {
+		order.Subtotal, order.DiscountAmount, order.Tax, order.TotalPrice = 0, 0, 0, 0
+		order.FulfillmentSeconds = nil
+		order.Status = order.Status.Normalize()
+		storedOrders[i] = order
 	}
 
-	err = os.WriteFile(r.filePath, jsonData, 0o644)
-	if err != nil {
+	if err := writeJSON(r.storage, r.key, storedOrders); err != nil {
+		// The stored data is unknown after a failed write
+		r.cache.invalidate()
 		return err
 	}
 
+	r.cache.set(storedOrders)
 	return nil
 }
 
+// InvalidateCache makes the next read load the orders from storage again.
+func (r *orderRepository) InvalidateCache() {
+	r.cache.invalidate()
+}
+
+// LockWrites blocks the writes of the repository until UnlockWrites is called.
+func (r *orderRepository) LockWrites() {
+	r.writeMu.Lock()
+}
+
+// UnlockWrites lets the writes blocked by LockWrites proceed.
+func (r *orderRepository) UnlockWrites() {
+	r.writeMu.Unlock()
+}
+
+// OrderExists reports whether an order with the same ID as o is stored.
+// Orders are unique by ID alone, the other fields are not compared.
 func (r *orderRepository) OrderExists(o models.Order) (bool, error) {
 	orders, err := r.GetAllOrders()
 	if err != nil {
@@ -185,6 +255,9 @@ func (r *orderRepository) OrderExists(o models.Order) (bool, error) {
 }
 
 func (r *orderRepository) RewriteOrder(id string, newOrder models.Order) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
 	orders, err := r.GetAllOrders()
 	if err != nil {
 		return err
@@ -197,7 +270,7 @@ func (r *orderRepository) RewriteOrder(id string, newOrder models.Order) error {
 		}
 	}
 
-	err = r.SaveOrders(orders)
+	err = r.save(orders)
 	if err != nil {
 		return err
 	}
@@ -205,26 +278,27 @@ func (r *orderRepository) RewriteOrder(id string, newOrder models.Order) error {
 	return nil
 }
 
-func (r *orderRepository) GetOrdersByStatus(status string) ([]models.Order, error) {
+// GetOrdersByStatus returns the orders with the given status, compared in any casing.
+func (r *orderRepository) GetOrdersByStatus(status models.OrderStatus) ([]models.Order, error) {
 	orders, err := r.GetAllOrders()
 	if err != nil {
 		return []models.Order{}, err
 	}
 
-	closedOrders := []models.Order{}
+	filteredOrders := []models.Order{}
 	for _, order := range orders {
-		if order.Status == status {
-			closedOrders = append(closedOrders, order)
+		if order.Status.Normalize() == status.Normalize() {
+			filteredOrders = append(filteredOrders, order)
 		}
 	}
 
-	return closedOrders, nil
+	return filteredOrders, nil
 }
 
 func (r *orderRepository) GetClosedOrders() ([]models.Order, error) {
-	return r.GetOrdersByStatus("closed")
+	return r.GetOrdersByStatus(models.StatusClosed)
 }
 
 func (r *orderRepository) GetOpenOrders() ([]models.Order, error) {
-	return r.GetOrdersByStatus("open")
+	return r.GetOrdersByStatus(models.StatusOpen)
 }
@@ -0,0 +1,109 @@
+package dal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestMigrateStatuses(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []models.OrderStatus
+		wantMigrated int
+		wantWrite    bool
+	}{
+		{name: "canonical", statuses: []models.OrderStatus{"open", "closed"}, wantMigrated: 0},
+		{name: "legacy casing", statuses: []models.OrderStatus{"Open", "closed", " CLOSED "}, wantMigrated: 2, wantWrite: true},
+		{name: "no orders", statuses: nil, wantMigrated: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewInMemoryStorage()
+			orders := []models.Order{}
+			for _, status := range tt.statuses {
+				orders = append(orders, models.Order{ID: "orders" + string(rune('1'+len(orders))), Status: status})
+			}
+			data, _ := json.Marshal(orders)
+			_ = storage.Write("orders", data)
+			before, _ := storage.ModTime("orders")
+
+			r := NewOrderRepositoryWithStorage(storage, "orders")
+
+			// Reading never writes, the legacy statuses are normalized in memory only
+			read, err := r.GetAllOrders()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, order := range read {
+				if order.Status != order.Status.Normalize() {
+					t.Errorf("read status %q, want %q", order.Status, order.Status.Normalize())
+				}
+			}
+			if after, _ := storage.ModTime("orders"); !after.Equal(before) {
+				t.Error("GetAllOrders() wrote the orders")
+			}
+
+			migrated, err := r.MigrateStatuses()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migrated != tt.wantMigrated {
+				t.Errorf("MigrateStatuses() = %d, want %d", migrated, tt.wantMigrated)
+			}
+			if after, _ := storage.ModTime("orders"); after.Equal(before) == tt.wantWrite {
+				t.Errorf("orders written = %v, want %v", !after.Equal(before), tt.wantWrite)
+			}
+
+			stored := []models.Order{}
+			if err := readJSON(storage, "orders", &stored); err != nil {
+				t.Fatal(err)
+			}
+			for _, order := range stored {
+				if order.Status != order.Status.Normalize() {
+					t.Errorf("stored status %q after the migration, want %q", order.Status, order.Status.Normalize())
+				}
+			}
+		})
+	}
+}
+
+func TestOrderExists(t *testing.T) {
+	stored := models.Order{ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}}
+
+	tests := []struct {
+		name  string
+		order models.Order
+		want  bool
+	}{
+		{name: "same order", order: stored, want: true},
+		{
+			name:  "same ID, different content",
+			order: models.Order{ID: "orders1", CustomerName: "Bob", Status: models.StatusClosed, Items: []models.OrderItem{{ProductID: "mocha", Quantity: 3}}},
+			want:  true,
+		},
+		{
+			name:  "different ID, same content",
+			order: models.Order{ID: "orders2", CustomerName: "Alice", Status: models.StatusOpen, Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}},
+			want:  false,
+		},
+		{name: "ID differing in case", order: models.Order{ID: "Orders1"}, want: false},
+		{name: "empty ID", order: models.Order{}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewInMemoryOrderRepository(stored)
+
+			got, err := r.OrderExists(tt.order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("OrderExists() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,8 @@
 package dal
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
-	"path/filepath"
+	"sync"
 
-	"hot-coffee/internal/utils"
 	"hot-coffee/models"
 )
 
@@ -15,102 +11,113 @@ type ReportRepository interface {
 	SetTotalSales(t float64) error
 	SaveTotalSales(totalSales models.TotalSales) error
 	UpdateTotalSales(income float64) error
+	RecordSale(sale models.TotalSales) error
 	ResetTotalSales(income float64) error
 }
 
 type reportRepository struct {
-	filePath string
+	storage Storage
+	key     string
+	cache   *cache[models.TotalSales]
+
+	// writeMu serializes the writes, so a read-modify-write never overwrites a concurrent change
+	writeMu sync.Mutex
 }
 
+// NewReportRepository creates a repository storing its data in the JSON file at filePath.
 func NewReportRepository(filePath string) *reportRepository {
-	return &reportRepository{filePath: filePath}
+	return NewReportRepositoryWithStorage(NewFileStorage(), filePath)
 }
 
-func (r *reportRepository) GetTotalSales() (models.TotalSales, error) {
-	totalSales := models.TotalSales{}
+// NewReportRepositoryWithStorage creates a repository storing its data under the key in the given storage.
+func NewReportRepositoryWithStorage(storage Storage, key string) *reportRepository {
+	return &reportRepository{storage: storage, key: key, cache: newCache(cloneValue[models.TotalSales])}
+}
 
-	exists, err := utils.FileExists(r.filePath)
-	if err != nil {
+func (r *reportRepository) GetTotalSales() (models.TotalSales, error) {
+	return r.cache.get(func() (models.TotalSales, error) {
+		totalSales := models.TotalSales{}
+		err := readJSON(r.storage, r.key, &totalSales)
 		return totalSales, err
-	}
-	if !exists {
-		return totalSales, nil
-	}
+	})
+}
 
-	file, err := os.Open(r.filePath)
-	if err != nil {
-		return totalSales, err
-	}
-	defer file.Close()
+func (r *reportRepository) SaveTotalSales(totalSales models.TotalSales) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
 
-	decoder := json.NewDecoder(file)
+	return r.save(totalSales)
+}
 
-	if utils.FileEmpty(file) {
-		return totalSales, nil
+// save writes the total sales and updates the cache, the caller holds writeMu.
+func (r *reportRepository) save(totalSales models.TotalSales) error {
+	if err := writeJSON(r.storage, r.key, totalSales); err != nil {
+		// The stored data is unknown after a failed write
+		r.cache.invalidate()
+		return err
 	}
 
-	err = decoder.Decode(&totalSales)
-	if err != nil {
-		return totalSales, err
-	}
+	r.cache.set(totalSales)
+	return nil
+}
 
-	return totalSales, nil
+// InvalidateCache makes the next read load the total sales from storage again.
+func (r *reportRepository) InvalidateCache() {
+	r.cache.invalidate()
 }
 
-func (r *reportRepository) SaveTotalSales(totalSales models.TotalSales) error {
-	// Checking the existence of a directory for a file
-	dir := filepath.Dir(r.filePath)
-	err := utils.CreateDir(dir)
-	if err != nil {
-		return err
-	}
+// LockWrites blocks the writes of the repository until UnlockWrites is called.
+func (r *reportRepository) LockWrites() {
+	r.writeMu.Lock()
+}
 
-	// Checking file write permissions
-	exists, err := utils.FileExists(r.filePath)
-	if err != nil {
-		return err
-	}
+// UnlockWrites lets the writes blocked by LockWrites proceed.
+func (r *reportRepository) UnlockWrites() {
+	r.writeMu.Unlock()
+}
 
-	if !exists {
-		// If the file does not exist, create it
-		err := utils.CreateFile(r.filePath)
-		if err != nil {
-			return fmt.Errorf("error creating file %s: %w", r.filePath, err)
-		}
-	}
+func (r *reportRepository) SetTotalSales(t float64) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
 
-	jsonData, err := json.MarshalIndent(totalSales, "", " ")
+	totalSales, err := r.GetTotalSales()
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(r.filePath, jsonData, 0o644)
-	if err != nil {
-		return err
-	}
+	totalSales.TotalSales = t
 
-	return nil
+	return r.save(totalSales)
 }
 
-func (r *reportRepository) SetTotalSales(t float64) error {
+func (r *reportRepository) UpdateTotalSales(income float64) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
 	totalSales, err := r.GetTotalSales()
 	if err != nil {
 		return err
 	}
 
-	totalSales.TotalSales = t
-
-	return r.SaveTotalSales(totalSales)
+	totalSales.TotalSales += income
+	return r.save(totalSales)
 }
 
-func (r *reportRepository) UpdateTotalSales(income float64) error {
+// RecordSale adds the price breakdown of a closed order to the stored totals.
+func (r *reportRepository) RecordSale(sale models.TotalSales) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
 	totalSales, err := r.GetTotalSales()
 	if err != nil {
 		return err
 	}
 
-	totalSales.TotalSales += income
-	return r.SaveTotalSales(totalSales)
+	totalSales.Subtotal += sale.Subtotal
+	totalSales.Discounts += sale.Discounts
+	totalSales.Tax += sale.Tax
+	totalSales.TotalSales += sale.TotalSales
+	return r.save(totalSales)
 }
 
 func (r *reportRepository) ResetTotalSales(income float64) error {
@@ -0,0 +1,138 @@
+package dal
+
+import (
+	"slices"
+	"sync"
+
+	"hot-coffee/models"
+)
+
+// ReservationRepository stores the inventory reservations of open orders, at most one per order.
+type ReservationRepository interface {
+	GetAllReservations() ([]models.InventoryReservation, error)
+	SaveReservation(rv models.InventoryReservation) error
+	DeleteReservation(orderID string) (bool, error)
+	UpdateReservations(update func(reservations []models.InventoryReservation) ([]models.InventoryReservation, error)) error
+}
+
+type reservationRepository struct {
+	storage Storage
+	key     string
+	cache   *cache[[]models.InventoryReservation]
+
+	// writeMu serializes the writes, so a read-modify-write never overwrites a concurrent change
+	writeMu sync.Mutex
+}
+
+// NewReservationRepository creates a repository storing its data in the JSON file at filePath.
+func NewReservationRepository(filePath string) *reservationRepository {
+	return NewReservationRepositoryWithStorage(NewFileStorage(), filePath)
+}
+
+// NewReservationRepositoryWithStorage creates a repository storing its data under the key in the given storage.
+func NewReservationRepositoryWithStorage(storage Storage, key string) *reservationRepository {
+	return &reservationRepository{storage: storage, key: key, cache: newCache(cloneReservations)}
+}
+
+// GetAllReservations returns every reservation in the order they were first made.
+func (r *reservationRepository) GetAllReservations() ([]models.InventoryReservation, error) {
+	reservations, err := r.cache.get(func() ([]models.InventoryReservation, error) {
+		reservations := []models.InventoryReservation{}
+		err := readJSON(r.storage, r.key, &reservations)
+		return reservations, err
+	})
+	if err != nil {
+		return []models.InventoryReservation{}, err
+	}
+
+	return reservations, nil
+}
+
+// InvalidateCache makes the next read load the reservations from storage again.
+func (r *reservationRepository) InvalidateCache() {
+	r.cache.invalidate()
+}
+
+// LockWrites blocks the writes of the repository until UnlockWrites is called.
+func (r *reservationRepository) LockWrites() {
+	r.writeMu.Lock()
+}
+
+// UnlockWrites lets the writes blocked by LockWrites proceed.
+func (r *reservationRepository) UnlockWrites() {
+	r.writeMu.Unlock()
+}
+
+// SaveReservation stores the reservation, replacing an earlier reservation of the same order.
+func (r *reservationRepository) SaveReservation(rv models.InventoryReservation) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	reservations, err := r.GetAllReservations()
+	if err != nil {
+		return err
+	}
+
+	index := slices.IndexFunc(reservations, func(existing models.InventoryReservation) bool {
+		return existing.OrderID == rv.OrderID
+	})
+	if index >= 0 {
+		reservations[index] = rv
+	} else {
+		reservations = append(reservations, rv)
+	}
+
+	return r.save(reservations)
+}
+
+// DeleteReservation removes the reservation of an order.
+// Returns false if the order has no reservation.
+func (r *reservationRepository) DeleteReservation(orderID string) (bool, error) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	reservations, err := r.GetAllReservations()
+	if err != nil {
+		return false, err
+	}
+
+	index := slices.IndexFunc(reservations, func(existing models.InventoryReservation) bool {
+		return existing.OrderID == orderID
+	})
+	if index < 0 {
+		return false, nil
+	}
+
+	return true, r.save(slices.Delete(reservations, index, index+1))
+}
+
+// UpdateReservations passes the current reservations to update and saves the reservations it returns.
+// No other write can happen in between, so a reservation checked against the others is saved before
+// another order can reserve the same stock. Nothing is saved if update returns an error.
+func (r *reservationRepository) UpdateReservations(update func(reservations []models.InventoryReservation) ([]models.InventoryReservation, error)) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	reservations, err := r.GetAllReservations()
+	if err != nil {
+		return err
+	}
+
+	updatedReservations, err := update(reservations)
+	if err != nil {
+		return err
+	}
+
+	return r.save(updatedReservations)
+}
+
+func (r *reservationRepository) save(reservations []models.InventoryReservation) error {
+	if err := writeJSON(r.storage, r.key, reservations); err != nil {
+		// The stored data is unknown after a failed write
+		r.cache.invalidate()
+		return err
+	}
+
+	r.cache.set(reservations)
+	return nil
+}
@@ -0,0 +1,86 @@
+package dal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Storage reads and writes raw data by key.
+// The repositories encode their data as JSON on top of it, so the backend can be swapped
+// without touching the repository logic.
+type Storage interface {
+	// Read returns the data stored under the key, or nil if nothing is stored yet.
+	Read(key string) ([]byte, error)
+	// Write replaces the data stored under the key.
+	Write(key string, data []byte) error
+	// ModTime returns the time the data under the key was last written, or the zero time if nothing is stored yet.
+	ModTime(key string) (time.Time, error)
+}
+
+// Bounds of the retry when a data file can not be read or decoded, e.g. while another process replaces it
+var (
+	readAttempts = 3
+	readBackoff  = 10 * time.Millisecond
+)
+
+// readJSON decodes the data stored under the key into v.
+// Failed reads and data that can not be decoded, such as a partially written file, are retried with
+// an exponential backoff and the last error is returned once all attempts are exhausted.
+// Missing or empty data leaves v untouched.
+func readJSON(storage Storage, key string, v any) error {
+	var err error
+	for attempt := 0; attempt < readAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(readBackoff << (attempt - 1))
+		}
+
+		if err = decodeJSON(storage, key, v); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func decodeJSON(storage Storage, key string, v any) error {
+	data, err := storage.Read(key)
+	if err != nil {
+		return err
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// CheckJSON reads the data stored under the key directly from storage, bypassing the repository caches,
+// and checks that it is valid JSON. Empty data is treated as nothing stored, like repositories read it.
+// Returns false if nothing is stored under the key yet.
+func CheckJSON(storage Storage, key string) (bool, error) {
+	data, err := storage.Read(key)
+	if err != nil {
+		return false, err
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return false, nil
+	}
+
+	if !json.Valid(data) {
+		return true, errors.New("data is not valid JSON")
+	}
+	return true, nil
+}
+
+// writeJSON encodes v as indented JSON and stores it under the key.
+func writeJSON(storage Storage, key string, v any) error {
+	jsonData, err := json.MarshalIndent(v, "", " ")
+	if err != nil {
+		return err
+	}
+
+	return storage.Write(key, jsonData)
+}
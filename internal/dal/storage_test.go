@@ -0,0 +1,74 @@
+package dal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"hot-coffee/models"
+)
+
+// scriptedStorage returns the scripted reads in turn, repeating the last one.
+type scriptedStorage struct {
+	reads []scriptedRead
+	calls int
+}
+
+type scriptedRead struct {
+	data string
+	err  error
+}
+
+func (s *scriptedStorage) Read(key string) ([]byte, error) {
+	read := s.reads[min(s.calls, len(s.reads)-1)]
+	s.calls++
+	if read.err != nil {
+		return nil, read.err
+	}
+	return []byte(read.data), nil
+}
+
+func (s *scriptedStorage) Write(key string, data []byte) error   { return nil }
+func (s *scriptedStorage) ModTime(key string) (time.Time, error) { return time.Time{}, nil }
+
+func TestReadJSON(t *testing.T) {
+	defer func(backoff time.Duration) { readBackoff = backoff }(readBackoff)
+	readBackoff = time.Microsecond
+
+	const stored = `[{"ingredient_id":"milk","name":"Milk","quantity":1000,"unit":"ml"}]`
+	errRead := errors.New("read failed")
+
+	tests := []struct {
+		name      string
+		reads     []scriptedRead
+		wantItems int
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "valid", reads: []scriptedRead{{data: stored}}, wantItems: 1, wantCalls: 1},
+		{name: "missing", reads: []scriptedRead{{data: ""}}, wantItems: 0, wantCalls: 1},
+		{name: "blank", reads: []scriptedRead{{data: " \n"}}, wantItems: 0, wantCalls: 1},
+		{name: "partially written then complete", reads: []scriptedRead{{data: stored[:20]}, {data: stored}}, wantItems: 1, wantCalls: 2},
+		{name: "read error then complete", reads: []scriptedRead{{err: errRead}, {err: errRead}, {data: stored}}, wantItems: 1, wantCalls: 3},
+		{name: "always corrupt", reads: []scriptedRead{{data: "{"}}, wantCalls: readAttempts, wantErr: true},
+		{name: "always failing", reads: []scriptedRead{{err: errRead}}, wantCalls: readAttempts, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &scriptedStorage{reads: tt.reads}
+
+			items := []models.InventoryItem{}
+			err := readJSON(storage, "inventory", &items)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readJSON() error = %v, want error %v", err, tt.wantErr)
+			}
+			if storage.calls != tt.wantCalls {
+				t.Errorf("storage was read %d times, want %d", storage.calls, tt.wantCalls)
+			}
+			if !tt.wantErr && len(items) != tt.wantItems {
+				t.Errorf("decoded %d items, want %d", len(items), tt.wantItems)
+			}
+		})
+	}
+}
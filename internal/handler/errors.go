@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"hot-coffee/internal/utils"
+)
+
+// describedError replaces the message of a service error while keeping it matchable with errors.Is,
+// so the response carries the code of the service error.
+type describedError struct {
+	message string
+	err     error
+}
+
+func (e *describedError) Error() string { return e.message }
+
+func (e *describedError) Unwrap() error { return e.err }
+
+// describeError returns err with a formatted message for the response.
+func describeError(err error, format string, args ...any) error {
+	return &describedError{message: fmt.Sprintf(format, args...), err: err}
+}
+
+// requireJSONContentType writes a 415 response and returns false if the request has a content type other than JSON.
+// Requests without a content type are treated as JSON for backward compatibility.
+func requireJSONContentType(w http.ResponseWriter, r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/json" {
+		utils.WriteErrorResponse(http.StatusUnsupportedMediaType, errors.New("content type must be application/json"), w, r)
+		return false
+	}
+
+	return true
+}
+
+// decodeJSONBody decodes the JSON request body into v, reading at most maxBodySize bytes.
+// Writes a 413 response for oversized bodies, a 400 response for empty or malformed ones, and returns false
+// if the body could not be decoded.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, maxBodySize int64, v any) bool {
+	if r.Body == nil {
+		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
+		return false
+	}
+	defer r.Body.Close()
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.WriteErrorResponse(http.StatusRequestEntityTooLarge, fmt.Errorf("request body must not exceed %d bytes", maxBytesErr.Limit), w, r)
+			return false
+		}
+		if err == io.EOF {
+			utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
+			return false
+		}
+		utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSONBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       io.Reader
+		wantOK     bool
+		wantStatus int
+	}{
+		{name: "valid", body: strings.NewReader(`{"name":"latte"}`), wantOK: true, wantStatus: http.StatusOK},
+		{name: "at the limit", body: strings.NewReader(`{"name":"` + strings.Repeat("a", 21) + `"}`), wantOK: true, wantStatus: http.StatusOK},
+		{name: "oversized", body: strings.NewReader(`{"name":"` + strings.Repeat("a", 100) + `"}`), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "empty", body: strings.NewReader(""), wantStatus: http.StatusBadRequest},
+		{name: "no body", body: nil, wantStatus: http.StatusBadRequest},
+		{name: "malformed", body: strings.NewReader(`{"name":`), wantStatus: http.StatusBadRequest},
+		{name: "wrong type", body: strings.NewReader(`{"name":1}`), wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/menu", tt.body)
+			if tt.body == nil {
+				r.Body = nil
+			}
+			w := httptest.NewRecorder()
+
+			var v struct {
+				Name string `json:"name"`
+			}
+			if ok := decodeJSONBody(w, r, 32, &v); ok != tt.wantOK {
+				t.Errorf("decodeJSONBody() = %v, want %v", ok, tt.wantOK)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
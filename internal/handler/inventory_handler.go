@@ -1,11 +1,13 @@
 package handler
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"hot-coffee/internal/service"
 	"hot-coffee/internal/utils"
@@ -19,48 +21,48 @@ type InventoryHandler interface {
 	GetInventoryItem(w http.ResponseWriter, r *http.Request)
 	UpdateInventoryItem(w http.ResponseWriter, r *http.Request)
 	DeleteInventoryItem(w http.ResponseWriter, r *http.Request)
+	GetLowStockItems(w http.ResponseWriter, r *http.Request)
+	ImportInventoryItems(w http.ResponseWriter, r *http.Request)
+	AdjustInventoryItem(w http.ResponseWriter, r *http.Request)
+	GetInventoryAdjustments(w http.ResponseWriter, r *http.Request)
 }
 
 type inventoryHandler struct {
 	InventoryService service.InventoryService
 	logger           *logger.Logger
+	maxBodySize      int64
 }
 
-func NewInventoryHandler(s service.InventoryService, l *logger.Logger) *inventoryHandler {
-	return &inventoryHandler{InventoryService: s, logger: l}
+func NewInventoryHandler(s service.InventoryService, l *logger.Logger, maxBodySize int64) *inventoryHandler {
+	return &inventoryHandler{InventoryService: s, logger: l, maxBodySize: maxBodySize}
 }
 
 // AddInventoryItem handles the HTTP request to add a new inventory item.
 // It processes the incoming request, validates the input, and interacts with the service layer to add the item.
 // If successful, it returns the added item as a JSON response with a 201 status code.
 func (h *inventoryHandler) AddInventoryItem(w http.ResponseWriter, r *http.Request) {
-	if r.Body == nil {
-		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
+	if !requireJSONContentType(w, r) {
 		return
 	}
-	defer r.Body.Close()
 
 	var item models.InventoryItem
 
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&item); err != nil {
-		if err == io.EOF {
-			utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
-			return
-		}
-		utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+	if !decodeJSONBody(w, r, h.maxBodySize, &item) {
 		return
 	}
 
 	err := h.InventoryService.AddInventoryItem(item)
 	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			utils.WriteValidationErrorResponse(validationErr.Errors, w, r)
+			return
+		}
+
 		switch err {
 		case service.ErrNotUniqueID:
 			utils.WriteErrorResponse(http.StatusConflict, err, w, r)
 			return
-		case service.ErrNotValidIngredientID, service.ErrNotValidIngredientName, service.ErrNotValidQuantity, service.ErrNotValidUnit:
-			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
-			return
 		default:
 			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
 			return
@@ -70,6 +72,7 @@ func (h *inventoryHandler) AddInventoryItem(w http.ResponseWriter, r *http.Reque
 	h.logger.PrintDebugMsg("Adding new inventory item: %+v", item)
 	h.logger.PrintInfoMsg("Successfully added new inventory item: %+v", item)
 
+	w.Header().Set("Location", "/inventory/"+url.PathEscape(item.IngredientID))
 	utils.WriteJSONResponse(http.StatusCreated, item, w, r)
 }
 
@@ -78,10 +81,56 @@ func (h *inventoryHandler) AddInventoryItem(w http.ResponseWriter, r *http.Reque
 // 400 Bad Request — ошибка в запросе.
 // 500 Internal Server Error — ошибка на сервере.
 
-// GetInventoryItems handles the HTTP request to retrieve inventory items.
+// GetInventoryItems handles the HTTP request to retrieve inventory items, optionally filtered by the "category" query parameter.
 // It calls the service layer to get the list of inventory items, handles errors, and returns the data in the response.
+// Responds with 304 Not Modified if the inventory has not changed since the If-Modified-Since time.
 func (h *inventoryHandler) GetInventoryItems(w http.ResponseWriter, r *http.Request) {
-	data, err := h.InventoryService.RetrieveInventoryItems()
+	// Conditional GET for polling clients, HTTP dates have a one second precision
+	lastModified, err := h.InventoryService.InventoryLastModified()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+	if !lastModified.IsZero() {
+		lastModified = lastModified.UTC().Truncate(time.Second)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	// A list of IDs takes precedence over the category filter
+	if query := r.URL.Query(); query.Has("ids") {
+		ids := []string{}
+		for _, id := range strings.Split(query.Get("ids"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+
+		lookups, err := h.InventoryService.RetrieveInventoryItemsByIDs(ids)
+		if err != nil {
+			switch err {
+			case service.ErrEmptyIDList:
+				utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+				return
+			default:
+				utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+				return
+			}
+		}
+
+		h.logger.PrintDebugMsg("Retrieved inventory items by IDs: %v", ids)
+
+		utils.WriteJSONResponse(http.StatusOK, lookups, w, r)
+		return
+	}
+
+	category := strings.TrimSpace(r.URL.Query().Get("category"))
+
+	data, err := h.InventoryService.RetrieveInventoryItems(category)
 	if err != nil {
 		switch err {
 		default:
@@ -92,9 +141,7 @@ func (h *inventoryHandler) GetInventoryItems(w http.ResponseWriter, r *http.Requ
 
 	h.logger.PrintDebugMsg("Retrieved inventory items")
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+	utils.WriteJSONBytes(http.StatusOK, data, w, r)
 }
 
 // GetInventoryItem handles the HTTP request to retrieve a specific inventory item by its ID.
@@ -106,11 +153,11 @@ func (h *inventoryHandler) GetInventoryItem(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	data, err := h.InventoryService.RetrieveInventoryItem(itemId)
+	data, etag, err := h.InventoryService.RetrieveInventoryItem(itemId)
 	if err != nil {
 		switch err.Error() {
 		case "item not found":
-			utils.WriteErrorResponse(http.StatusNotFound, fmt.Errorf("item with id '%s' not found", itemId), w, r)
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(service.ErrNoItem, "item with id '%s' not found", itemId), w, r)
 		default:
 			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
 		}
@@ -120,24 +167,15 @@ func (h *inventoryHandler) GetInventoryItem(w http.ResponseWriter, r *http.Reque
 	h.logger.PrintDebugMsg("Retrieved inventory item with ID: %s", itemId)
 
 	// Send an HTTP status code 200 (OK) and write the retrieved item data to the response body.
-	w.Header().Set("Content-Type", "application/json")
-	_, err = w.Write(data)
-	if err != nil {
-		h.logger.PrintErrorMsg("Failed to write response: %v", err)
-
-		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
-		return
-	}
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("ETag", etag)
+	utils.WriteJSONBytes(http.StatusOK, data, w, r)
 }
 
 // UpdateInventoryItem handles the HTTP request to update an existing inventory item by its ID.
 func (h *inventoryHandler) UpdateInventoryItem(w http.ResponseWriter, r *http.Request) {
-	if r.Body == nil {
-		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
+	if !requireJSONContentType(w, r) {
 		return
 	}
-	defer r.Body.Close()
 
 	itemId := r.PathValue("id")
 	if len(itemId) == 0 {
@@ -146,29 +184,30 @@ func (h *inventoryHandler) UpdateInventoryItem(w http.ResponseWriter, r *http.Re
 	}
 
 	var item models.InventoryItem
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&item); err != nil {
-		// If the request body cannot be decoded, return a Bad Request (400) response.
-		if err == io.EOF {
-			utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
-			return
-		}
-		utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+	if !decodeJSONBody(w, r, h.maxBodySize, &item) {
 		return
 	}
 
-	err := h.InventoryService.UpdateInventoryItem(itemId, item)
+	etag, err := h.InventoryService.UpdateInventoryItem(itemId, item, r.Header.Get("If-Match"))
 	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			utils.WriteValidationErrorResponse(validationErr.Errors, w, r)
+			return
+		}
+
 		switch err {
 		case service.ErrNoItem:
-			utils.WriteErrorResponse(http.StatusNotFound, fmt.Errorf("item with id '%s' not found", itemId), w, r)
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "item with id '%s' not found", itemId), w, r)
 			return
-		case service.ErrNotUniqueID,
-			service.ErrNotValidIngredientID,
-			service.ErrNotValidIngredientName,
-			service.ErrNotValidQuantity,
-			service.ErrNotValidUnit:
-			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+		case service.ErrETagRequired:
+			utils.WriteErrorResponse(http.StatusPreconditionRequired, err, w, r)
+			return
+		case service.ErrETagMismatch:
+			utils.WriteErrorResponse(http.StatusPreconditionFailed, err, w, r)
+			return
+		case service.ErrNotUniqueID:
+			utils.WriteErrorResponse(http.StatusConflict, err, w, r)
 			return
 		default:
 			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
@@ -176,6 +215,7 @@ func (h *inventoryHandler) UpdateInventoryItem(w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	w.Header().Set("ETag", etag)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -186,11 +226,18 @@ func (h *inventoryHandler) DeleteInventoryItem(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	err := h.InventoryService.DeleteInventoryItem(itemId)
+	force := r.URL.Query().Get("force") == "true"
+
+	err := h.InventoryService.DeleteInventoryItem(itemId, force)
 	if err != nil {
+		if errors.Is(err, service.ErrInventoryItemInUse) {
+			utils.WriteErrorResponse(http.StatusConflict, err, w, r)
+			return
+		}
+
 		switch err {
 		case service.ErrNoItem:
-			utils.WriteErrorResponse(http.StatusNotFound, fmt.Errorf("item with id '%s' not found", itemId), w, r)
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "item with id '%s' not found", itemId), w, r)
 			return
 		default:
 			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
@@ -202,3 +249,119 @@ func (h *inventoryHandler) DeleteInventoryItem(w http.ResponseWriter, r *http.Re
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// AdjustInventoryItem handles the HTTP request to apply a manual stock change to an inventory item.
+// The adjustment is recorded in the audit log and returned in the response.
+func (h *inventoryHandler) AdjustInventoryItem(w http.ResponseWriter, r *http.Request) {
+	if !requireJSONContentType(w, r) {
+		return
+	}
+
+	itemId := r.PathValue("id")
+	if len(itemId) == 0 {
+		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("item id is not valid"), w, r)
+		return
+	}
+
+	var adjustmentRequest models.InventoryAdjustmentRequest
+	if !decodeJSONBody(w, r, h.maxBodySize, &adjustmentRequest) {
+		return
+	}
+
+	if adjustmentRequest.Delta == nil {
+		utils.WriteErrorResponse(http.StatusBadRequest, service.ErrNotValidDelta, w, r)
+		return
+	}
+
+	adjustment, err := h.InventoryService.AdjustInventoryItem(itemId, *adjustmentRequest.Delta, adjustmentRequest.Reason)
+	if err != nil {
+		switch err {
+		case service.ErrNoItem:
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "item with id '%s' not found", itemId), w, r)
+			return
+		case service.ErrNotValidDelta, service.ErrNotValidReason:
+			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+			return
+		case service.ErrNegativeQuantity:
+			utils.WriteErrorResponse(http.StatusConflict, err, w, r)
+			return
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+			return
+		}
+	}
+
+	h.logger.PrintInfoMsg("Adjusted inventory item %s by %g: %s", itemId, adjustment.Delta, adjustment.Reason)
+
+	utils.WriteJSONResponse(http.StatusCreated, adjustment, w, r)
+}
+
+// GetInventoryAdjustments handles the HTTP request to retrieve the adjustment history of an inventory item.
+func (h *inventoryHandler) GetInventoryAdjustments(w http.ResponseWriter, r *http.Request) {
+	itemId := r.PathValue("id")
+	if len(itemId) == 0 {
+		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("item id is not valid"), w, r)
+		return
+	}
+
+	adjustments, err := h.InventoryService.RetrieveAdjustments(itemId)
+	if err != nil {
+		switch err {
+		case service.ErrNoItem:
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "item with id '%s' not found", itemId), w, r)
+			return
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+			return
+		}
+	}
+
+	h.logger.PrintDebugMsg("Retrieved %d adjustments of inventory item %s", len(adjustments), itemId)
+
+	utils.WriteJSONResponse(http.StatusOK, adjustments, w, r)
+}
+
+// GetLowStockItems handles the HTTP request to retrieve inventory items at or below their reorder level.
+func (h *inventoryHandler) GetLowStockItems(w http.ResponseWriter, r *http.Request) {
+	items, err := h.InventoryService.GetLowStockItems()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	h.logger.PrintDebugMsg("Retrieved %d low stock inventory items", len(items))
+
+	utils.WriteJSONResponse(http.StatusOK, items, w, r)
+}
+
+// ImportInventoryItems handles the HTTP request to upsert inventory items from a text/csv body.
+// It returns a summary of inserted, updated and failed rows with per-row errors.
+func (h *inventoryHandler) ImportInventoryItems(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "text/csv" {
+		utils.WriteErrorResponse(http.StatusUnsupportedMediaType, errors.New("content type must be text/csv"), w, r)
+		return
+	}
+
+	if r.Body == nil {
+		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
+		return
+	}
+	defer r.Body.Close()
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBodySize)
+
+	summary, err := h.InventoryService.ImportInventoryCSV(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.WriteErrorResponse(http.StatusRequestEntityTooLarge, fmt.Errorf("request body must not exceed %d bytes", maxBytesErr.Limit), w, r)
+			return
+		}
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	h.logger.PrintInfoMsg("Imported inventory items: %d inserted, %d updated, %d failed", summary.Inserted, summary.Updated, summary.Failed)
+
+	utils.WriteJSONResponse(http.StatusOK, summary, w, r)
+}
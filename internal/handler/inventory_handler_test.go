@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+	"hot-coffee/pkg/logger"
+)
+
+func TestGetInventoryItemsConditional(t *testing.T) {
+	tests := []struct {
+		name  string
+		since func(lastModified time.Time) string
+		want  int
+	}{
+		{name: "no condition", since: func(time.Time) string { return "" }, want: http.StatusOK},
+		{name: "unchanged since", since: func(lastModified time.Time) string { return lastModified.Format(http.TimeFormat) }, want: http.StatusNotModified},
+		{name: "changed since", since: func(lastModified time.Time) string { return lastModified.Add(-time.Minute).Format(http.TimeFormat) }, want: http.StatusOK},
+		{name: "invalid date", since: func(time.Time) string { return "yesterday" }, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventory := dal.NewInMemoryInventoryRepository(models.InventoryItem{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml"})
+			s := service.NewInventoryService(inventory, dal.NewInMemoryMenuRepository(), dal.NewInMemoryAdjustmentRepository())
+			h := NewInventoryHandler(s, logger.New(slog.LevelError, logger.FormatText, io.Discard), 1<<20)
+
+			lastModified, err := s.InventoryLastModified()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest("GET", "/inventory", nil)
+			if since := tt.since(lastModified.UTC().Truncate(time.Second)); since != "" {
+				r.Header.Set("If-Modified-Since", since)
+			}
+			w := httptest.NewRecorder()
+			h.GetInventoryItems(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("GET /inventory status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Header().Get("Last-Modified") == "" {
+				t.Error("Last-Modified header is not set")
+			}
+			if tt.want == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 response has a body: %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestInventoryIDConflict(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{name: "add new ID", method: "POST", target: "/inventory", body: `{"ingredient_id":"cream","name":"Cream","quantity":1,"unit":"ml"}`, want: http.StatusCreated},
+		{name: "add case variant", method: "POST", target: "/inventory", body: `{"ingredient_id":"MILK","name":"Milk","quantity":1,"unit":"ml"}`, want: http.StatusConflict},
+		{name: "rename to case variant of itself", method: "PUT", target: "/inventory/milk", body: `{"ingredient_id":"Milk","name":"Milk","quantity":1,"unit":"ml"}`, want: http.StatusOK},
+		{name: "rename onto another item", method: "PUT", target: "/inventory/milk", body: `{"ingredient_id":"Sugar","name":"Sugar","quantity":1,"unit":"g"}`, want: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventory := dal.NewInMemoryInventoryRepository(
+				models.InventoryItem{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml"},
+				models.InventoryItem{IngredientID: "sugar", Name: "Sugar", Quantity: 500, Unit: "g"},
+			)
+			s := service.NewInventoryService(inventory, dal.NewInMemoryMenuRepository(), dal.NewInMemoryAdjustmentRepository())
+			h := NewInventoryHandler(s, logger.New(slog.LevelError, logger.FormatText, io.Discard), 1<<20)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /inventory", h.AddInventoryItem)
+			mux.HandleFunc("PUT /inventory/{id}", h.UpdateInventoryItem)
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("If-Match", "*")
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("%s %s status = %d, want %d: %s", tt.method, tt.target, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestHandlerInterfacesDeclareEveryRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler any
+		iface   reflect.Type
+	}{
+		{name: "inventory", handler: &inventoryHandler{}, iface: reflect.TypeFor[InventoryHandler]()},
+		{name: "menu", handler: &menuHandler{}, iface: reflect.TypeFor[MenuHandler]()},
+		{name: "order", handler: &orderHandler{}, iface: reflect.TypeFor[OrderHandler]()},
+		{name: "report", handler: &reportHandler{}, iface: reflect.TypeFor[ReportHandler]()},
+	}
+
+	routeType := reflect.TypeFor[func(http.ResponseWriter, *http.Request)]()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlerType := reflect.TypeOf(tt.handler)
+			if !handlerType.Implements(tt.iface) {
+				t.Fatalf("%s does not implement %s", handlerType, tt.iface)
+			}
+			for i := range handlerType.NumMethod() {
+				method := handlerType.Method(i)
+				// Method types of a concrete type include the receiver
+				if method.Type.NumIn() != 3 || reflect.FuncOf([]reflect.Type{method.Type.In(1), method.Type.In(2)}, nil, false) != routeType {
+					continue
+				}
+				if _, declared := tt.iface.MethodByName(method.Name); !declared {
+					t.Errorf("%s.%s is not declared in %s", handlerType, method.Name, tt.iface)
+				}
+			}
+		})
+	}
+}
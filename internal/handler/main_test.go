@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/internal/utils"
+	"hot-coffee/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	// The handlers and services log through the package logger, only errors are shown in the test output
+	logger.InitLogger(slog.LevelError, logger.FormatText)
+	// Error responses carry the codes of the service errors, as the server sets them up
+	utils.SetErrorCoder(service.ErrorCode)
+	os.Exit(m.Run())
+}
@@ -1,10 +1,7 @@
 package handler
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
 	"net/http"
 
 	"hot-coffee/internal/service"
@@ -19,35 +16,30 @@ type MenuHandler interface {
 	GetMenuItem(w http.ResponseWriter, r *http.Request)
 	UpdateMenuItem(w http.ResponseWriter, r *http.Request)
 	DeleteMenuItem(w http.ResponseWriter, r *http.Request)
+	SetMenuItemAvailability(w http.ResponseWriter, r *http.Request)
+	UpdateMenuPrices(w http.ResponseWriter, r *http.Request)
+	GetMenuIngredients(w http.ResponseWriter, r *http.Request)
 }
 
 type menuHandler struct {
 	MenuService service.MenuService
 	logger      *logger.Logger
+	maxBodySize int64
 }
 
-func NewMenuHandler(s service.MenuService, l *logger.Logger) *menuHandler {
-	return &menuHandler{MenuService: s, logger: l}
+func NewMenuHandler(s service.MenuService, l *logger.Logger, maxBodySize int64) *menuHandler {
+	return &menuHandler{MenuService: s, logger: l, maxBodySize: maxBodySize}
 }
 
 // AddMenuItem handles the HTTP request to add a new menu item.
 // It processes the request body, validates the input, and calls the service layer to add the item.
 func (h *menuHandler) AddMenuItem(w http.ResponseWriter, r *http.Request) {
-	if r.Body == nil {
-		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
+	if !requireJSONContentType(w, r) {
 		return
 	}
-	defer r.Body.Close()
 
 	var item models.MenuItem
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&item); err != nil {
-		switch err {
-		case io.EOF:
-			utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
-		default:
-			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
-		}
+	if !decodeJSONBody(w, r, h.maxBodySize, &item) {
 		return
 	}
 
@@ -55,6 +47,13 @@ func (h *menuHandler) AddMenuItem(w http.ResponseWriter, r *http.Request) {
 
 	err := h.MenuService.AddMenuItem(item)
 	if err != nil {
+		if errors.Is(err, service.ErrNotValidRecipeQuantity) ||
+			errors.Is(err, service.ErrComboComponentNotFound) ||
+			errors.Is(err, service.ErrComboCycle) {
+			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+			return
+		}
+
 		switch err {
 		case service.ErrNotUniqueMenuID:
 			utils.WriteErrorResponse(http.StatusConflict, err, w, r)
@@ -64,9 +63,12 @@ func (h *menuHandler) AddMenuItem(w http.ResponseWriter, r *http.Request) {
 			service.ErrNotValidMenuDescription,
 			service.ErrNotValidPrice,
 			service.ErrNotValidIngredientID,
-			service.ErrNotValidQuantity,
 			service.ErrDuplicateMenuIngredients,
-			service.ErrNotValidIngredints:
+			service.ErrNotValidMenuSize,
+			service.ErrNotValidComboComponent,
+			service.ErrNotValidComboSizes,
+			service.ErrNotValidIngredints,
+			service.ErrIncompatibleUnit:
 			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
 			return
 		default:
@@ -94,9 +96,21 @@ func (h *menuHandler) GetMenuItems(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.PrintDebugMsg("Retrieved Menu items")
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+	utils.WriteJSONBytes(http.StatusOK, data, w, r)
+}
+
+// GetMenuIngredients handles the HTTP request to list the ingredients used across the menu
+// with the number of menu items using each, most used first.
+func (h *menuHandler) GetMenuIngredients(w http.ResponseWriter, r *http.Request) {
+	usages, err := h.MenuService.ListIngredientUsage()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	h.logger.PrintDebugMsg("Retrieved usage of %d menu ingredients", len(usages))
+
+	utils.WriteJSONResponse(http.StatusOK, usages, w, r)
 }
 
 // GetMenuItem handles the HTTP request to retrieve a specific menu item by its ID.
@@ -114,7 +128,7 @@ func (h *menuHandler) GetMenuItem(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		switch err {
 		case service.ErrNoItem:
-			utils.WriteErrorResponse(http.StatusNotFound, fmt.Errorf("item with id '%s' not found", itemId), w, r)
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "item with id '%s' not found", itemId), w, r)
 			return
 		default:
 			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
@@ -124,12 +138,7 @@ func (h *menuHandler) GetMenuItem(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.PrintDebugMsg("Retrieved menu item with ID: %s", itemId)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(data)
-	if err != nil {
-		h.logger.PrintErrorMsg("Failed to write response: %v", err)
-	}
+	utils.WriteJSONBytes(http.StatusOK, data, w, r)
 }
 
 // UpdateMenuItem handles the HTTP request to update an existing menu item.
@@ -137,11 +146,9 @@ func (h *menuHandler) GetMenuItem(w http.ResponseWriter, r *http.Request) {
 // calls the service layer to update the menu item. In case of errors, it responds
 // with the appropriate HTTP status and error message.
 func (h *menuHandler) UpdateMenuItem(w http.ResponseWriter, r *http.Request) {
-	if r.Body == nil {
-		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
+	if !requireJSONContentType(w, r) {
 		return
 	}
-	defer r.Body.Close()
 
 	itemId := r.PathValue("id")
 	if len(itemId) == 0 {
@@ -150,17 +157,26 @@ func (h *menuHandler) UpdateMenuItem(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.MenuItem
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&item); err != nil {
-		utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+	if !decodeJSONBody(w, r, h.maxBodySize, &item) {
 		return
 	}
 
 	err := h.MenuService.UpdateMenuItem(itemId, item)
 	if err != nil {
+		if errors.Is(err, service.ErrNotValidRecipeQuantity) ||
+			errors.Is(err, service.ErrComboComponentNotFound) ||
+			errors.Is(err, service.ErrComboCycle) {
+			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+			return
+		}
+		if errors.Is(err, service.ErrMenuItemInCombo) {
+			utils.WriteErrorResponse(http.StatusConflict, err, w, r)
+			return
+		}
+
 		switch err {
 		case service.ErrNoItem:
-			utils.WriteErrorResponse(http.StatusNotFound, fmt.Errorf("item with id '%s' not found", itemId), w, r)
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "item with id '%s' not found", itemId), w, r)
 			return
 		case service.ErrNotUniqueMenuID,
 			service.ErrNotValidMenuID,
@@ -168,8 +184,11 @@ func (h *menuHandler) UpdateMenuItem(w http.ResponseWriter, r *http.Request) {
 			service.ErrNotValidMenuDescription,
 			service.ErrNotValidPrice,
 			service.ErrNotValidIngredientID,
-			service.ErrNotValidQuantity,
-			service.ErrDuplicateMenuIngredients:
+			service.ErrDuplicateMenuIngredients,
+			service.ErrNotValidMenuSize,
+			service.ErrNotValidComboComponent,
+			service.ErrNotValidComboSizes,
+			service.ErrIncompatibleUnit:
 			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
 			return
 		default:
@@ -193,9 +212,14 @@ func (h *menuHandler) DeleteMenuItem(w http.ResponseWriter, r *http.Request) {
 
 	err := h.MenuService.DeleteMenuItem(itemId)
 	if err != nil {
+		if errors.Is(err, service.ErrMenuItemInUse) || errors.Is(err, service.ErrMenuItemInCombo) {
+			utils.WriteErrorResponse(http.StatusConflict, err, w, r)
+			return
+		}
+
 		switch err {
 		case service.ErrNoItem:
-			utils.WriteErrorResponse(http.StatusNotFound, fmt.Errorf("item with id '%s' not found", itemId), w, r)
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "item with id '%s' not found", itemId), w, r)
 			return
 		default:
 			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
@@ -207,3 +231,73 @@ func (h *menuHandler) DeleteMenuItem(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// SetMenuItemAvailability handles the HTTP request to mark a menu item as available or unavailable.
+// It expects a body like {"available": false}.
+func (h *menuHandler) SetMenuItemAvailability(w http.ResponseWriter, r *http.Request) {
+	if !requireJSONContentType(w, r) {
+		return
+	}
+
+	itemId := r.PathValue("id")
+	if len(itemId) == 0 {
+		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("identificator is not valid"), w, r)
+		return
+	}
+
+	var availability models.MenuItemAvailability
+	if !decodeJSONBody(w, r, h.maxBodySize, &availability) {
+		return
+	}
+
+	if availability.Available == nil {
+		utils.WriteErrorResponse(http.StatusBadRequest, service.ErrNotValidAvailability, w, r)
+		return
+	}
+
+	err := h.MenuService.SetMenuItemAvailability(itemId, *availability.Available)
+	if err != nil {
+		switch err {
+		case service.ErrNoItem:
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "item with id '%s' not found", itemId), w, r)
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		}
+		return
+	}
+
+	h.logger.PrintInfoMsg("Menu item with ID: %s availability set to %t", itemId, *availability.Available)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// UpdateMenuPrices handles the HTTP request to change the prices of several menu items at once.
+// Responds with the per-item results, with 400 and no changes applied if any item was rejected.
+func (h *menuHandler) UpdateMenuPrices(w http.ResponseWriter, r *http.Request) {
+	if !requireJSONContentType(w, r) {
+		return
+	}
+
+	var updates []models.MenuPriceUpdate
+	if !decodeJSONBody(w, r, h.maxBodySize, &updates) {
+		return
+	}
+
+	results, err := h.MenuService.UpdateMenuPrices(updates)
+	if err != nil {
+		switch err {
+		case service.ErrPriceUpdateRejected:
+			h.logger.PrintDebugMsg("Rejected menu price update of %d items", len(updates))
+			utils.WriteJSONResponse(http.StatusBadRequest, models.MenuPriceUpdateResponse{Updated: false, Results: results}, w, r)
+		case service.ErrEmptyPriceUpdate:
+			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		}
+		return
+	}
+
+	h.logger.PrintInfoMsg("Updated prices of %d menu items", len(results))
+
+	utils.WriteJSONResponse(http.StatusOK, models.MenuPriceUpdateResponse{Updated: true, Results: results}, w, r)
+}
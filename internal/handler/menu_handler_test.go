@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+	"hot-coffee/pkg/logger"
+)
+
+func TestUpdateMenuPricesBody(t *testing.T) {
+	oversized := `[` + strings.Repeat(`{"id":"latte","price":4},`, 1<<15) + `{"id":"latte","price":4}]`
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "valid", body: `[{"id":"latte","price":4}]`, want: http.StatusOK},
+		{name: "oversized", body: oversized, want: http.StatusRequestEntityTooLarge},
+		{name: "empty", body: "", want: http.StatusBadRequest},
+		{name: "malformed", body: `[{"id":`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			menu := dal.NewInMemoryMenuRepository(models.MenuItem{ID: "latte", Name: "Latte", Price: 3.5, Available: true})
+			s := service.NewMenuService(menu, dal.NewInMemoryInventoryRepository(), dal.NewInMemoryOrderRepository())
+			h := NewMenuHandler(s, logger.New(slog.LevelError, logger.FormatText, io.Discard), 1<<16)
+
+			w := httptest.NewRecorder()
+			h.UpdateMenuPrices(w, httptest.NewRequest("POST", "/menu/prices", strings.NewReader(tt.body)))
+			if w.Code != tt.want {
+				t.Errorf("POST /menu/prices status = %d, want %d: %.200s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
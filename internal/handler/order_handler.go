@@ -1,11 +1,16 @@
 package handler
 
 import (
-	"encoding/json"
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"hot-coffee/internal/service"
 	"hot-coffee/internal/utils"
@@ -16,61 +21,75 @@ import (
 type OrderHandler interface {
 	CreateOrder(w http.ResponseWriter, r *http.Request)
 	RetrieveOrders(w http.ResponseWriter, r *http.Request)
+	RetrieveOrderQueue(w http.ResponseWriter, r *http.Request)
+	GetAffectedOrders(w http.ResponseWriter, r *http.Request)
+	GetAvailableMenu(w http.ResponseWriter, r *http.Request)
 	RetrieveOrder(w http.ResponseWriter, r *http.Request)
 	UpdateOrder(w http.ResponseWriter, r *http.Request)
+	PatchOrder(w http.ResponseWriter, r *http.Request)
 	DeleteOrder(w http.ResponseWriter, r *http.Request)
+	RetrieveOrderHistory(w http.ResponseWriter, r *http.Request)
+	ReserveInventory(w http.ResponseWriter, r *http.Request)
+	ReleaseInventory(w http.ResponseWriter, r *http.Request)
 	CloseOrder(w http.ResponseWriter, r *http.Request)
+	CloseAllOrders(w http.ResponseWriter, r *http.Request)
+	CheckOrder(w http.ResponseWriter, r *http.Request)
+	GetRequirements(w http.ResponseWriter, r *http.Request)
+	ExportOrders(w http.ResponseWriter, r *http.Request)
 }
 
 type orderHandler struct {
 	OrderService service.OrderService
 	logger       *logger.Logger
+	maxBodySize  int64
 }
 
-func NewOrderHandler(s service.OrderService, l *logger.Logger) *orderHandler {
-	return &orderHandler{OrderService: s, logger: l}
+func NewOrderHandler(s service.OrderService, l *logger.Logger, maxBodySize int64) *orderHandler {
+	return &orderHandler{OrderService: s, logger: l, maxBodySize: maxBodySize}
 }
 
 func (h *orderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
-	if r.Body == nil {
-		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
+	if !requireJSONContentType(w, r) {
 		return
 	}
-	defer r.Body.Close()
 
 	var order models.Order
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&order); err != nil {
-		if err == io.EOF {
-			utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
-			return
-		}
-		utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+	if !decodeJSONBody(w, r, h.maxBodySize, &order) {
 		return
 	}
 
 	h.logger.PrintDebugMsg("Creating new order: %+v", order)
 
-	err := h.OrderService.AddOrder(order)
+	createdOrder, err := h.OrderService.WithActor(requestActor(r)).AddOrder(order)
 	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			utils.WriteValidationErrorResponse(validationErr.Errors, w, r)
+			return
+		}
+
 		switch err {
-		case service.ErrNotUniqueOrder:
+		case service.ErrNotUniqueOrder,
+			service.ErrProductUnavailable:
 			utils.WriteErrorResponse(http.StatusConflict, err, w, r)
 			return
 		case service.ErrNotValidOrderID,
 			service.ErrNotValidOrderCustomerName,
 			service.ErrNotValidStatusField,
 			service.ErrNotValidCreatedAt,
+			service.ErrNotValidOrderNotes,
+			service.ErrNotValidItemInstructions,
 			service.ErrNotValidOrderItems,
-			service.ErrNotValidIngredientID,
+			service.ErrNotValidOrderProductID,
 			service.ErrDuplicateOrderItems,
 			service.ErrNotValidQuantity,
-			service.ErrNotValidOrderProductID,
 			service.ErrNotEnoughInventoryQuantity:
 			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
 			return
 		case service.ErrOrderProductNotFound,
-			service.ErrInventoryItemNotFound:
+			service.ErrOrderSizeNotFound,
+			service.ErrInventoryItemNotFound,
+			service.ErrIncompatibleUnit:
 			utils.WriteErrorResponse(http.StatusUnprocessableEntity, err, w, r)
 			return
 		default:
@@ -79,27 +98,101 @@ func (h *orderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	h.logger.PrintInfoMsg("Successfully created new order: %+v", order)
+	h.logger.PrintInfoMsg("Successfully created new order: %+v", createdOrder)
 
-	utils.WriteJSONResponse(http.StatusCreated, order, w, r)
+	w.Header().Set("Location", "/orders/"+url.PathEscape(createdOrder.ID))
+	utils.WriteJSONResponse(http.StatusCreated, createdOrder, w, r)
 }
 
+// RetrieveOrders handles the HTTP request to list orders, optionally filtered by the "status",
+// "product", "tag" and "since" query parameters. The filters can be combined.
+// The "sort" and "order" parameters choose the sort field and direction, newest first by default.
 func (h *orderHandler) RetrieveOrders(w http.ResponseWriter, r *http.Request) {
+	filter := service.OrderFilter{
+		Status:    models.OrderStatus(r.URL.Query().Get("status")),
+		ProductID: r.URL.Query().Get("product"),
+		Tag:       r.URL.Query().Get("tag"),
+		Sort:      r.URL.Query().Get("sort"),
+		Order:     r.URL.Query().Get("order"),
+	}
+
+	// since is a Go duration, e.g. 15m, counted back from now
+	if value := r.URL.Query().Get("since"); value != "" {
+		since, err := time.ParseDuration(value)
+		if err != nil || since <= 0 {
+			utils.WriteErrorResponse(http.StatusBadRequest, fmt.Errorf("'since' must be a positive duration such as 15m: %s", value), w, r)
+			return
+		}
+		filter.CreatedSince = time.Now().Add(-since)
+	}
+
 	// Retrieve the orders from the service layer
-	data, err := h.OrderService.RetrieveOrders()
+	orders, err := h.OrderService.RetrieveOrders(filter)
 	if err != nil {
 		switch err {
+		case service.ErrUnknownStatus, service.ErrProductNotFound, service.ErrNotValidSortField, service.ErrNotValidSortOrder:
+			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+			return
 		default:
 			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
 			return
 		}
 	}
 
-	h.logger.PrintDebugMsg("Retrieved orders")
+	h.logger.PrintDebugMsg("Retrieved %d orders", len(orders))
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+	utils.WriteJSONArrayStream(http.StatusOK, orders, w, r)
+}
+
+// RetrieveOrderQueue handles the HTTP request to list the open orders by priority, then by creation time.
+func (h *orderHandler) RetrieveOrderQueue(w http.ResponseWriter, r *http.Request) {
+	orders, err := h.OrderService.RetrieveOrderQueue()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	h.logger.PrintDebugMsg("Retrieved %d queued orders", len(orders))
+
+	utils.WriteJSONArrayStream(http.StatusOK, orders, w, r)
+}
+
+// GetAffectedOrders handles the HTTP request to retrieve the open orders that depend on an inventory item.
+func (h *orderHandler) GetAffectedOrders(w http.ResponseWriter, r *http.Request) {
+	itemId := r.PathValue("id")
+	if len(itemId) == 0 {
+		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("item id is not valid"), w, r)
+		return
+	}
+
+	orders, err := h.OrderService.RetrieveOrdersUsingIngredient(itemId)
+	if err != nil {
+		switch err {
+		case service.ErrNoItem:
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "item with id '%s' not found", itemId), w, r)
+			return
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+			return
+		}
+	}
+
+	h.logger.PrintDebugMsg("Retrieved %d open orders using inventory item %s", len(orders), itemId)
+
+	utils.WriteJSONArrayStream(http.StatusOK, orders, w, r)
+}
+
+// GetAvailableMenu handles the HTTP request to list the menu items the inventory can make right now.
+func (h *orderHandler) GetAvailableMenu(w http.ResponseWriter, r *http.Request) {
+	menuItems, err := h.OrderService.RetrieveAvailableMenu()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	h.logger.PrintDebugMsg("Retrieved %d available menu items", len(menuItems))
+
+	utils.WriteJSONArrayStream(http.StatusOK, menuItems, w, r)
 }
 
 func (h *orderHandler) RetrieveOrder(w http.ResponseWriter, r *http.Request) {
@@ -113,7 +206,7 @@ func (h *orderHandler) RetrieveOrder(w http.ResponseWriter, r *http.Request) {
 	data, err := h.OrderService.RetrieveOrder(orderId)
 	if err != nil {
 		if err.Error() == "order not found" {
-			utils.WriteErrorResponse(http.StatusNotFound, fmt.Errorf("order with id '%s' not found", orderId), w, r)
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(service.ErrNoOrder, "order with id '%s' not found", orderId), w, r)
 			return
 		} else {
 			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
@@ -123,22 +216,13 @@ func (h *orderHandler) RetrieveOrder(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.PrintDebugMsg("Retrieved order with ID: %s", orderId)
 
-	w.Header().Set("Content-Type", "application/json")
-	_, err = w.Write(data)
-	if err != nil {
-		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
-		h.logger.PrintErrorMsg("Failed to write response: %v", err)
-		return
-	}
-	w.WriteHeader(http.StatusOK)
+	utils.WriteJSONBytes(http.StatusOK, data, w, r)
 }
 
 func (h *orderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
-	if r.Body == nil {
-		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("request body can not be empty"), w, r)
+	if !requireJSONContentType(w, r) {
 		return
 	}
-	defer r.Body.Close()
 
 	orderId := r.PathValue("id")
 	if len(orderId) == 0 {
@@ -147,30 +231,44 @@ func (h *orderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var order models.Order
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&order); err != nil {
-		utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+	if !decodeJSONBody(w, r, h.maxBodySize, &order) {
 		return
 	}
 
-	err := h.OrderService.UpdateOrder(orderId, order)
+	err := h.OrderService.WithActor(requestActor(r)).UpdateOrder(orderId, order)
 	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			utils.WriteValidationErrorResponse(validationErr.Errors, w, r)
+			return
+		}
+
 		switch err {
-		case service.ErrNoItem:
-			utils.WriteErrorResponse(http.StatusNotFound, fmt.Errorf("order with id '%s' not found", orderId), w, r)
+		case service.ErrNoItem, service.ErrNoOrder:
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "order with id '%s' not found", orderId), w, r)
+			return
+		case service.ErrOrderClosed, service.ErrNotUniqueOrder:
+			utils.WriteErrorResponse(http.StatusConflict, err, w, r)
+			return
+		case service.ErrUnknownStatus, service.ErrMalformedCreatedAt, service.ErrFutureCreatedAt:
+			// The stored order is corrupted, it can not be edited until the data file is fixed
+			utils.WriteErrorResponse(http.StatusConflict, describeError(err, "stored order with id '%s' is invalid: %v", orderId, err), w, r)
 			return
 		case service.ErrNotValidOrderID,
 			service.ErrNotValidOrderCustomerName,
 			service.ErrNotValidStatusField,
 			service.ErrNotValidCreatedAt,
+			service.ErrNotValidOrderNotes,
+			service.ErrNotValidItemInstructions,
 			service.ErrNotValidOrderItems,
-			service.ErrNotValidIngredientID,
+			service.ErrNotValidOrderProductID,
 			service.ErrDuplicateOrderItems,
 			service.ErrNotValidQuantity,
-			service.ErrNotValidOrderProductID,
 			service.ErrOrderProductNotFound,
+			service.ErrOrderSizeNotFound,
 			service.ErrNotEnoughInventoryQuantity,
-			service.ErrInventoryItemNotFound:
+			service.ErrInventoryItemNotFound,
+			service.ErrIncompatibleUnit:
 			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
 			return
 		default:
@@ -182,6 +280,55 @@ func (h *orderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// PatchOrder handles the HTTP request to partially update an open order.
+// Only the customer name and notes can be changed, the updated order is returned.
+func (h *orderHandler) PatchOrder(w http.ResponseWriter, r *http.Request) {
+	if !requireJSONContentType(w, r) {
+		return
+	}
+
+	orderId := r.PathValue("id")
+	if len(orderId) == 0 {
+		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("identificator is not valid"), w, r)
+		return
+	}
+
+	var patch models.OrderPatch
+	if !decodeJSONBody(w, r, h.maxBodySize, &patch) {
+		return
+	}
+
+	order, err := h.OrderService.WithActor(requestActor(r)).PatchOrder(orderId, patch)
+	if err != nil {
+		switch err {
+		case service.ErrNoOrder:
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "order with id '%s' not found", orderId), w, r)
+			return
+		case service.ErrOrderClosed:
+			utils.WriteErrorResponse(http.StatusConflict, err, w, r)
+			return
+		case service.ErrUnknownStatus:
+			utils.WriteErrorResponse(http.StatusConflict, describeError(err, "stored order with id '%s' is invalid: %v", orderId, err), w, r)
+			return
+		case service.ErrNotValidOrderIDField,
+			service.ErrNotValidStatusField,
+			service.ErrNotValidCreatedAt,
+			service.ErrEmptyOrderPatch,
+			service.ErrNotValidOrderCustomerName,
+			service.ErrNotValidOrderNotes:
+			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+			return
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+			return
+		}
+	}
+
+	h.logger.PrintDebugMsg("Order with ID: %s successfully patched", orderId)
+
+	utils.WriteJSONResponse(http.StatusOK, order, w, r)
+}
+
 func (h *orderHandler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
 	orderId := r.PathValue("id")
 
@@ -190,10 +337,14 @@ func (h *orderHandler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.OrderService.DeleteOrder(orderId)
+	err := h.OrderService.WithActor(requestActor(r)).DeleteOrder(orderId)
 	if err != nil {
-		if err.Error() == "order not found" {
-			utils.WriteErrorResponse(http.StatusNotFound, fmt.Errorf("order with id '%s' not found", orderId), w, r)
+		switch err {
+		case service.ErrNoOrder:
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "order with id '%s' not found", orderId), w, r)
+			return
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
 			return
 		}
 	}
@@ -203,8 +354,99 @@ func (h *orderHandler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// RetrieveOrderHistory handles the HTTP request to retrieve the audit trail of an order.
+func (h *orderHandler) RetrieveOrderHistory(w http.ResponseWriter, r *http.Request) {
+	orderId := r.PathValue("id")
+	if len(orderId) == 0 {
+		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("order id is not valid"), w, r)
+		return
+	}
+
+	entries, err := h.OrderService.RetrieveOrderHistory(orderId)
+	if err != nil {
+		switch err {
+		case service.ErrNoOrder:
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "order with id '%s' not found", orderId), w, r)
+			return
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+			return
+		}
+	}
+
+	h.logger.PrintDebugMsg("Retrieved %d history entries of order %s", len(entries), orderId)
+
+	utils.WriteJSONResponse(http.StatusOK, entries, w, r)
+}
+
+// ReserveInventory handles the HTTP request to hold the ingredients of an open order in the inventory.
+func (h *orderHandler) ReserveInventory(w http.ResponseWriter, r *http.Request) {
+	orderId := r.PathValue("id")
+	if len(orderId) == 0 {
+		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("order id is not valid"), w, r)
+		return
+	}
+
+	reservation, err := h.OrderService.ReserveInventory(orderId)
+	if err != nil {
+		switch err {
+		case service.ErrNoOrder:
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "order with id '%s' not found", orderId), w, r)
+			return
+		case service.ErrOrderClosed, service.ErrNotEnoughInventoryQuantity:
+			utils.WriteErrorResponse(http.StatusConflict, err, w, r)
+			return
+		case service.ErrUnknownStatus:
+			utils.WriteErrorResponse(http.StatusConflict, describeError(err, "stored order with id '%s' is invalid: %v", orderId, err), w, r)
+			return
+		case service.ErrOrderProductNotFound,
+			service.ErrOrderSizeNotFound,
+			service.ErrInventoryItemNotFound,
+			service.ErrIncompatibleUnit:
+			utils.WriteErrorResponse(http.StatusUnprocessableEntity, err, w, r)
+			return
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+			return
+		}
+	}
+
+	h.logger.PrintDebugMsg("Reserved %d ingredients for order %s", len(reservation.Ingredients), orderId)
+
+	utils.WriteJSONResponse(http.StatusOK, reservation, w, r)
+}
+
+// ReleaseInventory handles the HTTP request to drop the inventory reservation of an order.
+func (h *orderHandler) ReleaseInventory(w http.ResponseWriter, r *http.Request) {
+	orderId := r.PathValue("id")
+	if len(orderId) == 0 {
+		utils.WriteErrorResponse(http.StatusBadRequest, errors.New("order id is not valid"), w, r)
+		return
+	}
+
+	err := h.OrderService.ReleaseInventory(orderId)
+	if err != nil {
+		switch err {
+		case service.ErrNoOrder:
+			utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "order with id '%s' not found", orderId), w, r)
+			return
+		case service.ErrNoReservation:
+			utils.WriteErrorResponse(http.StatusNotFound, err, w, r)
+			return
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+			return
+		}
+	}
+
+	h.logger.PrintDebugMsg("Released the inventory reservation of order %s", orderId)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CloseOrder handles the HTTP request to close an order and deduct its ingredients from the inventory.
+// With "partial=true" only the items the inventory can fulfill are closed, the rest is moved to a new order.
 func (h *orderHandler) CloseOrder(w http.ResponseWriter, r *http.Request) {
-	// TODO: implement logic to Close an order by ID.
 	orderId := r.PathValue("id")
 
 	if len(orderId) == 0 {
@@ -212,13 +454,224 @@ func (h *orderHandler) CloseOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.OrderService.CloseOrder(orderId)
+	// Partial close fulfills what the inventory allows and reports the order left open for the rest
+	if r.URL.Query().Get("partial") == "true" {
+		result, err := h.OrderService.WithActor(requestActor(r)).ClosePartialOrder(orderId)
+		if err != nil {
+			h.writeCloseError(err, orderId, w, r)
+			return
+		}
+
+		h.logger.PrintDebugMsg("order with ID: %s partially closed, remaining order: %q", orderId, result.RemainingOrderID)
+
+		utils.WriteJSONResponse(http.StatusOK, result, w, r)
+		return
+	}
+
+	err := h.OrderService.WithActor(requestActor(r)).CloseOrder(orderId)
+	if err != nil {
+		h.writeCloseError(err, orderId, w, r)
+		return
+	}
+
+	h.logger.PrintDebugMsg("order with ID: %s successfully closed", orderId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// CloseAllOrders handles the HTTP request to close every open order the inventory can fulfill,
+// reporting for each open order whether it was closed.
+func (h *orderHandler) CloseAllOrders(w http.ResponseWriter, r *http.Request) {
+	result, err := h.OrderService.WithActor(requestActor(r)).CloseAllOrders()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	h.logger.PrintDebugMsg("bulk close: %d orders closed, %d insufficient, %d failed", result.Closed, result.Insufficient, result.Failed)
+
+	utils.WriteJSONResponse(http.StatusOK, result, w, r)
+}
+
+// writeCloseError maps the errors of closing an order to the response status.
+func (h *orderHandler) writeCloseError(err error, orderId string, w http.ResponseWriter, r *http.Request) {
+	switch err {
+	case service.ErrNoOrder:
+		utils.WriteErrorResponse(http.StatusNotFound, describeError(err, "order with id '%s' not found", orderId), w, r)
+	case service.ErrOrderAlreadyClosed:
+		utils.WriteErrorResponse(http.StatusConflict, err, w, r)
+	case service.ErrUnknownStatus:
+		utils.WriteErrorResponse(http.StatusConflict, describeError(err, "stored order with id '%s' is invalid: %v", orderId, err), w, r)
+	case service.ErrNotEnoughInventoryQuantity:
+		utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+	case service.ErrOrderProductNotFound,
+		service.ErrOrderSizeNotFound,
+		service.ErrInventoryItemNotFound,
+		service.ErrIncompatibleUnit:
+		utils.WriteErrorResponse(http.StatusUnprocessableEntity, err, w, r)
+	default:
+		// Storage failures, including failed rollbacks of the close, are server faults
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+	}
+}
+
+// CheckOrder handles the HTTP request to check whether the inventory can fulfill an order.
+// The order is not saved and the inventory is not changed.
+func (h *orderHandler) CheckOrder(w http.ResponseWriter, r *http.Request) {
+	if !requireJSONContentType(w, r) {
+		return
+	}
+
+	var order models.Order
+	if !decodeJSONBody(w, r, h.maxBodySize, &order) {
+		return
+	}
+
+	check, err := h.OrderService.CheckOrder(order)
 	if err != nil {
 		switch err {
+		case service.ErrNotValidOrderItems,
+			service.ErrNotValidOrderProductID,
+			service.ErrDuplicateOrderItems,
+			service.ErrNotValidQuantity,
+			service.ErrNotValidItemInstructions,
+			service.ErrFractionalQuantity:
+			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+			return
+		case service.ErrOrderProductNotFound,
+			service.ErrOrderSizeNotFound,
+			service.ErrInventoryItemNotFound,
+			service.ErrIncompatibleUnit:
+			utils.WriteErrorResponse(http.StatusUnprocessableEntity, err, w, r)
+			return
 		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+			return
+		}
+	}
+
+	h.logger.PrintDebugMsg("Checked inventory for order: %+v", check)
+
+	utils.WriteJSONResponse(http.StatusOK, check, w, r)
+}
+
+// GetRequirements handles the HTTP request to build a prep list of the ingredients needed by a set of orders
+// or order items, marking the ingredients that are short against the current stock.
+func (h *orderHandler) GetRequirements(w http.ResponseWriter, r *http.Request) {
+	if !requireJSONContentType(w, r) {
+		return
+	}
+
+	var request models.RequirementsRequest
+	if !decodeJSONBody(w, r, h.maxBodySize, &request) {
+		return
+	}
+
+	requirements, err := h.OrderService.CalculateRequirements(request)
+	if err != nil {
+		switch err {
+		case service.ErrNoOrder:
+			utils.WriteErrorResponse(http.StatusNotFound, err, w, r)
+			return
+		case service.ErrNotValidOrderItems,
+			service.ErrNotValidOrderProductID,
+			service.ErrDuplicateOrderItems,
+			service.ErrNotValidQuantity,
+			service.ErrNotValidItemInstructions,
+			service.ErrFractionalQuantity:
 			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+			return
+		case service.ErrOrderProductNotFound,
+			service.ErrOrderSizeNotFound,
+			service.ErrInventoryItemNotFound,
+			service.ErrIncompatibleUnit:
+			utils.WriteErrorResponse(http.StatusUnprocessableEntity, err, w, r)
+			return
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+			return
 		}
 	}
 
+	h.logger.PrintDebugMsg("Calculated requirements for %d ingredients", len(requirements.Ingredients))
+
+	utils.WriteJSONResponse(http.StatusOK, requirements, w, r)
+}
+
+// ExportOrders handles the HTTP request to download all orders with their total prices.
+// The format query parameter selects "json" (default) or "csv".
+func (h *orderHandler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		utils.WriteErrorResponse(http.StatusBadRequest, fmt.Errorf("format '%s' is not supported, use 'json' or 'csv'", format), w, r)
+		return
+	}
+
+	exports, err := h.OrderService.ExportOrders()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	h.logger.PrintDebugMsg("Exporting %d orders as %s", len(exports), format)
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"orders.%s\"", format))
+	if format == "json" {
+		utils.WriteJSONResponse(http.StatusOK, exports, w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
 	w.WriteHeader(http.StatusOK)
+
+	if err := writeOrdersCSV(w, exports); err != nil {
+		h.logger.PrintErrorMsg("Failed to write orders export: %v", err)
+	}
+}
+
+// writeOrdersCSV writes the header row and one row per order export,
+// stopping at the first row that can not be written.
+func writeOrdersCSV(w io.Writer, exports []models.OrderExport) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"order_id", "customer_name", "status", "created_at", "total_price"}); err != nil {
+		return err
+	}
+	for _, export := range exports {
+		err := writer.Write([]string{
+			export.ID,
+			export.CustomerName,
+			export.Status,
+			export.CreatedAt,
+			strconv.FormatFloat(export.TotalPrice, 'f', 2, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// maxActorLength limits the X-Actor header recorded on the order history
+const maxActorLength = 64
+
+// requestActor returns who makes the request: the X-Actor header, or the client address when it is not set.
+func requestActor(r *http.Request) string {
+	if actor := strings.TrimSpace(r.Header.Get("X-Actor")); actor != "" {
+		if len(actor) > maxActorLength {
+			// Drops a rune cut in half by the limit
+			actor = strings.ToValidUTF8(actor[:maxActorLength], "")
+		}
+		return actor
+	}
+
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return client
 }
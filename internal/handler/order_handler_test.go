@@ -0,0 +1,413 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+	"hot-coffee/pkg/logger"
+)
+
+// newTestOrderHandler creates a handler over in-memory repositories holding the orders,
+// with a menu of lattes made from milk and espresso.
+func newTestOrderHandler(orders ...models.Order) *orderHandler {
+	return newTestOrderHandlerWithRepository(dal.NewInMemoryOrderRepository(orders...))
+}
+
+// newTestOrderHandlerWithRepository creates the handler of newTestOrderHandler over the given order repository.
+func newTestOrderHandlerWithRepository(orders dal.OrderRepository) *orderHandler {
+	menu := models.MenuItem{
+		ID: "latte", Name: "Latte", Price: 3.5, Available: true,
+		Ingredients: []models.MenuItemIngredient{{IngredientID: "milk", Quantity: 200}, {IngredientID: "espresso", Quantity: 1}},
+	}
+	inventory := []models.InventoryItem{
+		{IngredientID: "espresso", Name: "Espresso", Quantity: 100, Unit: "shots"},
+		{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml"},
+	}
+
+	s := service.NewOrderService(
+		orders,
+		dal.NewInMemoryMenuRepository(menu),
+		dal.NewInMemoryInventoryRepository(inventory...),
+		dal.NewInMemoryReportRepository(0),
+		dal.NewInMemoryOrderAuditRepository(),
+		dal.NewInMemoryReservationRepository(),
+		0,
+	)
+	return NewOrderHandler(s, logger.New(slog.LevelError, logger.FormatText, io.Discard), 1<<20)
+}
+
+// serveOrders sends the request to the order routes of the handler and returns the response.
+func serveOrders(h *orderHandler, method, target, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /orders/{id}", h.UpdateOrder)
+	mux.HandleFunc("PATCH /orders/{id}", h.PatchOrder)
+	mux.HandleFunc("POST /orders/{id}/close", h.CloseOrder)
+	mux.HandleFunc("POST /orders/{id}/reserve", h.ReserveInventory)
+	mux.HandleFunc("GET /orders/{id}", h.RetrieveOrder)
+	mux.HandleFunc("GET /orders", h.RetrieveOrders)
+	mux.HandleFunc("POST /orders", h.CreateOrder)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestRequestActor(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		remoteAddr string
+		want       string
+	}{
+		{name: "header", header: "barista-1", remoteAddr: "10.0.0.1:5000", want: "barista-1"},
+		{name: "header trimmed", header: "  barista-1 ", remoteAddr: "10.0.0.1:5000", want: "barista-1"},
+		{name: "header limited", header: strings.Repeat("a", 100), remoteAddr: "10.0.0.1:5000", want: strings.Repeat("a", maxActorLength)},
+		{name: "limit inside a rune", header: strings.Repeat("a", maxActorLength-1) + "é", remoteAddr: "10.0.0.1:5000", want: strings.Repeat("a", maxActorLength-1)},
+		{name: "blank header", header: "   ", remoteAddr: "10.0.0.1:5000", want: "10.0.0.1"},
+		{name: "no header", remoteAddr: "[::1]:5000", want: "::1"},
+		{name: "address without port", remoteAddr: "10.0.0.1", want: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/orders", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set("X-Actor", tt.header)
+			}
+
+			if got := requestActor(r); got != tt.want {
+				t.Errorf("requestActor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCorruptedStoredOrder(t *testing.T) {
+	latte := []models.OrderItem{{ProductID: "latte", Quantity: 1}}
+	future := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+	update := `{"customer_name":"Alice","items":[{"product_id":"latte","quantity":2}]}`
+
+	tests := []struct {
+		name   string
+		order  models.Order
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{name: "update unknown status", order: models.Order{Status: "bogus", CreatedAt: "2024-01-02T10:00:00Z"}, method: "PUT", target: "/orders/orders1", body: update, want: http.StatusConflict},
+		{name: "update malformed created_at", order: models.Order{Status: models.StatusOpen, CreatedAt: "yesterday"}, method: "PUT", target: "/orders/orders1", body: update, want: http.StatusConflict},
+		{name: "update future created_at", order: models.Order{Status: models.StatusOpen, CreatedAt: future}, method: "PUT", target: "/orders/orders1", body: update, want: http.StatusConflict},
+		{name: "patch unknown status", order: models.Order{Status: "bogus", CreatedAt: "2024-01-02T10:00:00Z"}, method: "PATCH", target: "/orders/orders1", body: `{"notes":"extra hot"}`, want: http.StatusConflict},
+		{name: "close unknown status", order: models.Order{Status: "bogus", CreatedAt: "2024-01-02T10:00:00Z"}, method: "POST", target: "/orders/orders1/close", want: http.StatusConflict},
+		{name: "reserve unknown status", order: models.Order{Status: "bogus", CreatedAt: "2024-01-02T10:00:00Z"}, method: "POST", target: "/orders/orders1/reserve", want: http.StatusConflict},
+		{name: "update mixed-case open status", order: models.Order{Status: "Open", CreatedAt: "2024-01-02T10:00:00Z"}, method: "PUT", target: "/orders/orders1", body: update, want: http.StatusOK},
+		{name: "close mixed-case open status", order: models.Order{Status: " OPEN ", CreatedAt: "2024-01-02T10:00:00Z"}, method: "POST", target: "/orders/orders1/close", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.order.ID, tt.order.CustomerName, tt.order.Items = "orders1", "Alice", latte
+			h := newTestOrderHandler(tt.order)
+
+			w := serveOrders(h, tt.method, tt.target, tt.body)
+			if w.Code != tt.want {
+				t.Errorf("%s %s status = %d, want %d: %s", tt.method, tt.target, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestOrderBodyLimit(t *testing.T) {
+	oversized := `{"customer_name":"` + strings.Repeat("a", 2<<20) + `"}`
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{name: "create oversized", method: "POST", target: "/orders", body: oversized, want: http.StatusRequestEntityTooLarge},
+		{name: "update oversized", method: "PUT", target: "/orders/orders1", body: oversized, want: http.StatusRequestEntityTooLarge},
+		{name: "patch oversized", method: "PATCH", target: "/orders/orders1", body: oversized, want: http.StatusRequestEntityTooLarge},
+		{name: "create empty", method: "POST", target: "/orders", want: http.StatusBadRequest},
+		{name: "update empty", method: "PUT", target: "/orders/orders1", want: http.StatusBadRequest},
+		{name: "create", method: "POST", target: "/orders", body: `{"customer_name":"Alice","items":[{"product_id":"latte","quantity":1}]}`, want: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestOrderHandler(models.Order{ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}})
+
+			w := serveOrders(h, tt.method, tt.target, tt.body)
+			if w.Code != tt.want {
+				t.Errorf("%s %s status = %d, want %d", tt.method, tt.target, w.Code, tt.want)
+			}
+		})
+	}
+}
+
+// headerCountingWriter counts the WriteHeader calls, a second call is a superfluous write the server only logs.
+type headerCountingWriter struct {
+	*httptest.ResponseRecorder
+	writeHeaders int
+}
+
+func (w *headerCountingWriter) WriteHeader(status int) {
+	w.writeHeaders++
+	w.ResponseRecorder.WriteHeader(status)
+}
+
+func TestRetrieveOrderWritesHeaderOnce(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{name: "existing order", id: "orders1", want: http.StatusOK},
+		{name: "missing order", id: "missing", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestOrderHandler(models.Order{ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}})
+
+			r := httptest.NewRequest("GET", "/orders/"+tt.id, nil)
+			r.SetPathValue("id", tt.id)
+			w := &headerCountingWriter{ResponseRecorder: httptest.NewRecorder()}
+			h.RetrieveOrder(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("GET /orders/%s status = %d, want %d", tt.id, w.Code, tt.want)
+			}
+			if w.writeHeaders != 1 {
+				t.Errorf("WriteHeader called %d times, want 1", w.writeHeaders)
+			}
+		})
+	}
+}
+
+// failingWriter fails every write, like a client that went away.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestWriteOrdersCSV(t *testing.T) {
+	exports := []models.OrderExport{{ID: "orders1", CustomerName: "Alice", Status: "closed", CreatedAt: "2024-01-02T10:00:00Z", TotalPrice: 3.5}}
+
+	tests := []struct {
+		name    string
+		writer  io.Writer
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "written",
+			writer: &strings.Builder{},
+			want:   "order_id,customer_name,status,created_at,total_price\norders1,Alice,closed,2024-01-02T10:00:00Z,3.50\n",
+		},
+		{name: "write fails", writer: failingWriter{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := writeOrdersCSV(tt.writer, exports)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeOrdersCSV() error = %v, want error %v", err, tt.wantErr)
+			}
+			if b, ok := tt.writer.(*strings.Builder); ok && b.String() != tt.want {
+				t.Errorf("writeOrdersCSV() wrote %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+// readOnlyStorage serves the orders it was seeded with and fails every write, like a full disk.
+type readOnlyStorage struct {
+	dal.Storage
+}
+
+func (readOnlyStorage) Write(key string, data []byte) error {
+	return errors.New("no space left on device")
+}
+
+func TestDeleteOrder(t *testing.T) {
+	stored := models.Order{ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}}
+
+	tests := []struct {
+		name     string
+		readOnly bool
+		id       string
+		want     int
+		wantCode string
+	}{
+		{name: "deleted", id: "orders1", want: http.StatusNoContent},
+		{name: "missing order", id: "orders9", want: http.StatusNotFound, wantCode: "ORDER_NOT_FOUND"},
+		{name: "failed write", readOnly: true, id: "orders1", want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestOrderHandler(stored)
+			if tt.readOnly {
+				storage := dal.NewInMemoryStorage()
+				data, _ := json.Marshal([]models.Order{stored})
+				storage.Write("orders", data)
+
+				s := service.NewOrderService(
+					dal.NewOrderRepositoryWithStorage(readOnlyStorage{storage}, "orders"),
+					dal.NewInMemoryMenuRepository(), dal.NewInMemoryInventoryRepository(), dal.NewInMemoryReportRepository(0),
+					dal.NewInMemoryOrderAuditRepository(), dal.NewInMemoryReservationRepository(), 0,
+				)
+				h = NewOrderHandler(s, h.logger, 1<<20)
+			}
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("DELETE /orders/{id}", h.DeleteOrder)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("DELETE", "/orders/"+tt.id, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("DELETE /orders/%s status = %d, want %d: %s", tt.id, w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusNoContent {
+				if w.Body.Len() != 0 {
+					t.Errorf("204 response has a body: %s", w.Body)
+				}
+				return
+			}
+
+			var response models.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("error response is not JSON: %v: %s", err, w.Body)
+			}
+			if tt.wantCode != "" && response.Code != tt.wantCode {
+				t.Errorf("error code = %q, want %q", response.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRetrieveOrdersSince(t *testing.T) {
+	order := func(id string, status models.OrderStatus, age time.Duration) models.Order {
+		createdAt := time.Now().Add(-age).Format(time.RFC3339)
+		return models.Order{ID: id, CustomerName: "Alice", Status: status, CreatedAt: createdAt, Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}}
+	}
+	stored := []models.Order{
+		order("orders1", models.StatusOpen, 5*time.Minute),
+		order("orders2", models.StatusClosed, 10*time.Minute),
+		order("orders3", models.StatusOpen, time.Hour),
+	}
+
+	tests := []struct {
+		query string
+		want  int
+		ids   []string
+	}{
+		{query: "", want: http.StatusOK, ids: []string{"orders1", "orders2", "orders3"}},
+		{query: "?since=15m", want: http.StatusOK, ids: []string{"orders1", "orders2"}},
+		{query: "?since=15m&status=open", want: http.StatusOK, ids: []string{"orders1"}},
+		{query: "?since=2h&status=closed", want: http.StatusOK, ids: []string{"orders2"}},
+		{query: "?since=1m", want: http.StatusOK, ids: []string{}},
+		{query: "?since=fifteen", want: http.StatusBadRequest},
+		{query: "?since=-15m", want: http.StatusBadRequest},
+		{query: "?since=0s", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := serveOrders(newTestOrderHandler(stored...), "GET", "/orders"+tt.query, "")
+			if w.Code != tt.want {
+				t.Fatalf("GET /orders%s status = %d, want %d: %s", tt.query, w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			var orders []models.Order
+			if err := json.Unmarshal(w.Body.Bytes(), &orders); err != nil {
+				t.Fatalf("response is not a JSON array: %v: %s", err, w.Body)
+			}
+			ids := []string{}
+			for _, order := range orders {
+				ids = append(ids, order.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.ids, ",") {
+				t.Errorf("GET /orders%s = %v, want %v", tt.query, ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestRetrieveOrdersSortParameters(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{query: "?sort=customer_name&order=asc", want: http.StatusOK},
+		{query: "?sort=status", want: http.StatusOK},
+		{query: "?sort=total", want: http.StatusBadRequest},
+		{query: "?order=sideways", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := serveOrders(newTestOrderHandler(), "GET", "/orders"+tt.query, "")
+			if w.Code != tt.want {
+				t.Errorf("GET /orders%s status = %d, want %d: %s", tt.query, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestCloseOrderErrors(t *testing.T) {
+	order := func(status models.OrderStatus, lattes float64) models.Order {
+		return models.Order{ID: "orders1", CustomerName: "Alice", Status: status, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "latte", Quantity: lattes}}}
+	}
+
+	tests := []struct {
+		name     string
+		order    models.Order
+		readOnly bool
+		id       string
+		want     int
+	}{
+		{name: "closed", order: order(models.StatusOpen, 1), id: "orders1", want: http.StatusOK},
+		{name: "missing order", order: order(models.StatusOpen, 1), id: "orders9", want: http.StatusNotFound},
+		{name: "already closed", order: order(models.StatusClosed, 1), id: "orders1", want: http.StatusConflict},
+		{name: "not enough milk", order: order(models.StatusOpen, 6), id: "orders1", want: http.StatusBadRequest},
+		{name: "unknown product", order: models.Order{ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "mocha", Quantity: 1}}}, id: "orders1", want: http.StatusUnprocessableEntity},
+		{name: "failed write", order: order(models.StatusOpen, 1), readOnly: true, id: "orders1", want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestOrderHandler(tt.order)
+			if tt.readOnly {
+				storage := dal.NewInMemoryStorage()
+				data, _ := json.Marshal([]models.Order{tt.order})
+				storage.Write("orders", data)
+				h = newTestOrderHandlerWithRepository(dal.NewOrderRepositoryWithStorage(readOnlyStorage{storage}, "orders"))
+			}
+
+			w := serveOrders(h, "POST", "/orders/"+tt.id+"/close", "")
+			if w.Code != tt.want {
+				t.Errorf("POST /orders/%s/close status = %d, want %d: %s", tt.id, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
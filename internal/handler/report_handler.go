@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
+	"time"
 
 	"hot-coffee/internal/service"
 	"hot-coffee/internal/utils"
@@ -12,6 +15,13 @@ import (
 type ReportHandler interface {
 	GetTotalSales(w http.ResponseWriter, r *http.Request)
 	GetPopularItems(w http.ResponseWriter, r *http.Request)
+	GetOrderVolume(w http.ResponseWriter, r *http.Request)
+	GetIngredientUsage(w http.ResponseWriter, r *http.Request)
+	GetOrderCounts(w http.ResponseWriter, r *http.Request)
+	GetAverageOrderValue(w http.ResponseWriter, r *http.Request)
+	GetAverageFulfillmentTime(w http.ResponseWriter, r *http.Request)
+	GetInventoryValue(w http.ResponseWriter, r *http.Request)
+	GetRevenueByItem(w http.ResponseWriter, r *http.Request)
 }
 
 type reportHandler struct {
@@ -23,21 +33,54 @@ func NewReportHandler(rs service.ReportService, l *logger.Logger) *reportHandler
 	return &reportHandler{ReportService: rs, logger: l}
 }
 
-func (h *reportHandler) WriteInfoResponse(statusCode int, message string, w http.ResponseWriter, r *http.Request) {
-	h.logger.PrintDebugMsg(message)
+// parseTimeRange parses the optional "from" and "to" RFC3339 query parameters.
+// Missing parameters are returned as zero times.
+func parseTimeRange(r *http.Request) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
 
-	infoJSON := &models.InfoResponse{Message: message}
-	utils.WriteJSONResponse(statusCode, infoJSON, w, r)
+	if value := r.URL.Query().Get("from"); value != "" {
+		from, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("'from' must be an RFC3339 timestamp: %s", value)
+		}
+	}
+
+	if value := r.URL.Query().Get("to"); value != "" {
+		to, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("'to' must be an RFC3339 timestamp: %s", value)
+		}
+	}
+
+	return from, to, nil
 }
 
+// GetTotalSales handles the HTTP request to retrieve the total sales.
+// If "from" or "to" are set, only closed orders within the range are summed.
 func (h *reportHandler) GetTotalSales(w http.ResponseWriter, r *http.Request) {
-	totalSales, err := h.ReportService.GetTotalSales()
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+		return
+	}
+
+	var totalSales models.TotalSales
+	if from.IsZero() && to.IsZero() {
+		totalSales, err = h.ReportService.GetTotalSales()
+	} else {
+		totalSales, err = h.ReportService.GetTotalSalesInRange(from, to)
+	}
+	if err == service.ErrNotValidTimeRange {
+		utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+		return
+	}
 	if err != nil {
 		h.logger.PrintErrorMsg("Failed to get total sales: " + err.Error())
 		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
 		return
 	}
-	h.logger.PrintDebugMsg("Successfully retrieved the total sales: ", totalSales)
+	h.logger.PrintDebugMsg("Successfully retrieved the total sales: %+v", totalSales)
 	utils.WriteJSONResponse(http.StatusOK, totalSales, w, r)
 }
 
@@ -46,3 +89,122 @@ func (h *reportHandler) GetPopularItems(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("There will be Retrieving popular items."))
 }
+
+// GetOrderVolume handles the HTTP request to retrieve the number of orders created per hour or day.
+// The entries are sorted chronologically.
+func (h *reportHandler) GetOrderVolume(w http.ResponseWriter, r *http.Request) {
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+
+	volume, err := h.ReportService.GetOrderVolume(bucket)
+	if err != nil {
+		switch err {
+		case service.ErrNotValidBucket:
+			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		}
+		return
+	}
+
+	entries := []models.OrderVolume{}
+	for period, count := range volume {
+		entries = append(entries, models.OrderVolume{Period: period, Count: count})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Period < entries[j].Period
+	})
+
+	h.logger.PrintDebugMsg("Successfully retrieved the order volume by %s", bucket)
+	utils.WriteJSONResponse(http.StatusOK, entries, w, r)
+}
+
+// GetIngredientUsage handles the HTTP request to retrieve the ingredients consumed by closed orders.
+func (h *reportHandler) GetIngredientUsage(w http.ResponseWriter, r *http.Request) {
+	usages, err := h.ReportService.GetIngredientUsage()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	h.logger.PrintDebugMsg("Successfully retrieved the ingredient usage")
+	utils.WriteJSONResponse(http.StatusOK, usages, w, r)
+}
+
+// GetOrderCounts handles the HTTP request to retrieve the number of open and closed orders.
+func (h *reportHandler) GetOrderCounts(w http.ResponseWriter, r *http.Request) {
+	counts, err := h.ReportService.GetOrderCounts()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	h.logger.PrintDebugMsg("Successfully retrieved the order counts: %+v", counts)
+	utils.WriteJSONResponse(http.StatusOK, counts, w, r)
+}
+
+// GetAverageOrderValue handles the HTTP request to retrieve the average value of closed orders.
+// If "from" or "to" are set, only closed orders within the range are taken into account.
+func (h *reportHandler) GetAverageOrderValue(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+		return
+	}
+
+	average, err := h.ReportService.GetAverageOrderValue(from, to)
+	if err != nil {
+		switch err {
+		case service.ErrNotValidTimeRange:
+			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		}
+		return
+	}
+
+	h.logger.PrintDebugMsg("Successfully retrieved the average order value: %+v", average)
+	utils.WriteJSONResponse(http.StatusOK, average, w, r)
+}
+
+// GetAverageFulfillmentTime handles the HTTP request to get the average time from creation to closing of the closed orders.
+func (h *reportHandler) GetAverageFulfillmentTime(w http.ResponseWriter, r *http.Request) {
+	average, err := h.ReportService.GetAverageFulfillmentTime()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	h.logger.PrintDebugMsg("Successfully retrieved the average fulfillment time: %+v", average)
+	utils.WriteJSONResponse(http.StatusOK, average, w, r)
+}
+
+// GetInventoryValue handles the HTTP request to retrieve the value of the inventory at cost.
+func (h *reportHandler) GetInventoryValue(w http.ResponseWriter, r *http.Request) {
+	value, err := h.ReportService.GetInventoryValue()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	if len(value.UncostedItems) > 0 {
+		h.logger.PrintDebugMsg("Inventory items without a cost per unit excluded from the inventory value: %v", value.UncostedItems)
+	}
+	h.logger.PrintDebugMsg("Successfully retrieved the inventory value: %g", value.TotalValue)
+	utils.WriteJSONResponse(http.StatusOK, value, w, r)
+}
+
+// GetRevenueByItem handles the HTTP request to retrieve the revenue of every product sold, highest first.
+func (h *reportHandler) GetRevenueByItem(w http.ResponseWriter, r *http.Request) {
+	revenues, err := h.ReportService.GetRevenueByItem()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	h.logger.PrintDebugMsg("Successfully retrieved the revenue of %d products", len(revenues))
+	utils.WriteJSONResponse(http.StatusOK, revenues, w, r)
+}
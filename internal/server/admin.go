@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+// HandleReload reloads every data file into the repository caches, so that files edited by hand
+// are picked up without a restart.
+// Responds with the number of records loaded from each file, or 500 naming the first file that failed.
+func (s *Server) HandleReload(w http.ResponseWriter, r *http.Request) {
+	summary, err := s.reloadData()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	s.logger.PrintInfoMsg("Reloaded data files: %+v", summary)
+
+	utils.WriteJSONResponse(http.StatusOK, summary, w, r)
+}
+
+// reloadData drops the cached data of every repository and loads it from the data files again.
+func (s *Server) reloadData() (models.ReloadSummary, error) {
+	summary := models.ReloadSummary{}
+	s.repositories.invalidateCaches()
+
+	inventoryItems, err := s.repositories.inventory.GetAllItems()
+	if err != nil {
+		return summary, fmt.Errorf("failed to reload inventory: %w", err)
+	}
+	summary.InventoryItems = len(inventoryItems)
+
+	menuItems, err := s.repositories.menu.GetAllMenuItems()
+	if err != nil {
+		return summary, fmt.Errorf("failed to reload menu: %w", err)
+	}
+	summary.MenuItems = len(menuItems)
+
+	orders, err := s.repositories.order.GetAllOrders()
+	if err != nil {
+		return summary, fmt.Errorf("failed to reload orders: %w", err)
+	}
+	summary.Orders = len(orders)
+
+	adjustments, err := s.repositories.adjustment.GetAllAdjustments()
+	if err != nil {
+		return summary, fmt.Errorf("failed to reload adjustments: %w", err)
+	}
+	summary.Adjustments = len(adjustments)
+
+	auditEntries, err := s.repositories.orderAudit.GetAllEntries()
+	if err != nil {
+		return summary, fmt.Errorf("failed to reload order history: %w", err)
+	}
+	summary.OrderHistory = len(auditEntries)
+
+	reservations, err := s.repositories.reservation.GetAllReservations()
+	if err != nil {
+		return summary, fmt.Errorf("failed to reload reservations: %w", err)
+	}
+	summary.Reservations = len(reservations)
+
+	if _, err := s.repositories.report.GetTotalSales(); err != nil {
+		return summary, fmt.Errorf("failed to reload report: %w", err)
+	}
+
+	return summary, nil
+}
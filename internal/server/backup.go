@@ -0,0 +1,274 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+// backupTimeFormat names the backup directories, so they sort by creation time
+const backupTimeFormat = "20060102T150405.000Z"
+
+var (
+	errBackupAuthDisabled = errors.New("backups require an API key to be configured")
+	errBackupNotFound     = errors.New("backup not found")
+	errNotValidBackup     = errors.New("backup must be a directory in the backups directory")
+)
+
+// dataFiles returns the paths of every data file of the repositories.
+func (s *Server) dataFiles() []string {
+	return []string{
+		s.config.inventory_file,
+		s.config.menu_file,
+		s.config.order_file,
+		s.config.report_file,
+		s.config.adjustment_file,
+		s.config.order_audit_file,
+		s.config.reservation_file,
+	}
+}
+
+// backupsDir is the directory the backups are stored in, one timestamped directory per backup.
+func (s *Server) backupsDir() string {
+	return filepath.Join(s.config.data_directory, "backups")
+}
+
+// HandleBackup copies every data file into a new timestamped backup directory.
+// Writes are blocked while the files are copied, so the backup is consistent.
+// Responds with the backup path, which can be passed to POST /admin/restore.
+// Backups are refused with 403 while authentication is disabled.
+func (s *Server) HandleBackup(w http.ResponseWriter, r *http.Request) {
+	if s.config.api_key == "" {
+		utils.WriteErrorResponse(http.StatusForbidden, errBackupAuthDisabled, w, r)
+		return
+	}
+
+	unlock := s.repositories.lockWrites()
+	backup, err := s.createBackup()
+	unlock()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	s.logger.PrintInfoMsg("Created backup %s of %d data files", backup.Path, len(backup.Files))
+
+	utils.WriteJSONResponse(http.StatusCreated, backup, w, r)
+}
+
+// HandleRestore replaces the data files with the ones of the backup named by the "from" query parameter
+// and reloads them. The current data is backed up first, so a restore can be undone.
+// Data files missing from the backup did not exist when it was taken and are removed.
+// Writes are blocked from the backup of the current data until the restored data is loaded,
+// so no write is lost between the two.
+// Restores are refused with 403 while authentication is disabled.
+func (s *Server) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	if s.config.api_key == "" {
+		utils.WriteErrorResponse(http.StatusForbidden, errBackupAuthDisabled, w, r)
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	backupPath, err := s.resolveBackup(from)
+	if err != nil {
+		switch err {
+		case errBackupNotFound:
+			utils.WriteErrorResponse(http.StatusNotFound, fmt.Errorf("backup '%s' not found", from), w, r)
+		case errNotValidBackup:
+			utils.WriteErrorResponse(http.StatusBadRequest, err, w, r)
+		default:
+			utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		}
+		return
+	}
+
+	if err := s.checkBackup(backupPath); err != nil {
+		utils.WriteErrorResponse(http.StatusUnprocessableEntity, err, w, r)
+		return
+	}
+
+	unlock := s.repositories.lockWrites()
+	defer unlock()
+
+	previous, err := s.createBackup()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, fmt.Errorf("failed to back up the current data: %w", err), w, r)
+		return
+	}
+
+	if err := s.restoreBackup(backupPath); err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, fmt.Errorf("%w, the replaced data is kept in %s", err, previous.Path), w, r)
+		return
+	}
+
+	summary, err := s.reloadData()
+	if err != nil {
+		utils.WriteErrorResponse(http.StatusInternalServerError, err, w, r)
+		return
+	}
+
+	s.logger.PrintInfoMsg("Restored data files from %s, replaced data kept in %s", backupPath, previous.Path)
+
+	utils.WriteJSONResponse(http.StatusOK, &models.Restore{From: backupPath, PreviousBackup: previous.Path, Loaded: summary}, w, r)
+}
+
+// createBackup copies the existing data files into a new directory in the backups directory.
+// The caller blocks the repository writes.
+func (s *Server) createBackup() (models.Backup, error) {
+	now := time.Now().UTC()
+	backup := models.Backup{
+		Files:     []string{},
+		CreatedAt: now.Format(time.RFC3339),
+	}
+
+	path, err := s.createBackupDir(now.Format(backupTimeFormat))
+	if err != nil {
+		return models.Backup{}, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	backup.Path = path
+
+	for _, file := range s.dataFiles() {
+		copied, err := copyFile(file, filepath.Join(backup.Path, filepath.Base(file)))
+		if err != nil {
+			return models.Backup{}, fmt.Errorf("failed to back up %s: %w", file, err)
+		}
+		if copied {
+			backup.Files = append(backup.Files, filepath.Base(file))
+		}
+	}
+
+	return backup, nil
+}
+
+// createBackupDir creates a new directory with the given name in the backups directory.
+// A numbered suffix is added when a backup of the same name exists, so two backups
+// taken within the same millisecond never share a directory.
+func (s *Server) createBackupDir(name string) (string, error) {
+	if err := os.MkdirAll(s.backupsDir(), 0o755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(s.backupsDir(), name)
+	for i := 1; ; i++ {
+		// Mkdir fails if the directory exists, unlike MkdirAll
+		err := os.Mkdir(path, 0o755)
+		if err == nil {
+			return path, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		path = filepath.Join(s.backupsDir(), fmt.Sprintf("%s-%d", name, i))
+	}
+}
+
+// resolveBackup returns the path of a backup given its path, as returned by a backup, or its directory name.
+// Only directories directly inside the backups directory are accepted.
+func (s *Server) resolveBackup(from string) (string, error) {
+	if from == "" {
+		return "", errNotValidBackup
+	}
+
+	name := filepath.Base(filepath.Clean(from))
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", errNotValidBackup
+	}
+	if filepath.Clean(from) != name && filepath.Dir(filepath.Clean(from)) != filepath.Clean(s.backupsDir()) {
+		return "", errNotValidBackup
+	}
+
+	backupPath := filepath.Join(s.backupsDir(), name)
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errBackupNotFound
+		}
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", errBackupNotFound
+	}
+
+	return backupPath, nil
+}
+
+// checkBackup verifies that every file of the backup is valid JSON before any data file is replaced.
+func (s *Server) checkBackup(backupPath string) error {
+	for _, file := range s.dataFiles() {
+		data, err := os.ReadFile(filepath.Join(backupPath, filepath.Base(file)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(strings.TrimSpace(string(data))) > 0 && !json.Valid(data) {
+			return fmt.Errorf("backup file %s is not valid JSON", filepath.Base(file))
+		}
+	}
+	return nil
+}
+
+// restoreBackup replaces every data file with its copy in the backup, removing the files the backup has no copy of.
+func (s *Server) restoreBackup(backupPath string) error {
+	for _, file := range s.dataFiles() {
+		copied, err := copyFile(filepath.Join(backupPath, filepath.Base(file)), file)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", file, err)
+		}
+		if !copied {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", file, err)
+			}
+		}
+	}
+	return nil
+}
+
+// copyFile copies the file at src to dst, replacing dst.
+// The copy is written to a temporary file next to dst and renamed over it,
+// so dst is never left partially written.
+// Returns false without an error if src does not exist.
+func copyFile(src, dst string) (bool, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return false, err
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
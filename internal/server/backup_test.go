@@ -0,0 +1,206 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"hot-coffee/models"
+)
+
+// serve sends an authenticated request to the server and returns the response.
+func serve(t *testing.T, s *Server, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("X-API-Key", testAPIKey)
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(w, r)
+	return w
+}
+
+func inventoryQuantities(t *testing.T, s *Server) map[string]float64 {
+	t.Helper()
+
+	items, err := s.repositories.inventory.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	quantities := map[string]float64{}
+	for _, item := range items {
+		quantities[item.IngredientID] = item.Quantity
+	}
+	return quantities
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	s := newTestServer(t)
+	if w := serve(t, s, "POST", "/inventory", `{"ingredient_id":"milk","name":"Milk","quantity":1000,"unit":"ml"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST /inventory status = %d: %s", w.Code, w.Body)
+	}
+
+	w := serve(t, s, "POST", "/admin/backup", "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /admin/backup status = %d: %s", w.Code, w.Body)
+	}
+	var backup models.Backup
+	if err := json.Unmarshal(w.Body.Bytes(), &backup); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes made after the backup are undone by the restore
+	if w := serve(t, s, "POST", "/inventory", `{"ingredient_id":"sugar","name":"Sugar","quantity":500,"unit":"g"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST /inventory status = %d: %s", w.Code, w.Body)
+	}
+
+	w = serve(t, s, "POST", "/admin/restore?from="+url.QueryEscape(backup.Path), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /admin/restore status = %d: %s", w.Code, w.Body)
+	}
+	var restore models.Restore
+	if err := json.Unmarshal(w.Body.Bytes(), &restore); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := inventoryQuantities(t, s); len(got) != 1 || got["milk"] != 1000 {
+		t.Errorf("inventory after restore = %v, want only milk 1000", got)
+	}
+	if restore.PreviousBackup == backup.Path {
+		t.Errorf("the replaced data was backed up into the restored backup %s", backup.Path)
+	}
+	if _, err := os.Stat(filepath.Join(restore.PreviousBackup, "inventory.json")); err != nil {
+		t.Errorf("replaced data is not kept: %v", err)
+	}
+}
+
+func TestCreateBackupUniqueDirs(t *testing.T) {
+	s := newTestServer(t)
+	if err := os.WriteFile(s.config.inventory_file, []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backups taken in the same millisecond must not share a directory
+	paths := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		backup, err := s.createBackup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if paths[backup.Path] {
+			t.Fatalf("backup %d reused the directory %s", i, backup.Path)
+		}
+		paths[backup.Path] = true
+	}
+}
+
+func TestResolveBackup(t *testing.T) {
+	s := newTestServer(t)
+	if err := os.MkdirAll(filepath.Join(s.backupsDir(), "20240102T100000.000Z"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.backupsDir(), "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		from    string
+		want    string
+		wantErr error
+	}{
+		{name: "directory name", from: "20240102T100000.000Z", want: filepath.Join(s.backupsDir(), "20240102T100000.000Z")},
+		{name: "backup path", from: filepath.Join(s.backupsDir(), "20240102T100000.000Z"), want: filepath.Join(s.backupsDir(), "20240102T100000.000Z")},
+		{name: "empty", from: "", wantErr: errNotValidBackup},
+		{name: "parent directory", from: "..", wantErr: errNotValidBackup},
+		{name: "outside the backups directory", from: filepath.Join(s.config.data_directory, "20240102T100000.000Z"), wantErr: errNotValidBackup},
+		{name: "missing", from: "20200101T000000.000Z", wantErr: errBackupNotFound},
+		{name: "not a directory", from: "file", wantErr: errBackupNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.resolveBackup(tt.from)
+			if err != tt.wantErr {
+				t.Fatalf("resolveBackup(%q) error = %v, want %v", tt.from, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveBackup(%q) = %q, want %q", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.json")
+	dst := filepath.Join(dir, "dst.json")
+	if err := os.WriteFile(src, []byte("[1]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("[2]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		src        string
+		wantCopied bool
+		wantData   string
+	}{
+		{name: "replaces the destination", src: src, wantCopied: true, wantData: "[1]"},
+		{name: "missing source", src: filepath.Join(dir, "missing.json"), wantCopied: false, wantData: "[1]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			copied, err := copyFile(tt.src, dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if copied != tt.wantCopied {
+				t.Errorf("copyFile() = %v, want %v", copied, tt.wantCopied)
+			}
+			if data, _ := os.ReadFile(dst); string(data) != tt.wantData {
+				t.Errorf("destination holds %q, want %q", data, tt.wantData)
+			}
+		})
+	}
+
+	// No temporary file is left next to the destination
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("directory has %d files after the copies, want 2", len(entries))
+	}
+}
+
+func TestLockWritesBlocksWrites(t *testing.T) {
+	s := newTestServer(t)
+	unlock := s.repositories.lockWrites()
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := s.repositories.inventory.AddItem(models.InventoryItem{IngredientID: "milk", Name: "Milk", Quantity: 1, Unit: "ml"})
+		written <- err
+	}()
+
+	select {
+	case <-written:
+		t.Fatal("a write went through while the repositories were locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,5 +1,10 @@
 package server
 
+import (
+	"strings"
+	"time"
+)
+
 type Config struct {
 	env            string
 	port           string
@@ -10,14 +15,30 @@ type Config struct {
 	order_file     string
 	report_file    string
 
-	read_timeout  string
-	write_timeout string
-	idle_timout   string
+	adjustment_file  string
+	order_audit_file string
+	reservation_file string
+
+	read_timeout  time.Duration
+	write_timeout time.Duration
+	idle_timeout  time.Duration
 
-	log_file string
 	cfg_file string
 
 	allow_overwrite bool
+
+	max_body_size int64
+
+	cors_origins []string
+
+	api_key string
+
+	rate_limit float64
+	rate_burst int
+
+	tax_rate float64
+
+	public_metrics bool
 }
 
 func NewConfig(configPath, port, dir string) *Config {
@@ -36,17 +57,83 @@ func NewConfig(configPath, port, dir string) *Config {
 		order_file:     dir + "/orders.json",
 		report_file:    dir + "/report.json",
 
-		read_timeout:  "4s",
-		write_timeout: "4s",
-		idle_timout:   "60s",
+		adjustment_file:  dir + "/inventory_adjustments.json",
+		order_audit_file: dir + "/order_history.json",
+		reservation_file: dir + "/reservations.json",
+
+		read_timeout:  10 * time.Second,
+		write_timeout: 30 * time.Second,
+		idle_timeout:  120 * time.Second,
 
-		log_file: "./logs/triple-s.log",
 		cfg_file: "./configs/server.yaml",
 
 		allow_overwrite: true,
+
+		max_body_size: 1 << 20,
+
+		rate_limit: 10,
+		rate_burst: 20,
 	}
 }
 
 func (cfg *Config) GetPort() string {
 	return cfg.port
 }
+
+// SetMaxBodySize sets the maximum allowed size of a request body in bytes.
+// Non-positive values are ignored and the default limit is kept.
+func (cfg *Config) SetMaxBodySize(size int64) {
+	if size > 0 {
+		cfg.max_body_size = size
+	}
+}
+
+// SetCORSOrigins sets the origins allowed to make cross-origin requests.
+// The list is a comma separated string, empty entries are ignored.
+func (cfg *Config) SetCORSOrigins(origins string) {
+	cfg.cors_origins = nil
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.cors_origins = append(cfg.cors_origins, origin)
+		}
+	}
+}
+
+// SetAPIKey sets the key clients must send in the X-API-Key header.
+// An empty key disables authentication.
+func (cfg *Config) SetAPIKey(key string) {
+	cfg.api_key = key
+}
+
+// SetRateLimit sets the number of requests per second allowed for each client
+// and the maximum burst size. A non-positive rate disables rate limiting.
+func (cfg *Config) SetRateLimit(rate float64, burst int) {
+	cfg.rate_limit = rate
+	if burst > 0 {
+		cfg.rate_burst = burst
+	}
+}
+
+// SetTimeouts sets the time allowed to read a request, including its body, to write a response
+// and to keep an idle connection open. A zero timeout disables it, negative values are ignored.
+func (cfg *Config) SetTimeouts(read, write, idle time.Duration) {
+	if read >= 0 {
+		cfg.read_timeout = read
+	}
+	if write >= 0 {
+		cfg.write_timeout = write
+	}
+	if idle >= 0 {
+		cfg.idle_timeout = idle
+	}
+}
+
+// SetPublicMetrics serves GET /metrics without the API key when public is set.
+func (cfg *Config) SetPublicMetrics(public bool) {
+	cfg.public_metrics = public
+}
+
+// SetTaxRate sets the tax in percent applied to the discounted order subtotals.
+func (cfg *Config) SetTaxRate(rate float64) {
+	cfg.tax_rate = rate
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"hot-coffee/internal/utils"
+)
+
+// routeMethods are the methods probed to tell an unknown path from a known path with a wrong method.
+// HEAD is left out, the mux serves it with the GET routes.
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// withJSONFallback serves the requests matching a route of the mux and answers every other request
+// with a JSON error response, instead of the plain text 404 and 405 responses of http.ServeMux.
+func (s *Server) withJSONFallback(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := allowedMethods(mux, r)
+		if len(allowed) == 0 {
+			s.HandleNotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		s.HandleMethodNotAllowed(w, r)
+	})
+}
+
+// allowedMethods returns the methods the mux has routes for on the path of the request.
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	allowed := []string{}
+	for _, method := range routeMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, method)
+		}
+	}
+
+	if len(allowed) > 0 && allowed[0] == http.MethodGet {
+		allowed = append([]string{http.MethodGet, http.MethodHead}, allowed[1:]...)
+	}
+	return allowed
+}
+
+// HandleNotFound responds with 404 to requests for paths without a route.
+func (s *Server) HandleNotFound(w http.ResponseWriter, r *http.Request) {
+	utils.WriteErrorResponse(http.StatusNotFound, errors.New("path '"+r.URL.Path+"' not found"), w, r)
+}
+
+// HandleMethodNotAllowed responds with 405 to requests for known paths with an unsupported method.
+// The Allow header is set by the caller.
+func (s *Server) HandleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	utils.WriteErrorResponse(http.StatusMethodNotAllowed, errors.New("method "+r.Method+" is not allowed for '"+r.URL.Path+"'"), w, r)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestFallbackResponses(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		want      int
+		wantAllow string
+	}{
+		{name: "known route", method: "GET", target: "/orders", want: http.StatusOK},
+		{name: "unknown path", method: "GET", target: "/coffee", want: http.StatusNotFound},
+		{name: "unknown nested path", method: "GET", target: "/orders/orders1/receipt", want: http.StatusNotFound},
+		{name: "wrong method", method: "DELETE", target: "/orders", want: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, POST"},
+		{name: "wrong method on an item", method: "POST", target: "/menu/latte", want: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, PUT, DELETE"},
+	}
+
+	s := newTestServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, s, tt.method, tt.target, "")
+
+			if w.Code != tt.want {
+				t.Fatalf("%s %s status = %d, want %d: %s", tt.method, tt.target, w.Code, tt.want, w.Body)
+			}
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+			if tt.want == http.StatusOK {
+				return
+			}
+
+			var response models.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Errorf("%d response is not JSON: %v: %s", tt.want, err, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+// HandleHealth reports that the server process is up and able to serve requests.
+func (s *Server) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSONResponse(http.StatusOK, &models.HealthResponse{Status: "ok"}, w, r)
+}
+
+// HandleReady verifies that the data file of every repository shared by the routes can be opened and parsed.
+// The files are read directly, not through the repository caches, so a file deleted, made unreadable or
+// corrupted after it was cached fails the check. A missing file only passes while its repository holds
+// no data, as on a fresh deploy where the files are created on the first write.
+// Returns 200 if all checks pass, or 503 with the failed checks otherwise.
+func (s *Server) HandleReady(w http.ResponseWriter, r *http.Request) {
+	repositories := s.repositories
+	checks := map[string]struct {
+		path    string
+		hasData func() (bool, error)
+	}{
+		"inventory": {s.config.inventory_file, func() (bool, error) {
+			items, err := repositories.inventory.GetAllItems()
+			return len(items) > 0, err
+		}},
+		"menu": {s.config.menu_file, func() (bool, error) {
+			items, err := repositories.menu.GetAllMenuItems()
+			return len(items) > 0, err
+		}},
+		"orders": {s.config.order_file, func() (bool, error) {
+			orders, err := repositories.order.GetAllOrders()
+			return len(orders) > 0, err
+		}},
+		"adjustments": {s.config.adjustment_file, func() (bool, error) {
+			adjustments, err := repositories.adjustment.GetAllAdjustments()
+			return len(adjustments) > 0, err
+		}},
+		"order_history": {s.config.order_audit_file, func() (bool, error) {
+			entries, err := repositories.orderAudit.GetAllEntries()
+			return len(entries) > 0, err
+		}},
+		"reservations": {s.config.reservation_file, func() (bool, error) {
+			reservations, err := repositories.reservation.GetAllReservations()
+			return len(reservations) > 0, err
+		}},
+		"report": {s.config.report_file, func() (bool, error) {
+			totalSales, err := repositories.report.GetTotalSales()
+			return totalSales != models.TotalSales{}, err
+		}},
+	}
+
+	response := &models.HealthResponse{Status: "ok", Checks: make(map[string]string)}
+	statusCode := http.StatusOK
+
+	storage := dal.NewFileStorage()
+	for name, check := range checks {
+		if err := checkDataFile(storage, check.path, check.hasData); err != nil {
+			response.Checks[name] = err.Error()
+			response.Status = "unavailable"
+			statusCode = http.StatusServiceUnavailable
+			continue
+		}
+		response.Checks[name] = "ok"
+	}
+
+	if statusCode != http.StatusOK {
+		s.logger.PrintWarnMsg("Readiness check failed: %+v", response.Checks)
+	}
+
+	utils.WriteJSONResponse(statusCode, response, w, r)
+}
+
+// checkDataFile checks that the data file at path can be read and parsed. A missing file is an error
+// if hasData reports that the repository of the file holds data, which the file should hold too.
+func checkDataFile(storage dal.Storage, path string, hasData func() (bool, error)) error {
+	exists, err := dal.CheckJSON(storage, path)
+	if err != nil || exists {
+		return err
+	}
+
+	held, err := hasData()
+	if err != nil {
+		return err
+	}
+	if held {
+		return errors.New("data file is missing")
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestHandleReady(t *testing.T) {
+	tests := []struct {
+		name string
+		// change breaks the inventory data file after an item was added and cached, if set
+		change    func(t *testing.T, path string)
+		fresh     bool
+		want      int
+		wantCheck string
+	}{
+		{name: "fresh deploy without data files", fresh: true, want: http.StatusOK, wantCheck: "ok"},
+		{name: "cached data", want: http.StatusOK, wantCheck: "ok"},
+		{
+			name: "deleted data file",
+			change: func(t *testing.T, path string) {
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: http.StatusServiceUnavailable, wantCheck: "data file is missing",
+		},
+		{
+			name: "corrupted data file",
+			change: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte(`[{"ingredient_id":`), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: http.StatusServiceUnavailable, wantCheck: "data is not valid JSON",
+		},
+		{
+			name: "data file replaced by a directory",
+			change: func(t *testing.T, path string) {
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Mkdir(path, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			if !tt.fresh {
+				if w := serve(t, s, "POST", "/inventory", `{"ingredient_id":"milk","name":"Milk","quantity":1000,"unit":"ml"}`); w.Code != http.StatusCreated {
+					t.Fatalf("POST /inventory status = %d: %s", w.Code, w.Body)
+				}
+				// The inventory is cached, later checks must not be answered from memory
+				if w := serve(t, s, "GET", "/inventory", ""); w.Code != http.StatusOK {
+					t.Fatalf("GET /inventory status = %d: %s", w.Code, w.Body)
+				}
+			}
+			if tt.change != nil {
+				tt.change(t, s.config.inventory_file)
+			}
+
+			w := serve(t, s, "GET", "/ready", "")
+			if w.Code != tt.want {
+				t.Fatalf("GET /ready status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+
+			var response models.HealthResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if got := response.Checks["inventory"]; tt.wantCheck != "" && got != tt.wantCheck {
+				t.Errorf("inventory check = %q, want %q", got, tt.wantCheck)
+			}
+			if got := response.Checks["menu"]; got != "ok" {
+				t.Errorf("menu check = %q, want ok", got)
+			}
+		})
+	}
+}
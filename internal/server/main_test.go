@@ -0,0 +1,30 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"hot-coffee/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	// The services log through the package logger, only errors are shown in the test output
+	logger.InitLogger(slog.LevelError, logger.FormatText)
+	os.Exit(m.Run())
+}
+
+// testAPIKey authenticates the requests of the test servers
+const testAPIKey = "test-key"
+
+// newTestServer creates a server storing its data in a temporary directory, with authentication enabled
+// and rate limiting disabled.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	config := NewConfig("configs/server.yaml", ":0", t.TempDir())
+	config.SetAPIKey(testAPIKey)
+	config.SetRateLimit(0, 0)
+	return New(config, logger.New(slog.LevelError, logger.FormatText, io.Discard))
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hot-coffee/pkg/logger"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	tests := []struct {
+		name   string
+		apiKey string
+		public bool
+		key    string
+		want   int
+	}{
+		{name: "key required", apiKey: testAPIKey, want: http.StatusUnauthorized},
+		{name: "wrong key", apiKey: testAPIKey, key: "wrong", want: http.StatusUnauthorized},
+		{name: "valid key", apiKey: testAPIKey, key: testAPIKey, want: http.StatusOK},
+		{name: "public", apiKey: testAPIKey, public: true, want: http.StatusOK},
+		{name: "authentication disabled", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig("configs/server.yaml", ":0", t.TempDir())
+			config.SetAPIKey(tt.apiKey)
+			config.SetPublicMetrics(tt.public)
+			s := New(config, logger.New(slog.LevelError, logger.FormatText, io.Discard))
+
+			r := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.key != "" {
+				r.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("GET /metrics status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			for _, name := range []string{"hot_coffee_orders_created_total", "hot_coffee_http_request_duration_seconds"} {
+				if !strings.Contains(w.Body.String(), name) {
+					t.Errorf("metrics do not include %s", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/pkg/metrics"
+)
+
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, X-API-Key, If-Match, If-Modified-Since, X-Request-ID, X-Actor"
+	corsExposeHeaders = "ETag, Location, X-Request-ID"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// CORSMiddleware sets the CORS headers for origins in the configured allowlist
+// and answers preflight requests with 204. Origins are denied unless configured,
+// the "*" entry allows any origin.
+func (s *Server) CORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := s.originAllowed(origin)
+		isPreflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if !isPreflight {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !allowed {
+			utils.WriteErrorResponse(http.StatusForbidden, errors.New("origin '"+origin+"' is not allowed"), w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+		w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+		w.Header().Set("Access-Control-Max-Age", "600")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (s *Server) originAllowed(origin string) bool {
+	return slices.Contains(s.config.cors_origins, "*") ||
+		slices.ContainsFunc(s.config.cors_origins, func(o string) bool {
+			return strings.EqualFold(o, origin)
+		})
+}
+
+// RequestIDMiddleware assigns every request an ID, taken from the X-Request-ID header
+// or generated when the header is missing or too long. The ID is echoed in the response header.
+func (s *Server) RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the ID assigned to the request by RequestIDMiddleware.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// RecoverMiddleware recovers from panics in the handlers, so a single failing request
+// does not crash the server. The panic is logged with the request ID and stack trace,
+// the client only gets a generic 500 response.
+func (s *Server) RecoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// ErrAbortHandler is used to abort a response on purpose, net/http handles it silently
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			s.logger.PrintErrorMsg("Panic while serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, requestID(r), rec, debug.Stack())
+			utils.WriteErrorResponse(http.StatusInternalServerError, errors.New("internal server error"), w, r)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthMiddleware rejects requests without a valid X-API-Key header with 401.
+// Authentication is disabled when no API key is configured.
+func (s *Server) AuthMiddleware(next http.Handler) http.Handler {
+	if s.config.api_key == "" {
+		s.logger.PrintWarnMsg("API key is not set, authentication is disabled")
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			utils.WriteErrorResponse(http.StatusUnauthorized, errors.New("missing API key"), w, r)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(key), []byte(s.config.api_key)) != 1 {
+			utils.WriteErrorResponse(http.StatusUnauthorized, errors.New("invalid API key"), w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimitMiddleware limits the number of requests per client IP using a token bucket.
+// Requests over the limit are rejected with 429 and a Retry-After header.
+// Rate limiting is disabled when the configured rate is not positive.
+func (s *Server) RateLimitMiddleware(next http.Handler) http.Handler {
+	if s.config.rate_limit <= 0 {
+		return next
+	}
+
+	limiter := newRateLimiter(s.config.rate_limit, s.config.rate_burst)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+
+		allowed, retryAfter := limiter.allow(client, time.Now())
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			utils.WriteErrorResponse(http.StatusTooManyRequests, errors.New("too many requests"), w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// httpMetrics is the registry of the HTTP server metrics, served on GET /metrics with the service metrics
+var httpMetrics = metrics.NewRegistry()
+
+// requestDuration is the latency of the API requests, probes and scrapes are not included
+var requestDuration = httpMetrics.NewHistogram("hot_coffee_http_request_duration_seconds",
+	"Latency of the HTTP requests in seconds.", metrics.DefaultBuckets)
+
+// MetricsMiddleware records the latency of every request in the request duration histogram.
+func (s *Server) MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		requestDuration.Observe(time.Since(start).Seconds())
+	})
+}
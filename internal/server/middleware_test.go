@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{name: "no panic", handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }, want: http.StatusNoContent},
+		{name: "panic", handler: func(w http.ResponseWriter, r *http.Request) { panic("secret failure") }, want: http.StatusInternalServerError},
+		{name: "nil map", handler: func(w http.ResponseWriter, r *http.Request) {
+			var m map[string]int
+			m["x"]++
+		}, want: http.StatusInternalServerError},
+	}
+
+	s := newTestServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := s.RecoverMiddleware(tt.handler)
+
+			// The second request checks that the server keeps serving after a panic
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest("GET", "/orders", nil))
+
+				if w.Code != tt.want {
+					t.Fatalf("request %d status = %d, want %d", i+1, w.Code, tt.want)
+				}
+				if tt.want != http.StatusInternalServerError {
+					continue
+				}
+
+				var response models.ErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+					t.Fatalf("500 response is not JSON: %v: %s", err, w.Body)
+				}
+				if strings.Contains(w.Body.String(), "secret") || strings.Contains(w.Body.String(), "goroutine") {
+					t.Errorf("500 response leaks the panic: %s", w.Body)
+				}
+			}
+		})
+	}
+}
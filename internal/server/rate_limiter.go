@@ -0,0 +1,80 @@
+package server
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// cleanupInterval is how often idle clients are evicted from the limiter
+const cleanupInterval = time.Minute
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter is a token bucket rate limiter keyed by client.
+// Buckets of clients idle for longer than idleTimeout are evicted.
+type rateLimiter struct {
+	mu          sync.Mutex
+	clients     map[string]*tokenBucket
+	rate        float64
+	burst       float64
+	idleTimeout time.Duration
+	lastCleanup time.Time
+}
+
+// newRateLimiter creates a limiter allowing rate requests per second with bursts of up to burst requests.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	// A bucket idle long enough to refill completely is the same as a new one
+	idleTimeout := time.Duration(float64(burst) / rate * float64(time.Second))
+	if idleTimeout < cleanupInterval {
+		idleTimeout = cleanupInterval
+	}
+
+	return &rateLimiter{
+		clients:     make(map[string]*tokenBucket),
+		rate:        rate,
+		burst:       float64(burst),
+		idleTimeout: idleTimeout,
+		lastCleanup: time.Now(),
+	}
+}
+
+// allow takes a token from the client's bucket.
+// If the bucket is empty, it returns false and the time until the next token is available.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastCleanup) >= cleanupInterval {
+		l.evictIdle(now)
+	}
+
+	bucket, exists := l.clients[client]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.clients[client] = bucket
+	}
+
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.lastSeen).Seconds()*l.rate)
+	bucket.lastSeen = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+
+	bucket.tokens--
+	return true, 0
+}
+
+func (l *rateLimiter) evictIdle(now time.Time) {
+	for client, bucket := range l.clients {
+		if now.Sub(bucket.lastSeen) > l.idleTimeout {
+			delete(l.clients, client)
+		}
+	}
+	l.lastCleanup = now
+}
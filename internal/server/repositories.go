@@ -0,0 +1,58 @@
+package server
+
+import (
+	"hot-coffee/internal/dal"
+)
+
+// repositories are shared by all route groups, so that every service sees the writes
+// of the others through the same in-memory caches.
+type repositories struct {
+	inventory   dal.InventoryRepository
+	menu        dal.MenuRepository
+	order       dal.OrderRepository
+	report      dal.ReportRepository
+	adjustment  dal.AdjustmentRepository
+	orderAudit  dal.OrderAuditRepository
+	reservation dal.ReservationRepository
+}
+
+func newRepositories(cfg *Config) *repositories {
+	return &repositories{
+		inventory:   dal.NewInventoryRepository(cfg.inventory_file),
+		menu:        dal.NewMenuRepository(cfg.menu_file),
+		order:       dal.NewOrderRepository(cfg.order_file),
+		report:      dal.NewReportRepository(cfg.report_file),
+		adjustment:  dal.NewAdjustmentRepository(cfg.adjustment_file),
+		orderAudit:  dal.NewOrderAuditRepository(cfg.order_audit_file),
+		reservation: dal.NewReservationRepository(cfg.reservation_file),
+	}
+}
+
+// invalidateCaches makes every repository load its data from storage on the next read.
+func (r *repositories) invalidateCaches() {
+	for _, repository := range []any{r.inventory, r.menu, r.order, r.report, r.adjustment, r.orderAudit, r.reservation} {
+		if cached, ok := repository.(dal.CacheInvalidator); ok {
+			cached.InvalidateCache()
+		}
+	}
+}
+
+// lockWrites blocks the writes of every repository until the returned function is called.
+// The repositories are locked in a fixed order, so two callers can not deadlock. The order is the one
+// the services nest their locked updates in: menu changes pin order prices, and closing an order
+// deducts the inventory and records the sale.
+func (r *repositories) lockWrites() (unlock func()) {
+	var locked []dal.WriteLocker
+	for _, repository := range []any{r.menu, r.order, r.inventory, r.report, r.adjustment, r.orderAudit, r.reservation} {
+		if locker, ok := repository.(dal.WriteLocker); ok {
+			locker.LockWrites()
+			locked = append(locked, locker)
+		}
+	}
+
+	return func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			locked[i].UnlockWrites()
+		}
+	}
+}
@@ -1,18 +1,13 @@
 package server
 
 import (
-	"fmt"
 	"net/http"
 
-	"hot-coffee/internal/dal"
 	"hot-coffee/internal/handler"
 	"hot-coffee/internal/service"
 )
 
 func (s *Server) registerRoutes() {
-	// // basic routes
-	// s.mux.HandleFunc("GET /health", s.HandleHealth)
-
 	// ! 1)	Конфликт имен для интерфейса и структуры
 	// !  	Ошибка: Название интерфейсов и структур одинаковое
 	// !	(например, InventoryService и inventoryService). Это может быть запутывающим.
@@ -35,131 +30,107 @@ func (s *Server) registerRoutes() {
 
 	//  Registering report routes
 	s.registerReportRoutes()
+
+	// Admin routes are served behind the same authentication as the API
+	s.mux.HandleFunc("POST /admin/reload", s.HandleReload)
+	s.mux.HandleFunc("POST /admin/backup", s.HandleBackup)
+	s.mux.HandleFunc("POST /admin/restore", s.HandleRestore)
 }
 
 func (s *Server) registerInventoryRoutes() {
-	// Interfaces
-	inventoryRepository := dal.NewInventoryRepository(s.config.inventory_file)
-	if inventoryRepository == nil {
-		s.logger.PrintWarnMsg("Failed to create inventory repository")
-	}
-
-	inventoryService := service.NewInventoryService(inventoryRepository)
+	inventoryService := service.NewInventoryService(s.repositories.inventory, s.repositories.menu, s.repositories.adjustment)
 	if inventoryService == nil {
 		s.logger.PrintWarnMsg("Failed to create inventory service")
 	}
 
-	inventoryHandler := handler.NewInventoryHandler(inventoryService, s.logger)
+	inventoryHandler := handler.NewInventoryHandler(inventoryService, s.logger, s.config.max_body_size)
 	if inventoryHandler == nil {
 		s.logger.PrintWarnMsg("Failed to create inventory handler")
 	}
 
 	// Routes
 	s.mux.HandleFunc("POST /inventory", inventoryHandler.AddInventoryItem)
+	s.mux.HandleFunc("POST /inventory/import", inventoryHandler.ImportInventoryItems)
 	s.mux.HandleFunc("GET /inventory", inventoryHandler.GetInventoryItems)
+	s.mux.HandleFunc("GET /inventory/low-stock", inventoryHandler.GetLowStockItems)
 	s.mux.HandleFunc("GET /inventory/{id}", inventoryHandler.GetInventoryItem)
 	s.mux.HandleFunc("PUT /inventory/{id}", inventoryHandler.UpdateInventoryItem)
 	s.mux.HandleFunc("DELETE /inventory/{id}", inventoryHandler.DeleteInventoryItem)
+	s.mux.HandleFunc("POST /inventory/{id}/adjust", inventoryHandler.AdjustInventoryItem)
+	s.mux.HandleFunc("GET /inventory/{id}/adjustments", inventoryHandler.GetInventoryAdjustments)
 
 	// logging
 	s.logger.PrintInfoMsg("Inventory routes is registered successfully")
 }
 
 func (s *Server) registerMenuRoutes() {
-	// Interfaces
-	menuRepository := dal.NewMenuRepository(s.config.menu_file)
-	if menuRepository == nil {
-		s.logger.PrintErrorMsg("Failed to create menu repository")
-	}
-
-	menuService := service.NewMenuService(menuRepository)
+	menuService := service.NewMenuService(s.repositories.menu, s.repositories.inventory, s.repositories.order)
 	if menuService == nil {
 		s.logger.PrintErrorMsg("Failed to create menu service")
 	}
 
-	menuHandler := handler.NewMenuHandler(menuService, s.logger)
+	menuHandler := handler.NewMenuHandler(menuService, s.logger, s.config.max_body_size)
 	if menuHandler == nil {
 		s.logger.PrintErrorMsg("Failed to create  handler")
 	}
 
 	// Routes
 	s.mux.HandleFunc("POST /menu", menuHandler.AddMenuItem)
+	s.mux.HandleFunc("POST /menu/prices", menuHandler.UpdateMenuPrices)
 	s.mux.HandleFunc("GET /menu", menuHandler.GetMenuItems)
+	s.mux.HandleFunc("GET /menu/ingredients", menuHandler.GetMenuIngredients)
 	s.mux.HandleFunc("GET /menu/{id}", menuHandler.GetMenuItem)
 	s.mux.HandleFunc("PUT /menu/{id}", menuHandler.UpdateMenuItem)
 	s.mux.HandleFunc("DELETE /menu/{id}", menuHandler.DeleteMenuItem)
+	s.mux.HandleFunc("PATCH /menu/{id}/availability", menuHandler.SetMenuItemAvailability)
 
 	// logging
 	s.logger.PrintInfoMsg("Menu routes is registered successfully")
 }
 
 func (s *Server) registerOrderRoutes() {
-	// Orders
-	orderRepository := dal.NewOrderRepository(s.config.order_file)
-	if orderRepository == nil {
-		s.logger.PrintWarnMsg("Failed to create order repository")
-	}
-
-	menuRepository := dal.NewMenuRepository(s.config.menu_file)
-	if menuRepository == nil {
-		s.logger.PrintErrorMsg("Failed to create menu repository")
-	}
-
-	inventoryRepository := dal.NewInventoryRepository(s.config.inventory_file)
-	if inventoryRepository == nil {
-		s.logger.PrintWarnMsg("Failed to create inventory repository")
-	}
-
-	reportRepository := dal.NewReportRepository(s.config.report_file)
-	if reportRepository == nil {
-		s.logger.PrintWarnMsg("Failed to create report repository")
-	}
-
-	orderService := service.NewOrderService(orderRepository, menuRepository, inventoryRepository, reportRepository)
+	orderService := service.NewOrderService(s.repositories.order, s.repositories.menu, s.repositories.inventory, s.repositories.report, s.repositories.orderAudit, s.repositories.reservation, s.config.tax_rate)
 	if orderService == nil {
 		s.logger.PrintWarnMsg("Failed to create order service")
+	} else if reserved, err := orderService.BackfillReservations(); err != nil {
+		s.logger.PrintWarnMsg("Failed to reserve the inventory of open orders: %v", err)
+	} else if reserved > 0 {
+		// Open orders saved without a reservation hold their stock like new orders from now on
+		s.logger.PrintInfoMsg("Reserved the inventory of %d open orders", reserved)
 	}
 
-	orderHandler := handler.NewOrderHandler(orderService, s.logger)
+	orderHandler := handler.NewOrderHandler(orderService, s.logger, s.config.max_body_size)
 	if orderHandler == nil {
 		s.logger.PrintWarnMsg("Failed to create order handler")
 	}
 
 	// Order routes
 	s.mux.HandleFunc("POST /orders", orderHandler.CreateOrder)
+	s.mux.HandleFunc("POST /orders/check", orderHandler.CheckOrder)
+	s.mux.HandleFunc("POST /orders/close-all", orderHandler.CloseAllOrders)
 	s.mux.HandleFunc("GET /orders", orderHandler.RetrieveOrders)
+	s.mux.HandleFunc("GET /orders/export", orderHandler.ExportOrders)
+	s.mux.HandleFunc("GET /orders/queue", orderHandler.RetrieveOrderQueue)
 	s.mux.HandleFunc("GET /orders/{id}", orderHandler.RetrieveOrder)
 	s.mux.HandleFunc("PUT /orders/{id}", orderHandler.UpdateOrder)
+	s.mux.HandleFunc("PATCH /orders/{id}", orderHandler.PatchOrder)
 	s.mux.HandleFunc("DELETE /orders/{id}", orderHandler.DeleteOrder)
 	s.mux.HandleFunc("POST /orders/{id}/close", orderHandler.CloseOrder)
+	s.mux.HandleFunc("GET /orders/{id}/history", orderHandler.RetrieveOrderHistory)
+	s.mux.HandleFunc("POST /orders/{id}/reserve", orderHandler.ReserveInventory)
+	s.mux.HandleFunc("POST /orders/{id}/release", orderHandler.ReleaseInventory)
+
+	// Prep list, ingredient impact and live menu, served by the order handler since they are built from orders and menu recipes
+	s.mux.HandleFunc("POST /inventory/requirements", orderHandler.GetRequirements)
+	s.mux.HandleFunc("GET /inventory/{id}/affected-orders", orderHandler.GetAffectedOrders)
+	s.mux.HandleFunc("GET /menu/available", orderHandler.GetAvailableMenu)
 
 	// logging
 	s.logger.PrintInfoMsg("Order routes is registered successfully")
 }
 
 func (s *Server) registerReportRoutes() {
-	// Interfaces
-	orderRepository := dal.NewOrderRepository(s.config.order_file)
-	if orderRepository == nil {
-		s.logger.PrintWarnMsg("Failed to create order repository")
-	}
-
-	menuRepository := dal.NewMenuRepository(s.config.menu_file)
-	if menuRepository == nil {
-		s.logger.PrintErrorMsg("Failed to create menu repository")
-	}
-
-	inventoryRepository := dal.NewInventoryRepository(s.config.inventory_file)
-	if inventoryRepository == nil {
-		s.logger.PrintWarnMsg("Failed to create inventory repository")
-	}
-
-	reportRepository := dal.NewReportRepository(s.config.report_file)
-	if reportRepository == nil {
-		s.logger.PrintWarnMsg("Failed to create report repository")
-	}
-
-	reportService := service.NewReportService(orderRepository, menuRepository, inventoryRepository, reportRepository)
+	reportService := service.NewReportService(s.repositories.order, s.repositories.menu, s.repositories.inventory, s.repositories.report, s.config.tax_rate)
 	if reportService == nil {
 		s.logger.PrintWarnMsg("Failed to create report service")
 	}
@@ -172,6 +143,13 @@ func (s *Server) registerReportRoutes() {
 	// Aggregation routes
 	s.mux.HandleFunc("GET /reports/total-sales", reportHandler.GetTotalSales)
 	s.mux.HandleFunc("GET /reports/popular-items", reportHandler.GetPopularItems)
+	s.mux.HandleFunc("GET /reports/volume", reportHandler.GetOrderVolume)
+	s.mux.HandleFunc("GET /reports/inventory-usage", reportHandler.GetIngredientUsage)
+	s.mux.HandleFunc("GET /reports/order-counts", reportHandler.GetOrderCounts)
+	s.mux.HandleFunc("GET /reports/average-order-value", reportHandler.GetAverageOrderValue)
+	s.mux.HandleFunc("GET /reports/avg-fulfillment-time", reportHandler.GetAverageFulfillmentTime)
+	s.mux.HandleFunc("GET /reports/inventory-value", reportHandler.GetInventoryValue)
+	s.mux.HandleFunc("GET /reports/revenue-by-item", reportHandler.GetRevenueByItem)
 
 	// logging
 	s.logger.PrintInfoMsg("Report routes is registered successfully")
@@ -182,15 +160,15 @@ func (s *Server) RequestMiddleware(next http.Handler) http.Handler {
 		http.MethodGet:    true,
 		http.MethodPost:   true,
 		http.MethodPut:    true,
+		http.MethodPatch:  true,
 		http.MethodDelete: true,
 	}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		s.logger.PrintInfoMsg(fmt.Sprintf("Request %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr))
+	return s.logger.LogRequestMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !allowedMethods[r.Method] {
 			return
 		}
 
 		next.ServeHTTP(w, r)
-	})
+	}))
 }
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPrettyResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantIndent bool
+	}{
+		{name: "inventory", target: "/inventory"},
+		{name: "pretty inventory", target: "/inventory?pretty=true", wantIndent: true},
+		{name: "inventory item", target: "/inventory/milk"},
+		{name: "pretty inventory item", target: "/inventory/milk?pretty=true", wantIndent: true},
+		{name: "orders", target: "/orders"},
+		{name: "pretty orders", target: "/orders?pretty=true", wantIndent: true},
+		{name: "pretty order", target: "/orders/orders1?pretty=true", wantIndent: true},
+		{name: "order", target: "/orders/orders1"},
+	}
+
+	s := newTestServer(t)
+	if w := serve(t, s, "POST", "/inventory", `{"ingredient_id":"milk","name":"Milk","quantity":1000,"unit":"ml"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST /inventory status = %d: %s", w.Code, w.Body)
+	}
+	if w := serve(t, s, "POST", "/menu", `{"product_id":"latte","name":"Latte","description":"Espresso with milk","price":3.5,"ingredients":[{"ingredient_id":"milk","quantity":200}]}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST /menu status = %d: %s", w.Code, w.Body)
+	}
+	if w := serve(t, s, "POST", "/orders", `{"order_id":"orders1","customer_name":"Alice","items":[{"product_id":"latte","quantity":1}]}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST /orders status = %d: %s", w.Code, w.Body)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, s, "GET", tt.target, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s status = %d: %s", tt.target, w.Code, w.Body)
+			}
+
+			if indented := strings.Contains(w.Body.String(), "\n "); indented != tt.wantIndent {
+				t.Errorf("GET %s indented = %v, want %v: %s", tt.target, indented, tt.wantIndent, w.Body)
+			}
+		})
+	}
+}
@@ -1,16 +1,24 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"time"
 
+	"hot-coffee/internal/service"
 	"hot-coffee/internal/utils"
 	"hot-coffee/pkg/logger"
+	"hot-coffee/pkg/metrics"
 )
 
 type Server struct {
-	config *Config
-	logger *logger.Logger
-	mux    *http.ServeMux
+	config     *Config
+	logger     *logger.Logger
+	mux        *http.ServeMux
+	httpServer *http.Server
+
+	repositories *repositories
 }
 
 // New server
@@ -19,12 +27,50 @@ func New(config *Config, LOGGER *logger.Logger) *Server {
 		config: config,
 		logger: LOGGER,
 		mux:    http.NewServeMux(),
+
+		repositories: newRepositories(config),
+	}
+
+	utils.SetErrorCoder(service.ErrorCode)
+
+	// Statuses saved in a legacy casing are rewritten once, before any request can write the orders
+	if migrated, err := s.repositories.order.MigrateStatuses(); err != nil {
+		s.logger.PrintWarnMsg("Failed to migrate the order statuses: %v", err)
+	} else if migrated > 0 {
+		s.logger.PrintInfoMsg("Migrated the status of %d orders to the canonical casing", migrated)
 	}
 
 	s.registerRoutes()
+
+	// Health and metrics routes are served before the other middlewares to keep probes and scrapes cheap
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", s.HandleHealth)
+	mux.HandleFunc("GET /ready", s.HandleReady)
+	mux.Handle("GET /metrics", s.metricsHandler())
+	mux.Handle("/", s.MetricsMiddleware(s.RequestMiddleware(s.RateLimitMiddleware(s.AuthMiddleware(s.withJSONFallback(s.mux))))))
+
+	// The timeouts keep slow or stalled clients from holding connections open indefinitely
+	s.httpServer = &http.Server{
+		Addr:         config.port,
+		Handler:      s.CORSMiddleware(s.RequestIDMiddleware(s.RecoverMiddleware(mux))),
+		ReadTimeout:  config.read_timeout,
+		WriteTimeout: config.write_timeout,
+		IdleTimeout:  config.idle_timeout,
+	}
+
 	return s
 }
 
+// metricsHandler serves the service and HTTP metrics. The metrics require the API key
+// unless they are configured public, so scrapers without the key can read them.
+func (s *Server) metricsHandler() http.Handler {
+	handler := metrics.Handler(service.Metrics, httpMetrics)
+	if s.config.public_metrics || s.config.api_key == "" {
+		return handler
+	}
+	return s.AuthMiddleware(handler)
+}
+
 // TODO: Продолжить по видео REST API на Golang
 
 // Start the server
@@ -38,46 +84,30 @@ func (s *Server) Start() error {
 	//     return fmt.Errorf("dependencies are not satisfied")
 	// }
 
-	//  TODO: Использовать http.Server для более гибкой настройки сервера (таймауты, shutdown)
-	// server := &http.Server{
-	// 	Addr:    s.config.port,
-	// 	Handler: s.RequestMiddleware(s.mux),
-	// TODO: Установить таймауты для соединений и запросов
-	// ReadTimeout:  10 * time.Second,
-	// WriteTimeout: 10 * time.Second,
-	// IdleTimeout:  120 * time.Second,
-	// }
-
-	// TODO: Использовать горутины для асинхронного запуска сервера (обработка запросов)
-	// go func() {
-	// 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-	// 		s.logger.PrintErrorMsg("Server error: " + err.Error())
-	// 	}
-	// }()
-
-	// TODO: Обработать сигналы для корректного завершения работы (graceful shutdown)
-	// signalChannel := make(chan os.Signal, 1)
-	// signal.Notify(signalChannel, syscall.SIGINT, syscall.SIGTERM)
-	// sig := <-signalChannel
-	// s.logger.PrintInfoMsg(fmt.Sprintf("Received signal: %s. Shutting down...", sig))
-	// return s.Shutdown(server)
+	// Data files are not created here, the repositories treat a missing file as an empty
+	// collection and create it on the first write, so existing data survives restarts
 
-	utils.CreateFile(s.config.inventory_file)
-	utils.CreateFile(s.config.menu_file)
-	utils.CreateFile(s.config.order_file)
-	utils.CreateFile(s.config.report_file)
-
-	mux := s.RequestMiddleware(s.mux)
+	err := s.httpServer.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
 
-	return http.ListenAndServe(s.config.port, mux)
+	return nil
 }
 
-// Shutdown the server
-func (s *Server) Shutdown() error {
+// Shutdown gracefully stops the server.
+// It stops accepting new connections and waits for in-flight requests to finish
+// until the context is done.
+func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.PrintInfoMsg("Stopping the server")
 
-	// TODO: Write server graceful shutdown method
+	start := time.Now()
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		s.logger.PrintErrorMsg("Server shutdown failed after %s: %v", time.Since(start), err)
+		return err
+	}
 
-	s.logger.PrintInfoMsg("Server gracefully stopped")
+	s.logger.PrintInfoMsg("Server gracefully stopped, requests drained in %s", time.Since(start))
 	return nil
 }
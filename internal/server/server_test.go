@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hot-coffee/pkg/logger"
+)
+
+// startTestServer serves s on a free local port until the test ends and returns its address.
+func startTestServer(t *testing.T, s *Server) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.httpServer.Serve(ln)
+	t.Cleanup(func() { s.httpServer.Close() })
+
+	return ln.Addr().String()
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	s := newTestServer(t)
+	started := make(chan struct{})
+	var finished atomic.Bool
+	s.mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		finished.Store(true)
+	})
+	addr := startTestServer(t, s)
+
+	type result struct {
+		status int
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		r, _ := http.NewRequest("GET", "http://"+addr+"/slow", nil)
+		r.Header.Set("X-API-Key", testAPIKey)
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		resp.Body.Close()
+		done <- result{status: resp.StatusCode}
+	}()
+
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	// Shutdown returns only once the in-flight request has been answered
+	if !finished.Load() {
+		t.Error("Shutdown() returned before the in-flight request completed")
+	}
+	if res := <-done; res.err != nil || res.status != http.StatusOK {
+		t.Errorf("in-flight request = %d, %v, want 200", res.status, res.err)
+	}
+
+	if _, err := http.Get("http://" + addr + "/health"); err == nil {
+		t.Error("the server accepted a request after Shutdown()")
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	const body = `{"ingredient_id":"milk","name":"Milk","quantity":1000,"unit":"ml"}`
+
+	tests := []struct {
+		name     string
+		sent     string
+		wantCode bool
+	}{
+		{name: "complete body", sent: body, wantCode: true},
+		{name: "stalled body", sent: body[:10]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig("configs/server.yaml", ":0", t.TempDir())
+			config.SetAPIKey(testAPIKey)
+			config.SetRateLimit(0, 0)
+			config.SetTimeouts(200*time.Millisecond, time.Second, time.Second)
+			addr := startTestServer(t, New(config, logger.New(slog.LevelError, logger.FormatText, io.Discard)))
+
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			start := time.Now()
+			fmt.Fprintf(conn, "POST /inventory HTTP/1.1\r\nHost: %s\r\nX-API-Key: %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s",
+				addr, testAPIKey, len(body), tt.sent)
+
+			// The client gives up well after the server should have cut the request off
+			conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if time.Since(start) > 2*time.Second {
+				t.Fatalf("the request was not cut off after %s", time.Since(start))
+			}
+
+			if tt.wantCode {
+				if err != nil || resp.StatusCode != http.StatusCreated {
+					t.Errorf("response = %v, %v, want 201", resp, err)
+				}
+				return
+			}
+			// A stalled body is answered with an error or the connection is closed
+			if err == nil && resp.StatusCode < http.StatusBadRequest {
+				t.Errorf("stalled request status = %d, want an error status", resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"hot-coffee/models"
+)
+
+// validateComboComponents checks the components of a combo on their own: each must be another product,
+// listed once with a positive quantity. Combos can not have sizes.
+func validateComboComponents(item models.MenuItem) error {
+	if len(item.Sizes) > 0 {
+		return ErrNotValidComboSizes
+	}
+
+	seen := make(map[string]bool)
+	for _, component := range item.Components {
+		if component.ProductID == "" || strings.Contains(component.ProductID, " ") || component.ProductID == item.ID {
+			return ErrNotValidComboComponent
+		}
+		if component.Quantity <= 0 || seen[component.ProductID] {
+			return ErrNotValidComboComponent
+		}
+		seen[component.ProductID] = true
+	}
+	return nil
+}
+
+// checkComboReferences checks the components of a combo against the menu it is saved to.
+// The following errors may be returned:
+// - ErrComboComponentNotFound, wrapped with the product ID, if a component is not on the menu.
+// - ErrComboCycle, wrapped with the cycle, if a component contains the combo through other combos.
+func checkComboReferences(item models.MenuItem, menuMap map[string]models.MenuItem) error {
+	for _, component := range item.Components {
+		if _, exists := menuMap[component.ProductID]; !exists {
+			return fmt.Errorf("%w: %s", ErrComboComponentNotFound, component.ProductID)
+		}
+	}
+
+	if cycle := comboCycle(item.ID, menuMap); cycle != nil {
+		return fmt.Errorf("%w: %s", ErrComboCycle, strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// comboCycle returns the product IDs of a cycle reachable from the menu item through combo components,
+// starting and ending with the same ID. Returns nil if there is no cycle.
+func comboCycle(id string, menuMap map[string]models.MenuItem) []string {
+	path := []string{}
+	onPath := make(map[string]bool)
+	checked := make(map[string]bool)
+
+	var visit func(id string) bool
+	visit = func(id string) bool {
+		path = append(path, id)
+		if onPath[id] {
+			return true
+		}
+		if checked[id] {
+			path = path[:len(path)-1]
+			return false
+		}
+
+		onPath[id] = true
+		for _, component := range menuMap[id].Components {
+			if visit(component.ProductID) {
+				return true
+			}
+		}
+		onPath[id] = false
+		checked[id] = true
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if !visit(id) {
+		return nil
+	}
+	start := slices.Index(path, path[len(path)-1])
+	return path[start:]
+}
+
+// combosWithComponent returns the IDs of the combos listing the product as a component.
+func combosWithComponent(productID string, menuItems []models.MenuItem) []string {
+	comboIDs := []string{}
+	for _, item := range menuItems {
+		if slices.ContainsFunc(item.Components, func(c models.MenuItemComponent) bool { return c.ProductID == productID }) {
+			comboIDs = append(comboIDs, item.ID)
+		}
+	}
+	return comboIDs
+}
+
+// indexMenuItems returns the menu items by their ID with the combos resolved into plain menu items:
+// the recipes of the components, times their quantity, are added to the ingredients of the combo,
+// a combo without a price of its own costs the sum of its components, and it is unavailable while
+// any component is. Combos with a missing component or a cycle are left out, so ordering them fails
+// like ordering an unknown product.
+func indexMenuItems(menuItems []models.MenuItem) map[string]models.MenuItem {
+	itemsByID := make(map[string]models.MenuItem)
+	for _, item := range menuItems {
+		itemsByID[item.ID] = item
+	}
+
+	resolved := make(map[string]models.MenuItem)
+	resolving := make(map[string]bool)
+
+	var resolve func(id string) (models.MenuItem, bool)
+	resolve = func(id string) (models.MenuItem, bool) {
+		if item, exists := resolved[id]; exists {
+			return item, true
+		}
+		item, exists := itemsByID[id]
+		if !exists || resolving[id] {
+			return models.MenuItem{}, false
+		}
+		if !item.IsCombo() {
+			resolved[id] = item
+			return item, true
+		}
+
+		resolving[id] = true
+		defer delete(resolving, id)
+
+		combo := item
+		combo.Ingredients = slices.Clone(item.Ingredients)
+		componentsPrice := 0.0
+		for _, c := range item.Components {
+			component, ok := resolve(c.ProductID)
+			if !ok {
+				return models.MenuItem{}, false
+			}
+
+			for _, ingredient := range component.Ingredients {
+				ingredient.Quantity *= float64(c.Quantity)
+				combo.Ingredients = append(combo.Ingredients, ingredient)
+			}
+			componentsPrice += component.Price * float64(c.Quantity)
+			combo.Available = combo.Available && component.Available
+		}
+		if combo.Price == 0 {
+			combo.Price = roundCents(componentsPrice)
+		}
+
+		resolved[id] = combo
+		return combo, true
+	}
+
+	for _, item := range menuItems {
+		resolve(item.ID)
+	}
+	return resolved
+}
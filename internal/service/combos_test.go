@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestOrderCombo(t *testing.T) {
+	tests := []struct {
+		name         string
+		combo        models.MenuItem
+		quantity     float64
+		wantPrice    float64
+		wantMilk     float64
+		wantEspresso float64
+	}{
+		{
+			name: "priced as its components",
+			combo: models.MenuItem{
+				ID: "duo", Name: "Duo", Available: true,
+				Components: []models.MenuItemComponent{{ProductID: "latte", Quantity: 1}, {ProductID: "cappuccino", Quantity: 1}},
+			},
+			quantity: 1, wantPrice: 7.5, wantMilk: 650, wantEspresso: 97,
+		},
+		{
+			name: "overridden price",
+			combo: models.MenuItem{
+				ID: "duo", Name: "Duo", Price: 6, Available: true,
+				Components: []models.MenuItemComponent{{ProductID: "latte", Quantity: 1}, {ProductID: "cappuccino", Quantity: 1}},
+			},
+			quantity: 1, wantPrice: 6, wantMilk: 650, wantEspresso: 97,
+		},
+		{
+			name: "components and own ingredients",
+			combo: models.MenuItem{
+				ID: "double-latte", Name: "Double latte", Available: true,
+				Ingredients: []models.MenuItemIngredient{{IngredientID: "espresso", Quantity: 1}},
+				Components:  []models.MenuItemComponent{{ProductID: "latte", Quantity: 2}},
+			},
+			quantity: 2, wantPrice: 14, wantMilk: 200, wantEspresso: 94,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0)
+			if _, err := s.MenuRepository.AddMenuItem(tt.combo); err != nil {
+				t.Fatal(err)
+			}
+
+			order, err := s.AddOrder(models.Order{CustomerName: "Alice", Items: []models.OrderItem{{ProductID: tt.combo.ID, Quantity: tt.quantity}}})
+			if err != nil {
+				t.Fatalf("AddOrder() error = %v", err)
+			}
+			if got := retrieveOrder(t, s, order.ID).TotalPrice; got != tt.wantPrice {
+				t.Errorf("total price = %g, want %g", got, tt.wantPrice)
+			}
+
+			if err := s.CloseOrder(order.ID); err != nil {
+				t.Fatalf("CloseOrder() error = %v", err)
+			}
+
+			// Every component's recipe is deducted
+			for id, want := range map[string]float64{"milk": tt.wantMilk, "espresso": tt.wantEspresso} {
+				item, err := s.InventoryRepository.GetItemById(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if item.Quantity != want {
+					t.Errorf("%s left = %g, want %g", id, item.Quantity, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateComboComponents(t *testing.T) {
+	tests := []struct {
+		name       string
+		components []models.MenuItemComponent
+		sizes      []models.MenuItemSize
+		wantErr    error
+	}{
+		{name: "valid", components: []models.MenuItemComponent{{ProductID: "latte", Quantity: 1}}},
+		{name: "itself", components: []models.MenuItemComponent{{ProductID: "duo", Quantity: 1}}, wantErr: ErrNotValidComboComponent},
+		{name: "repeated", components: []models.MenuItemComponent{{ProductID: "latte", Quantity: 1}, {ProductID: "latte", Quantity: 1}}, wantErr: ErrNotValidComboComponent},
+		{name: "zero quantity", components: []models.MenuItemComponent{{ProductID: "latte"}}, wantErr: ErrNotValidComboComponent},
+		{name: "sizes", components: []models.MenuItemComponent{{ProductID: "latte", Quantity: 1}}, sizes: []models.MenuItemSize{{Name: "large"}}, wantErr: ErrNotValidComboSizes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := models.MenuItem{ID: "duo", Components: tt.components, Sizes: tt.sizes}
+			if err := validateComboComponents(item); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateComboComponents() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package service
 
 import (
 	"errors"
+	"strings"
+
+	"hot-coffee/models"
 )
 
 var (
@@ -13,6 +16,16 @@ var (
 	ErrNotValidIngredientName error = errors.New("ingredient name is not valid")
 	ErrNotValidQuantity       error = errors.New("quantity is not valid")
 	ErrNotValidUnit           error = errors.New("ingredient unit is not valid")
+	ErrIncompatibleUnit       error = errors.New("ingredient unit is incompatible with the inventory unit")
+	ErrNotValidReorderLevel   error = errors.New("reorder level must not be negative")
+	ErrNotValidCostPerUnit    error = errors.New("cost per unit must not be negative")
+	ErrNotValidDelta          error = errors.New("adjustment delta must be a non-zero number")
+	ErrNotValidReason         error = errors.New("adjustment reason cannot be empty")
+	ErrNegativeQuantity       error = errors.New("adjustment would make the quantity negative")
+	ErrInventoryItemInUse     error = errors.New("ingredient is used by menu items")
+	ErrETagRequired           error = errors.New("If-Match header with the item ETag is required")
+	ErrETagMismatch           error = errors.New("item was modified, If-Match does not match the current ETag")
+	ErrEmptyIDList            error = errors.New("ids must list at least one ingredient ID")
 
 	ErrNotValidMenuID           error = errors.New("product ID is not valid")
 	ErrNotUniqueMenuID          error = errors.New("product ID must be unique")
@@ -21,6 +34,18 @@ var (
 	ErrNotValidPrice            error = errors.New("product price must be greater than 0")
 	ErrDuplicateMenuIngredients error = errors.New("the ingredients of the product must not be repeated")
 	ErrNotValidIngredints       error = errors.New("product ingredients is not valid")
+	ErrNotValidRecipeQuantity   error = errors.New("recipe ingredient quantity must be greater than 0")
+	ErrNotValidAvailability     error = errors.New("product availability must be set")
+	ErrMenuItemInUse            error = errors.New("product is used by open orders")
+	ErrNotValidMenuSize         error = errors.New("product sizes must have unique, non-empty names")
+	ErrNotValidComboComponent   error = errors.New("combo components must be other products, listed once with a positive quantity")
+	ErrNotValidComboSizes       error = errors.New("combo products can not have sizes")
+	ErrComboComponentNotFound   error = errors.New("combo component not found")
+	ErrComboCycle               error = errors.New("combo components must not reference the combo itself")
+	ErrMenuItemInCombo          error = errors.New("product is a component of combo products")
+	ErrEmptyPriceUpdate         error = errors.New("price update must contain at least one item")
+	ErrDuplicatePriceUpdate     error = errors.New("product is listed more than once in the price update")
+	ErrPriceUpdateRejected      error = errors.New("price update rejected, no prices were changed")
 
 	ErrNotValidOrderID           error = errors.New("order ID is not valid")
 	ErrNotValidOrderCustomerName error = errors.New("order CustomeName is not valid")
@@ -28,13 +53,138 @@ var (
 	ErrNotValidOrderItems        error = errors.New("order items is not valid ")
 	ErrNotValidOrderProductID    error = errors.New("product ID is not valid")
 	ErrNotValidStatusField       error = errors.New("status field cannot be set manually")
+	ErrUnknownStatus             error = errors.New("order has an unknown status")
 	ErrNotValidCreatedAt         error = errors.New("created_at field cannot be set manually")
+	ErrNotValidOrderIDField      error = errors.New("order_id field cannot be changed")
+	ErrEmptyOrderPatch           error = errors.New("at least one of customer_name or notes must be set")
+	ErrNotValidOrderNotes        error = errors.New("order notes must not exceed 500 characters")
+	ErrNotValidItemInstructions  error = errors.New("item instructions must not exceed 500 characters")
+	ErrFractionalQuantity        error = errors.New("quantity must be a whole number for items not sold by weight")
+	ErrNotValidOrderTag          error = errors.New("order tags must be non-empty, without spaces and at most 32 characters")
+	ErrNotValidOrderPriority     error = errors.New("order priority must be between 0 and 5")
+	ErrNotValidDiscountType      error = errors.New("discount type must be 'percentage' or 'fixed'")
+	ErrNotValidDiscountPercent   error = errors.New("percentage discount must be between 0 and 100")
+	ErrNotValidDiscountAmount    error = errors.New("fixed discount must not be negative or exceed the order subtotal")
+	ErrMalformedCreatedAt        error = errors.New("created_at is not a valid RFC3339 timestamp")
+	ErrFutureCreatedAt           error = errors.New("created_at must not be in the future")
 
 	ErrOrderProductNotFound       error = errors.New("product not found")
+	ErrOrderSizeNotFound          error = errors.New("product size not found")
+	ErrProductUnavailable         error = errors.New("product is currently unavailable")
 	ErrNotEnoughInventoryQuantity error = errors.New("not enough ingredient quantity")
 	ErrProductNotFound            error = errors.New("the product is not on the menu")
 	ErrInventoryItemNotFound      error = errors.New("ingredient not found")
 	ErrOrderClosed                error = errors.New("order is closed")
+	ErrOrderAlreadyClosed         error = errors.New("order is already closed")
+	ErrNoReservation              error = errors.New("order has no inventory reservation")
 
 	ErrNotUniqueOrder error = errors.New("order ID must be unique")
+
+	ErrNotValidTimeRange error = errors.New("the start of the time range must not be after its end")
+	ErrNotValidBucket    error = errors.New("bucket must be 'hour' or 'day'")
+	ErrNotValidSortField error = errors.New("sort must be 'created_at', 'customer_name' or 'status'")
+	ErrNotValidSortOrder error = errors.New("order must be 'asc' or 'desc'")
 )
+
+// ValidationError is returned when a request body has one or more invalid fields.
+type ValidationError struct {
+	Errors []models.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldError := range e.Errors {
+		messages[i] = fieldError.Field + ": " + fieldError.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// errorCodes maps the service errors to the stable codes reported to clients.
+var errorCodes = map[error]string{
+	ErrNoOrder:                    "ORDER_NOT_FOUND",
+	ErrNotValidIngredientID:       "INVALID_INGREDIENT_ID",
+	ErrNotUniqueID:                "DUPLICATE_INGREDIENT_ID",
+	ErrNoItem:                     "ITEM_NOT_FOUND",
+	ErrNotValidIngredientName:     "INVALID_INGREDIENT_NAME",
+	ErrNotValidQuantity:           "INVALID_QUANTITY",
+	ErrNotValidUnit:               "INVALID_UNIT",
+	ErrIncompatibleUnit:           "INCOMPATIBLE_UNIT",
+	ErrNotValidReorderLevel:       "INVALID_REORDER_LEVEL",
+	ErrNotValidCostPerUnit:        "INVALID_COST_PER_UNIT",
+	ErrNotValidDelta:              "INVALID_DELTA",
+	ErrNotValidReason:             "INVALID_REASON",
+	ErrNegativeQuantity:           "NEGATIVE_QUANTITY",
+	ErrInventoryItemInUse:         "INVENTORY_ITEM_IN_USE",
+	ErrETagRequired:               "ETAG_REQUIRED",
+	ErrETagMismatch:               "ETAG_MISMATCH",
+	ErrEmptyIDList:                "EMPTY_ID_LIST",
+	ErrNotValidMenuID:             "INVALID_MENU_ID",
+	ErrNotUniqueMenuID:            "DUPLICATE_MENU_ID",
+	ErrNotValidMenuName:           "INVALID_MENU_NAME",
+	ErrNotValidMenuDescription:    "INVALID_MENU_DESCRIPTION",
+	ErrNotValidPrice:              "INVALID_PRICE",
+	ErrDuplicateMenuIngredients:   "DUPLICATE_MENU_INGREDIENTS",
+	ErrNotValidIngredints:         "INVALID_MENU_INGREDIENTS",
+	ErrNotValidRecipeQuantity:     "INVALID_RECIPE_QUANTITY",
+	ErrNotValidAvailability:       "INVALID_AVAILABILITY",
+	ErrMenuItemInUse:              "MENU_ITEM_IN_USE",
+	ErrNotValidMenuSize:           "INVALID_MENU_SIZE",
+	ErrNotValidComboComponent:     "INVALID_COMBO_COMPONENT",
+	ErrNotValidComboSizes:         "INVALID_COMBO_SIZES",
+	ErrComboComponentNotFound:     "COMBO_COMPONENT_NOT_FOUND",
+	ErrComboCycle:                 "COMBO_CYCLE",
+	ErrMenuItemInCombo:            "MENU_ITEM_IN_COMBO",
+	ErrEmptyPriceUpdate:           "EMPTY_PRICE_UPDATE",
+	ErrDuplicatePriceUpdate:       "DUPLICATE_PRICE_UPDATE",
+	ErrPriceUpdateRejected:        "PRICE_UPDATE_REJECTED",
+	ErrNotValidOrderID:            "INVALID_ORDER_ID",
+	ErrNotValidOrderCustomerName:  "INVALID_CUSTOMER_NAME",
+	ErrDuplicateOrderItems:        "DUPLICATE_ORDER_ITEMS",
+	ErrNotValidOrderItems:         "INVALID_ORDER_ITEMS",
+	ErrNotValidOrderProductID:     "INVALID_ORDER_PRODUCT_ID",
+	ErrNotValidStatusField:        "STATUS_READ_ONLY",
+	ErrUnknownStatus:              "UNKNOWN_STATUS",
+	ErrNotValidCreatedAt:          "CREATED_AT_READ_ONLY",
+	ErrNotValidOrderIDField:       "ORDER_ID_READ_ONLY",
+	ErrEmptyOrderPatch:            "EMPTY_ORDER_PATCH",
+	ErrNotValidOrderNotes:         "INVALID_ORDER_NOTES",
+	ErrNotValidItemInstructions:   "INVALID_ITEM_INSTRUCTIONS",
+	ErrFractionalQuantity:         "FRACTIONAL_QUANTITY",
+	ErrNotValidOrderTag:           "INVALID_ORDER_TAG",
+	ErrNotValidOrderPriority:      "INVALID_ORDER_PRIORITY",
+	ErrNotValidDiscountType:       "INVALID_DISCOUNT_TYPE",
+	ErrNotValidDiscountPercent:    "INVALID_DISCOUNT_PERCENTAGE",
+	ErrNotValidDiscountAmount:     "INVALID_DISCOUNT_AMOUNT",
+	ErrMalformedCreatedAt:         "MALFORMED_CREATED_AT",
+	ErrFutureCreatedAt:            "FUTURE_CREATED_AT",
+	ErrOrderProductNotFound:       "ORDER_PRODUCT_NOT_FOUND",
+	ErrOrderSizeNotFound:          "ORDER_SIZE_NOT_FOUND",
+	ErrProductUnavailable:         "PRODUCT_UNAVAILABLE",
+	ErrNotEnoughInventoryQuantity: "INSUFFICIENT_INVENTORY",
+	ErrProductNotFound:            "PRODUCT_NOT_FOUND",
+	ErrInventoryItemNotFound:      "INVENTORY_ITEM_NOT_FOUND",
+	ErrOrderClosed:                "ORDER_CLOSED",
+	ErrOrderAlreadyClosed:         "ORDER_ALREADY_CLOSED",
+	ErrNoReservation:              "RESERVATION_NOT_FOUND",
+	ErrNotUniqueOrder:             "DUPLICATE_ORDER_ID",
+	ErrNotValidTimeRange:          "INVALID_TIME_RANGE",
+	ErrNotValidBucket:             "INVALID_BUCKET",
+	ErrNotValidSortField:          "INVALID_SORT_FIELD",
+	ErrNotValidSortOrder:          "INVALID_SORT_ORDER",
+}
+
+// ErrorCode returns the stable code of a service error, looking through wrapped errors.
+// Returns an empty string for errors without a code.
+func ErrorCode(err error) string {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return "VALIDATION_FAILED"
+	}
+
+	for ; err != nil; err = errors.Unwrap(err) {
+		if code, ok := errorCodes[err]; ok {
+			return code
+		}
+	}
+	return ""
+}
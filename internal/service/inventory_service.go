@@ -1,8 +1,18 @@
 package service
 
 import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"hot-coffee/internal/dal"
 	"hot-coffee/models"
@@ -10,21 +20,29 @@ import (
 
 type InventoryService interface {
 	AddInventoryItem(i models.InventoryItem) error
-	RetrieveInventoryItems() ([]byte, error)
-	RetrieveInventoryItem(id string) ([]byte, error)
-	UpdateInventoryItem(id string, item models.InventoryItem) error
-	DeleteInventoryItem(id string) error
+	RetrieveInventoryItems(category string) ([]byte, error)
+	RetrieveInventoryItemsByIDs(ids []string) ([]models.InventoryLookup, error)
+	InventoryLastModified() (time.Time, error)
+	AdjustInventoryItem(id string, delta float64, reason string) (models.InventoryAdjustment, error)
+	RetrieveAdjustments(id string) ([]models.InventoryAdjustment, error)
+	RetrieveInventoryItem(id string) ([]byte, string, error)
+	UpdateInventoryItem(id string, item models.InventoryItem, ifMatch string) (string, error)
+	DeleteInventoryItem(id string, force bool) error
+	GetLowStockItems() ([]models.InventoryItem, error)
+	ImportInventoryCSV(data io.Reader) (models.ImportSummary, error)
 }
 
 type inventoryService struct {
-	InventoryRepository dal.InventoryRepository
+	InventoryRepository  dal.InventoryRepository
+	MenuRepository       dal.MenuRepository
+	AdjustmentRepository dal.AdjustmentRepository
 }
 
-func NewInventoryService(repo dal.InventoryRepository) *inventoryService {
-	if repo == nil {
+func NewInventoryService(repo dal.InventoryRepository, mr dal.MenuRepository, ar dal.AdjustmentRepository) *inventoryService {
+	if repo == nil || mr == nil || ar == nil {
 		return nil
 	}
-	return &inventoryService{InventoryRepository: repo}
+	return &inventoryService{InventoryRepository: repo, MenuRepository: mr, AdjustmentRepository: ar}
 }
 
 // ValidateItem validates the fields of an InventoryItem.
@@ -33,66 +51,86 @@ func NewInventoryService(repo dal.InventoryRepository) *inventoryService {
 // - ErrNotValidID if the IngredientID is empty.
 // - ErrIDContainsSpace if the IngredientID contains spaces.
 // - ErrNotValidName if the Name is empty.
-// - ErrNotValidQuantity if the Quantity is zero or negative.
-// - ErrNotValidUnit if the Unit is empty.
+// - ErrNotValidQuantity if the Quantity is negative or not a finite number.
+// - ErrNotValidUnit if the Unit is not one of the allowed units, wrapped with the list of allowed units.
+// - ErrNotValidReorderLevel if the ReorderLevel is negative or not a finite number.
+// - ErrNotValidCostPerUnit if the CostPerUnit is set and negative or not a finite number.
 func ValidateItem(i models.InventoryItem) error {
-	if i.IngredientID == "" || strings.Contains(i.IngredientID, " ") {
-		return ErrNotValidIngredientID
-	}
-	if strings.Contains(i.IngredientID, " ") {
-		return ErrNotValidIngredientID
-	}
+	return validateItemFields(i).err()
+}
 
-	if i.Name == "" {
-		return ErrNotValidIngredientName
-	}
+// ValidateItemCollecting checks every field of an inventory item and returns all problems found
+// instead of stopping at the first one. An empty result means the item is valid.
+func ValidateItemCollecting(i models.InventoryItem) []models.FieldError {
+	return validateItemFields(i).fieldErrors()
+}
 
-	if i.Quantity < 0 || i.Quantity == -0 {
-		return ErrNotValidQuantity
-	}
+// validateItemFields checks the fields of an inventory item in the order ValidateItem reports them.
+func validateItemFields(i models.InventoryItem) *fieldValidator {
+	v := &fieldValidator{}
 
-	if i.Unit == "" {
-		return ErrNotValidUnit
-	}
+	v.check(isValidID(i.IngredientID), "ingredient_id", ErrNotValidIngredientID)
+	v.check(i.Name != "", "name", ErrNotValidIngredientName)
 
-	return nil
+	// Zero is allowed for out-of-stock items
+	v.check(isNonNegative(i.Quantity), "quantity", ErrNotValidQuantity)
+	v.checkErr("unit", ValidateUnit(i.Unit))
+	v.check(isNonNegative(i.ReorderLevel), "reorder_level", ErrNotValidReorderLevel)
+	v.check(i.CostPerUnit == nil || isNonNegative(*i.CostPerUnit), "cost_per_unit", ErrNotValidCostPerUnit)
+
+	return v
 }
 
 // AddInventoryItem adds a new inventory item to the repository.
 // Returns nil if the addition is successful.
 // The following errors may be returned:
+// - ValidationError listing every invalid field of the item.
 // - ErrNotUniqueID if the item with the same ID already exists.
-// - An error if there is a validation issue or a failure when adding the item to the repository.
+// - An error if there is a failure when adding the item to the repository.
 func (s *inventoryService) AddInventoryItem(i models.InventoryItem) error {
-	if exists, err := s.InventoryRepository.ItemExists(i); err != nil {
-		return err
-	} else if exists {
-		return ErrNotUniqueID
-	}
-
 	// Item validation
-	if err := ValidateItem(i); err != nil {
-		return err
+	if fieldErrors := ValidateItemCollecting(i); len(fieldErrors) > 0 {
+		return &ValidationError{Errors: fieldErrors}
 	}
 
-	if _, err := s.InventoryRepository.AddItem(i); err != nil {
-		return err
-	}
+	// The uniqueness check and the write are one locked update, two requests can not add the same ID
+	return s.InventoryRepository.UpdateItems(func(items []models.InventoryItem) ([]models.InventoryItem, error) {
+		if containsItemID(items, i.IngredientID) {
+			return nil, ErrNotUniqueID
+		}
+		return append(items, i), nil
+	})
+}
 
-	return nil
+// containsItemID reports whether an item with the ID, ignoring case, is among the inventory items.
+func containsItemID(items []models.InventoryItem, id string) bool {
+	return slices.ContainsFunc(items, func(item models.InventoryItem) bool {
+		return strings.EqualFold(item.IngredientID, id)
+	})
 }
 
 // RetrieveInventoryItems retrieves all inventory items from the repository.
+// If category is not empty, only items of that category (case-insensitive) are returned.
 // Returns the items data in JSON format as a byte slice.
 // The following error may be returned:
 // - An error if there is a failure when retrieving items from the repository or when marshalling the data.
-func (s *inventoryService) RetrieveInventoryItems() ([]byte, error) {
+func (s *inventoryService) RetrieveInventoryItems(category string) ([]byte, error) {
 	inventoryItems, err := s.InventoryRepository.GetAllItems()
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := json.MarshalIndent(inventoryItems, "", " ")
+	if category != "" {
+		filtered := []models.InventoryItem{}
+		for _, item := range inventoryItems {
+			if strings.EqualFold(item.Category, category) {
+				filtered = append(filtered, item)
+			}
+		}
+		inventoryItems = filtered
+	}
+
+	data, err := json.Marshal(inventoryItems)
 	if err != nil {
 		return nil, err
 	}
@@ -100,70 +138,425 @@ func (s *inventoryService) RetrieveInventoryItems() ([]byte, error) {
 	return data, nil
 }
 
+// RetrieveInventoryItemsByIDs looks up several inventory items at once.
+// Every requested ID gets an entry in the result, in request order, with repeated IDs listed once.
+// Missing IDs are reported with the "not_found" status instead of failing the whole lookup.
+// The following errors may be returned:
+// - ErrEmptyIDList if no IDs are given.
+// - An error if there is a failure when retrieving items from the repository.
+func (s *inventoryService) RetrieveInventoryItemsByIDs(ids []string) ([]models.InventoryLookup, error) {
+	if len(ids) == 0 {
+		return nil, ErrEmptyIDList
+	}
+
+	inventoryItems, err := s.InventoryRepository.GetAllItems()
+	if err != nil {
+		return nil, err
+	}
+
+	itemsByID := make(map[string]models.InventoryItem, len(inventoryItems))
+	for _, item := range inventoryItems {
+		itemsByID[item.IngredientID] = item
+	}
+
+	lookups := []models.InventoryLookup{}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		item, ok := itemsByID[id]
+		if !ok {
+			lookups = append(lookups, models.InventoryLookup{IngredientID: id, Status: models.LookupNotFound})
+			continue
+		}
+		lookups = append(lookups, models.InventoryLookup{IngredientID: id, Status: models.LookupFound, Item: &item})
+	}
+
+	return lookups, nil
+}
+
+// InventoryItemETag computes the entity tag of an inventory item from a hash of its JSON representation.
+func InventoryItemETag(item models.InventoryItem) string {
+	data, _ := json.Marshal(item)
+	hash := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// matchesETag reports whether the If-Match header value matches the entity tag.
+// The header may contain a list of entity tags or "*" to match any item.
+func matchesETag(ifMatch, etag string) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
 // RetrieveInventoryItem retrieves a single inventory item by its ID.
-// Returns the item data in JSON format as a byte slice if found.
+// Returns the item data in JSON format as a byte slice and the ETag of the item if found.
 // The following errors may be returned:
 // - ErrNoItem if the item with the specified ID is not found.
 // - An error if there is a failure when retrieving items from the repository or when marshalling the item data.
-func (s *inventoryService) RetrieveInventoryItem(id string) ([]byte, error) {
+func (s *inventoryService) RetrieveInventoryItem(id string) ([]byte, string, error) {
 	var inventoryItem models.InventoryItem
 	inventoryItem, err := s.InventoryRepository.GetItemById(id)
 	if err != nil {
 		if err.Error() == "EOF" {
-			return nil, ErrNoItem
+			return nil, "", ErrNoItem
 		}
-		return nil, err
+		return nil, "", err
 	}
 
-	data, err := json.MarshalIndent(inventoryItem, "", " ")
+	data, err := json.Marshal(inventoryItem)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return data, nil
+	return data, InventoryItemETag(inventoryItem), nil
 }
 
 // UpdateInventoryItem updates the old inventory item with the new one.
-// Returns nil if the update is successful.
+// The ifMatch value must match the ETag of the current item, so concurrent edits are not lost.
+// Returns the ETag of the updated item if the update is successful.
 // The following errors may be returned:
 // - ErrNoItem if the old item is not found by id.
+// - ErrETagRequired if ifMatch is empty.
+// - ErrETagMismatch if ifMatch does not match the current item.
+// - ValidationError listing every invalid field of the new item.
 // - ErrNotUniqueID if new item id not unique.
-// - An error if there is a validation issue or a failure when updating the repository.
-func (s *inventoryService) UpdateInventoryItem(id string, i models.InventoryItem) error {
-	if exists, err := s.InventoryRepository.ItemExists(models.InventoryItem{IngredientID: id}); err != nil {
-		return err
-	} else if !exists {
-		return ErrNoItem
+// - An error if there is a failure when updating the repository.
+func (s *inventoryService) UpdateInventoryItem(id string, i models.InventoryItem, ifMatch string) (string, error) {
+	// The checks run on the items the update is applied to, a concurrent edit can not slip in between
+	err := s.InventoryRepository.UpdateItems(func(items []models.InventoryItem) ([]models.InventoryItem, error) {
+		index := slices.IndexFunc(items, func(item models.InventoryItem) bool { return item.IngredientID == id })
+		if index < 0 {
+			return nil, ErrNoItem
+		}
+
+		// Optimistic concurrency check
+		if ifMatch == "" {
+			return nil, ErrETagRequired
+		}
+		if !matchesETag(ifMatch, InventoryItemETag(items[index])) {
+			return nil, ErrETagMismatch
+		}
+
+		// Uniqueness test of new item, changing only the case of the ID can not clash with another item
+		if !strings.EqualFold(i.IngredientID, id) && containsItemID(items, i.IngredientID) {
+			return nil, ErrNotUniqueID
+		}
+
+		// New item validation
+		if fieldErrors := ValidateItemCollecting(i); len(fieldErrors) > 0 {
+			return nil, &ValidationError{Errors: fieldErrors}
+		}
+
+		// Rewriting old item
+		items[index] = i
+		return items, nil
+	})
+	if err != nil {
+		return "", err
 	}
 
-	// Uniqueness test of new item
-	if i.IngredientID != id {
-		if exists, err := s.InventoryRepository.ItemExists(i); err != nil {
+	return InventoryItemETag(i), nil
+}
+
+// DeleteInventoryItem deletes an inventory item by its ID.
+// Items still used by menu recipes are only deleted when force is set.
+// Returns nil if the deletion is successful.
+// The following errors may be returned:
+// - ErrNoItem if the item with the specified ID is not found.
+// - ErrInventoryItemInUse, wrapped with the referencing menu item IDs, if the item is used by the menu.
+// - An error if there is a failure when retrieving or saving items in the repository.
+func (s *inventoryService) DeleteInventoryItem(id string, force bool) error {
+	if !force {
+		menuItemIDs, err := s.referencingMenuItems(id)
+		if err != nil {
 			return err
-		} else if exists {
-			return ErrNotUniqueID
+		}
+
+		if len(menuItemIDs) > 0 {
+			if _, err := s.InventoryRepository.GetItemById(id); err != nil {
+				if err.Error() == "item not found" {
+					return ErrNoItem
+				}
+				return err
+			}
+			return fmt.Errorf("%w: %s", ErrInventoryItemInUse, strings.Join(menuItemIDs, ", "))
 		}
 	}
 
-	// New item validation
-	if err := ValidateItem(i); err != nil {
+	if err := s.InventoryRepository.DeleteItemByID(id); err != nil {
+		if err.Error() == "item not found" {
+			return ErrNoItem
+		}
 		return err
 	}
 
-	// Rewriting old item in repo
-	err := s.InventoryRepository.RewriteItem(id, i)
+	return nil
+}
+
+// referencingMenuItems returns the IDs of the menu items whose recipes, including size variants, use the ingredient.
+func (s *inventoryService) referencingMenuItems(ingredientID string) ([]string, error) {
+	menuItems, err := s.MenuRepository.GetAllMenuItems()
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	return nil
+	menuItemIDs := []string{}
+	for _, item := range menuItems {
+		ingredients := slices.Clone(item.Ingredients)
+		for _, size := range item.Sizes {
+			ingredients = append(ingredients, size.Ingredients...)
+		}
+
+		for _, ingredient := range ingredients {
+			if ingredient.IngredientID == ingredientID {
+				menuItemIDs = append(menuItemIDs, item.ID)
+				break
+			}
+		}
+	}
+
+	return menuItemIDs, nil
 }
 
-// DeleteInventoryItem deletes an inventory item by its ID.
-// Returns nil if the deletion is successful.
+// AdjustInventoryItem applies a manual stock change to an inventory item and records it in the adjustment log.
+// Returns the recorded adjustment.
 // The following errors may be returned:
+// - ErrNotValidDelta if the delta is zero or not a finite number.
+// - ErrNotValidReason if the reason is empty.
 // - ErrNoItem if the item with the specified ID is not found.
-// - An error if there is a failure when retrieving or saving items in the repository.
-func (s *inventoryService) DeleteInventoryItem(id string) error {
-	return s.InventoryRepository.DeleteItemByID(id)
+// - ErrNegativeQuantity if the adjustment would make the quantity negative.
+func (s *inventoryService) AdjustInventoryItem(id string, delta float64, reason string) (models.InventoryAdjustment, error) {
+	if delta == 0 || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return models.InventoryAdjustment{}, ErrNotValidDelta
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return models.InventoryAdjustment{}, ErrNotValidReason
+	}
+
+	quantityAfter, err := s.applyDelta(id, delta)
+	if err != nil {
+		return models.InventoryAdjustment{}, err
+	}
+
+	adjustment := models.InventoryAdjustment{
+		IngredientID:  id,
+		Delta:         delta,
+		Reason:        reason,
+		QuantityAfter: quantityAfter,
+		CreatedAt:     time.Now().Format(time.RFC3339),
+	}
+
+	// Every stock change must be audited, so the change is undone if it can not be recorded
+	if err := s.AdjustmentRepository.AddAdjustment(adjustment); err != nil {
+		if _, rollbackErr := s.applyDelta(id, -delta); rollbackErr != nil {
+			return models.InventoryAdjustment{}, fmt.Errorf("%w (inventory rollback failed: %v)", err, rollbackErr)
+		}
+		return models.InventoryAdjustment{}, err
+	}
+
+	return adjustment, nil
+}
+
+// applyDelta adds the delta to the quantity of an inventory item and returns the new quantity.
+// The change is applied to the latest saved quantity, concurrent changes are not overwritten.
+// Returns ErrNoItem if the item is not found and ErrNegativeQuantity if the quantity would go negative.
+func (s *inventoryService) applyDelta(id string, delta float64) (float64, error) {
+	var quantity float64
+	err := s.InventoryRepository.UpdateItems(func(items []models.InventoryItem) ([]models.InventoryItem, error) {
+		index := slices.IndexFunc(items, func(item models.InventoryItem) bool { return item.IngredientID == id })
+		if index < 0 {
+			return nil, ErrNoItem
+		}
+
+		quantity = items[index].Quantity + delta
+		if quantity < 0 {
+			return nil, ErrNegativeQuantity
+		}
+		items[index].Quantity = quantity
+		return items, nil
+	})
+	return quantity, err
+}
+
+// RetrieveAdjustments returns the adjustment history of an inventory item, oldest first.
+// Returns ErrNoItem if the item with the specified ID is not found.
+func (s *inventoryService) RetrieveAdjustments(id string) ([]models.InventoryAdjustment, error) {
+	if _, err := s.InventoryRepository.GetItemById(id); err != nil {
+		if err.Error() == "item not found" {
+			return nil, ErrNoItem
+		}
+		return nil, err
+	}
+
+	return s.AdjustmentRepository.GetAdjustmentsByItemId(id)
+}
+
+// InventoryLastModified returns the time the inventory was last changed, or the zero time if it is unknown.
+func (s *inventoryService) InventoryLastModified() (time.Time, error) {
+	return s.InventoryRepository.LastModified()
+}
+
+// GetLowStockItems retrieves all inventory items whose quantity is at or below their reorder level.
+// Items without a reorder level are excluded.
+func (s *inventoryService) GetLowStockItems() ([]models.InventoryItem, error) {
+	inventoryItems, err := s.InventoryRepository.GetAllItems()
+	if err != nil {
+		return nil, err
+	}
+
+	lowStockItems := []models.InventoryItem{}
+	for _, item := range inventoryItems {
+		if item.ReorderLevel > 0 && item.Quantity <= item.ReorderLevel {
+			lowStockItems = append(lowStockItems, item)
+		}
+	}
+
+	return lowStockItems, nil
+}
+
+// ImportInventoryCSV upserts inventory items from CSV data with the columns
+// ingredient_id,name,quantity,unit. The first line is treated as a header and skipped.
+// Rows that can not be parsed or validated are reported with their line numbers and skipped,
+// the other rows are saved at once. Existing items keep their reorder level, cost per unit and category.
+// A row whose ID differs from an existing ID only in case is rejected with ErrNotUniqueID.
+func (s *inventoryService) ImportInventoryCSV(data io.Reader) (models.ImportSummary, error) {
+	summary := models.ImportSummary{Errors: []models.ImportError{}}
+
+	rows, err := readInventoryRecords(data, &summary)
+	if err != nil {
+		return summary, err
+	}
+
+	// The rows are merged into the items the import is saved over, so concurrent changes are not lost
+	err = s.InventoryRepository.UpdateItems(func(inventoryItems []models.InventoryItem) ([]models.InventoryItem, error) {
+		indexByID := make(map[string]int)
+		foldedIDs := make(map[string]bool)
+		for i, item := range inventoryItems {
+			indexByID[item.IngredientID] = i
+			foldedIDs[strings.ToLower(item.IngredientID)] = true
+		}
+
+		for _, row := range rows {
+			item := row.item
+			if _, exists := indexByID[item.IngredientID]; !exists && foldedIDs[strings.ToLower(item.IngredientID)] {
+				summary.Failed++
+				summary.Errors = append(summary.Errors, models.ImportError{Line: row.line, Error: ErrNotUniqueID.Error()})
+				continue
+			}
+
+			if i, exists := indexByID[item.IngredientID]; exists {
+				item.ReorderLevel = inventoryItems[i].ReorderLevel
+				item.CostPerUnit = inventoryItems[i].CostPerUnit
+				item.Category = inventoryItems[i].Category
+				inventoryItems[i] = item
+				summary.Updated++
+				continue
+			}
+
+			indexByID[item.IngredientID] = len(inventoryItems)
+			foldedIDs[strings.ToLower(item.IngredientID)] = true
+			inventoryItems = append(inventoryItems, item)
+			summary.Inserted++
+		}
+
+		if summary.Inserted+summary.Updated == 0 {
+			return nil, errNothingImported
+		}
+		return inventoryItems, nil
+	})
+	if err != nil && !errors.Is(err, errNothingImported) {
+		return summary, err
+	}
+
+	// Rows are reported in line order, wherever they failed
+	slices.SortStableFunc(summary.Errors, func(a, b models.ImportError) int { return a.Line - b.Line })
+
+	return summary, nil
+}
+
+// errNothingImported skips saving the inventory when no row of an import was valid.
+var errNothingImported = errors.New("nothing imported")
+
+// inventoryRecord is a parsed and validated CSV row of an inventory import.
+type inventoryRecord struct {
+	line int
+	item models.InventoryItem
+}
+
+// readInventoryRecords parses and validates the CSV rows of an inventory import, skipping the header line.
+// Rows that can not be parsed or validated are counted as failed in the summary.
+func readInventoryRecords(data io.Reader, summary *models.ImportSummary) ([]inventoryRecord, error) {
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows := []inventoryRecord{}
+	isHeader := true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			summary.Failed++
+			summary.Errors = append(summary.Errors, models.ImportError{Line: parseErr.Line, Error: parseErr.Err.Error()})
+			isHeader = false
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if isHeader {
+			isHeader = false
+			continue
+		}
+
+		item, err := parseInventoryRecord(record)
+		if err == nil {
+			err = ValidateItem(item)
+		}
+		if err != nil {
+			summary.Failed++
+			summary.Errors = append(summary.Errors, models.ImportError{Line: line, Error: err.Error()})
+			continue
+		}
+
+		rows = append(rows, inventoryRecord{line: line, item: item})
+	}
+
+	return rows, nil
+}
+
+// parseInventoryRecord converts a CSV record with the columns ingredient_id,name,quantity,unit to an inventory item.
+func parseInventoryRecord(record []string) (models.InventoryItem, error) {
+	if len(record) != 4 {
+		return models.InventoryItem{}, fmt.Errorf("expected 4 columns, got %d", len(record))
+	}
+
+	quantity, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+	if err != nil {
+		return models.InventoryItem{}, ErrNotValidQuantity
+	}
+
+	return models.InventoryItem{
+		IngredientID: strings.TrimSpace(record[0]),
+		Name:         strings.TrimSpace(record[1]),
+		Quantity:     quantity,
+		Unit:         strings.TrimSpace(record[3]),
+	}, nil
 }
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+func newTestInventoryService(items ...models.InventoryItem) *inventoryService {
+	return NewInventoryService(
+		dal.NewInMemoryInventoryRepository(items...),
+		dal.NewInMemoryMenuRepository(),
+		dal.NewInMemoryAdjustmentRepository(),
+	)
+}
+
+func TestAdjustInventoryItemConcurrent(t *testing.T) {
+	s := newTestInventoryService(models.InventoryItem{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml"})
+
+	const workers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := s.AdjustInventoryItem("milk", 10, "restock")
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := s.AdjustInventoryItem("milk", -3, "spill")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("AdjustInventoryItem() error = %v", err)
+		}
+	}
+
+	item, err := s.InventoryRepository.GetItemById("milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 1000.0 + workers*7; item.Quantity != want {
+		t.Errorf("quantity = %g, want %g: a concurrent adjustment was lost", item.Quantity, want)
+	}
+
+	adjustments, err := s.RetrieveAdjustments("milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adjustments) != 2*workers {
+		t.Errorf("got %d adjustments, want %d", len(adjustments), 2*workers)
+	}
+}
+
+func TestAddInventoryItem(t *testing.T) {
+	milk := models.InventoryItem{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml"}
+
+	tests := []struct {
+		name      string
+		id        string
+		wantErr   error
+		wantItems int
+	}{
+		{name: "new ID", id: "cream", wantItems: 2},
+		{name: "same ID", id: "milk", wantErr: ErrNotUniqueID, wantItems: 1},
+		{name: "upper-case variant", id: "MILK", wantErr: ErrNotUniqueID, wantItems: 1},
+		{name: "mixed-case variant", id: "mIlK", wantErr: ErrNotUniqueID, wantItems: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestInventoryService(milk)
+
+			err := s.AddInventoryItem(models.InventoryItem{IngredientID: tt.id, Name: "Dairy", Quantity: 1, Unit: "ml"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddInventoryItem(%q) error = %v, want %v", tt.id, err, tt.wantErr)
+			}
+
+			items, err := s.InventoryRepository.GetAllItems()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != tt.wantItems {
+				t.Errorf("inventory has %d items, want %d", len(items), tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestAddInventoryItemConcurrentSameID(t *testing.T) {
+	s := newTestInventoryService()
+
+	// Every case variant of the ID competes for the same slot
+	ids := []string{"milk", "Milk", "MILK", "mIlk", "miLK", "milK", "MIlk", "mILK"}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	added, rejected := 0, 0
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			err := s.AddInventoryItem(models.InventoryItem{IngredientID: id, Name: "Milk", Quantity: 1, Unit: "ml"})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				added++
+			case errors.Is(err, ErrNotUniqueID):
+				rejected++
+			default:
+				t.Errorf("AddInventoryItem(%q) error = %v", id, err)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if added != 1 || rejected != len(ids)-1 {
+		t.Errorf("added %d and rejected %d items, want 1 and %d", added, rejected, len(ids)-1)
+	}
+
+	items, err := s.InventoryRepository.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Errorf("inventory has %d items, want 1", len(items))
+	}
+}
+
+func TestUpdateInventoryItemConcurrentETag(t *testing.T) {
+	milk := models.InventoryItem{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml"}
+	s := newTestInventoryService(milk)
+	etag := InventoryItemETag(milk)
+
+	// All writers saw the same version, only the first one to write may succeed
+	const workers = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	updated, mismatched := 0, 0
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			item := milk
+			item.Quantity = float64(i)
+			_, err := s.UpdateInventoryItem("milk", item, etag)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				updated++
+			case errors.Is(err, ErrETagMismatch):
+				mismatched++
+			default:
+				t.Errorf("UpdateInventoryItem() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if updated != 1 || mismatched != workers-1 {
+		t.Errorf("updated %d times and rejected %d updates, want 1 and %d", updated, mismatched, workers-1)
+	}
+}
+
+func TestConcurrentImportKeepsAdjustments(t *testing.T) {
+	s := newTestInventoryService(models.InventoryItem{IngredientID: "milk", Name: "Milk", Quantity: 100, Unit: "ml"})
+
+	// Imports of other items run alongside adjustments of milk, neither may overwrite the other
+	const workers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			csv := fmt.Sprintf("ingredient_id,name,quantity,unit\nitem%d,Item,1,g\n", i)
+			if _, err := s.ImportInventoryCSV(strings.NewReader(csv)); err != nil {
+				t.Errorf("ImportInventoryCSV() error = %v", err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := s.AdjustInventoryItem("milk", 1, "restock"); err != nil {
+				t.Errorf("AdjustInventoryItem() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	items, err := s.InventoryRepository.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != workers+1 {
+		t.Errorf("inventory has %d items, want %d", len(items), workers+1)
+	}
+	for _, item := range items {
+		if item.IngredientID == "milk" && item.Quantity != 100+workers {
+			t.Errorf("milk quantity = %g, want %d", item.Quantity, 100+workers)
+		}
+	}
+}
+
+func TestUpdateInventoryItem(t *testing.T) {
+	milk := models.InventoryItem{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml"}
+	sugar := models.InventoryItem{IngredientID: "sugar", Name: "Sugar", Quantity: 500, Unit: "g"}
+
+	tests := []struct {
+		name    string
+		id      string
+		item    models.InventoryItem
+		ifMatch string
+		wantErr error
+	}{
+		{name: "matching etag", id: "milk", item: milk, ifMatch: InventoryItemETag(milk)},
+		{name: "any etag", id: "milk", item: milk, ifMatch: "*"},
+		{name: "case-only rename", id: "milk", item: models.InventoryItem{IngredientID: "Milk", Name: "Milk", Quantity: 1, Unit: "ml"}, ifMatch: "*"},
+		{name: "unknown item", id: "cream", item: milk, ifMatch: "*", wantErr: ErrNoItem},
+		{name: "missing etag", id: "milk", item: milk, wantErr: ErrETagRequired},
+		{name: "stale etag", id: "milk", item: milk, ifMatch: `"stale"`, wantErr: ErrETagMismatch},
+		{name: "rename onto another item", id: "milk", item: models.InventoryItem{IngredientID: "SUGAR", Name: "Sugar", Quantity: 1, Unit: "g"}, ifMatch: "*", wantErr: ErrNotUniqueID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestInventoryService(milk, sugar)
+
+			_, err := s.UpdateInventoryItem(tt.id, tt.item, tt.ifMatch)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateInventoryItem() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestImportInventoryCSV(t *testing.T) {
+	cost := 0.002
+	milk := models.InventoryItem{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml", ReorderLevel: 100, CostPerUnit: &cost, Category: "dairy"}
+
+	tests := []struct {
+		name        string
+		csv         string
+		wantSummary models.ImportSummary
+		wantLines   []int
+	}{
+		{
+			name:        "insert and update",
+			csv:         "ingredient_id,name,quantity,unit\nmilk,Milk,50,ml\nsugar,Sugar,10,g\n",
+			wantSummary: models.ImportSummary{Inserted: 1, Updated: 1},
+		},
+		{
+			name:        "invalid rows are skipped",
+			csv:         "ingredient_id,name,quantity,unit\nsugar,Sugar,ten,g\nsalt,Salt,1\ncream,Cream,5,ml\n",
+			wantSummary: models.ImportSummary{Inserted: 1, Failed: 2},
+			wantLines:   []int{2, 3},
+		},
+		{
+			name:        "case variant of an existing ID",
+			csv:         "ingredient_id,name,quantity,unit\nMILK,Milk,5,ml\nsugar,Sugar,1,g\nSugar,Sugar,1,g\n",
+			wantSummary: models.ImportSummary{Inserted: 1, Failed: 2},
+			wantLines:   []int{2, 4},
+		},
+		{
+			name:        "nothing valid",
+			csv:         "ingredient_id,name,quantity,unit\n,Nameless,1,g\n",
+			wantSummary: models.ImportSummary{Failed: 1},
+			wantLines:   []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestInventoryService(milk)
+
+			summary, err := s.ImportInventoryCSV(strings.NewReader(tt.csv))
+			if err != nil {
+				t.Fatalf("ImportInventoryCSV() error = %v", err)
+			}
+
+			if summary.Inserted != tt.wantSummary.Inserted || summary.Updated != tt.wantSummary.Updated || summary.Failed != tt.wantSummary.Failed {
+				t.Errorf("summary = %+v, want %+v", summary, tt.wantSummary)
+			}
+			lines := []int{}
+			for _, importErr := range summary.Errors {
+				lines = append(lines, importErr.Line)
+			}
+			if fmt.Sprint(lines) != fmt.Sprint(append([]int{}, tt.wantLines...)) {
+				t.Errorf("error lines = %v, want %v", lines, tt.wantLines)
+			}
+
+			// Updated items keep the fields the CSV has no column for
+			item, err := s.InventoryRepository.GetItemById("milk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.ReorderLevel != milk.ReorderLevel {
+				t.Errorf("milk reorder level = %g, want %g", item.ReorderLevel, milk.ReorderLevel)
+			}
+			if item.CostPerUnit == nil || *item.CostPerUnit != cost {
+				t.Errorf("milk cost per unit = %v, want %g", item.CostPerUnit, cost)
+			}
+			if item.Category != milk.Category {
+				t.Errorf("milk category = %q, want %q", item.Category, milk.Category)
+			}
+
+			// The re-imported item is still found by its category
+			data, err := s.RetrieveInventoryItems("dairy")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var dairy []models.InventoryItem
+			if err := json.Unmarshal(data, &dairy); err != nil {
+				t.Fatal(err)
+			}
+			if len(dairy) != 1 || dairy[0].IngredientID != "milk" {
+				t.Errorf("dairy items = %+v, want milk", dairy)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"hot-coffee/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	// The services log through the package logger, only errors are shown in the test output
+	logger.InitLogger(slog.LevelError, logger.FormatText)
+	os.Exit(m.Run())
+}
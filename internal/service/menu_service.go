@@ -2,6 +2,10 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
 	"strings"
 
 	"hot-coffee/internal/dal"
@@ -14,20 +18,24 @@ type MenuService interface {
 	RetrieveMenuItem(id string) ([]byte, error)
 	UpdateMenuItem(id string, item models.MenuItem) error
 	DeleteMenuItem(id string) error
+	SetMenuItemAvailability(id string, available bool) error
+	UpdateMenuPrices(updates []models.MenuPriceUpdate) ([]models.MenuPriceUpdateResult, error)
+	ListIngredientUsage() ([]models.MenuIngredientUsage, error)
 }
 
 type menuService struct {
-	MenuRepository dal.MenuRepository
+	MenuRepository      dal.MenuRepository
+	InventoryRepository dal.InventoryRepository
+	OrderRepository     dal.OrderRepository
 }
 
-func NewMenuService(repo dal.MenuRepository) *menuService {
-	if repo == nil {
+func NewMenuService(repo dal.MenuRepository, ir dal.InventoryRepository, or dal.OrderRepository) *menuService {
+	if repo == nil || ir == nil || or == nil {
 		return nil
 	}
-	return &menuService{MenuRepository: repo}
+	return &menuService{MenuRepository: repo, InventoryRepository: ir, OrderRepository: or}
 }
 
-// TODO: Добавить правило чтобы не повторялись ингредиенты в массиве (один ингредиент и количество сразу пишутся)
 // ValidateMenuItem validates the fields of a MenuItem.
 // Returns nil if the item is valid.
 // The following errors may be returned:
@@ -35,10 +43,14 @@ func NewMenuService(repo dal.MenuRepository) *menuService {
 // - ErrIDContainsSpace if the ID contains spaces.
 // - ErrNotValidName if the Name is empty.
 // - ErrNotValidDescription if the Description is empty.
-// - ErrNotValidPrice if the Price is zero or negative.
-// - ErrNotValidIngredients if the Ingredients list is nil or empty.
+// - ErrNotValidPrice if the Price is negative, or zero for an item that is not a combo.
+// - ErrNotValidIngredients if the Ingredients list is nil or empty and the item is not a combo.
 // - ErrInvalidIngredientID if any ingredient has an invalid ID (empty or contains spaces).
-// - ErrInvalidIngredientQty if any ingredient has a quantity less than 1.
+// - ErrNotValidRecipeQuantity if any ingredient has a zero, negative or non-finite quantity, wrapped with the ingredient ID.
+// - ErrDuplicateMenuIngredients if an ingredient is listed more than once in the recipe.
+// - ErrNotValidMenuSize if a size variant has an empty or repeated name.
+// - ErrNotValidComboComponent if a combo component is the item itself, repeated or has no positive quantity.
+// - ErrNotValidComboSizes if a combo has size variants.
 func ValidateMenuItem(i models.MenuItem) error {
 	if i.ID == "" || strings.Contains(i.ID, " ") {
 		return ErrNotValidMenuID
@@ -52,15 +64,30 @@ func ValidateMenuItem(i models.MenuItem) error {
 		return ErrNotValidMenuDescription
 	}
 
-	if i.Price <= 0 {
+	// A combo without a price costs the sum of its components
+	if i.Price < 0 || (i.Price == 0 && !i.IsCombo()) || math.IsNaN(i.Price) || math.IsInf(i.Price, 0) {
 		return ErrNotValidPrice
 	}
 
+	if i.IsCombo() {
+		if err := validateComboComponents(i); err != nil {
+			return err
+		}
+		// The ingredients of a combo come from its components, its own are optional
+		if len(i.Ingredients) == 0 {
+			return nil
+		}
+	}
+
 	err := ValidateMenuIngredient(i.Ingredients)
 	if err != nil {
 		return err
 	}
 
+	if err := validateMenuSizes(i.Sizes); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -68,18 +95,81 @@ func ValidateMenuIngredient(i []models.MenuItemIngredient) error {
 	if i == nil || len(i) < 1 {
 		return ErrNotValidIngredints
 	}
-	for k, ingredient := range i {
-		for l, ingredient2 := range i {
-			if ingredient.IngredientID == ingredient2.IngredientID && k != l {
-				return ErrDuplicateMenuIngredients
-			}
+	seen := make(map[string]bool)
+	for _, ingredient := range i {
+		if seen[ingredient.IngredientID] {
+			return ErrDuplicateMenuIngredients
 		}
+		seen[ingredient.IngredientID] = true
+
 		if ingredient.IngredientID == "" || strings.Contains(ingredient.IngredientID, " ") {
 			return ErrNotValidIngredientID
 		}
 
-		if ingredient.Quantity < 1 {
-			return ErrNotValidQuantity
+		// A zero quantity would make every sufficiency check pass for the ingredient
+		if ingredient.Quantity <= 0 || math.IsNaN(ingredient.Quantity) || math.IsInf(ingredient.Quantity, 0) {
+			return fmt.Errorf("%w: ingredient '%s' has quantity %g", ErrNotValidRecipeQuantity, ingredient.IngredientID, ingredient.Quantity)
+		}
+	}
+	return nil
+}
+
+// validateComboReferences checks the components of a combo against the menu as it would be after saving the item,
+// replacing the item with the ID replacedID if it is not empty.
+// The following errors may be returned:
+// - ErrMenuItemInCombo, wrapped with the combo IDs, if the item is renamed while combos list it as a component.
+// - Any error of checkComboReferences.
+func (s *menuService) validateComboReferences(item models.MenuItem, replacedID string) error {
+	renamed := replacedID != "" && replacedID != item.ID
+	if !item.IsCombo() && !renamed {
+		return nil
+	}
+
+	menuItems, err := s.MenuRepository.GetAllMenuItems()
+	if err != nil {
+		return err
+	}
+
+	if renamed {
+		if comboIDs := combosWithComponent(replacedID, menuItems); len(comboIDs) > 0 {
+			return fmt.Errorf("%w: %s", ErrMenuItemInCombo, strings.Join(comboIDs, ", "))
+		}
+	}
+	if !item.IsCombo() {
+		return nil
+	}
+
+	menuMap := make(map[string]models.MenuItem)
+	for _, menuItem := range menuItems {
+		if menuItem.ID != replacedID {
+			menuMap[menuItem.ID] = menuItem
+		}
+	}
+	menuMap[item.ID] = item
+
+	return checkComboReferences(item, menuMap)
+}
+
+// validateIngredientUnits checks that every recipe unit can be converted to the unit of its inventory item.
+// Ingredients that are not in the inventory yet are skipped.
+// Returns ErrIncompatibleUnit if any of the units are incompatible.
+func (s *menuService) validateIngredientUnits(item models.MenuItem) error {
+	ingredients := slices.Clone(item.Ingredients)
+	for _, size := range item.Sizes {
+		ingredients = append(ingredients, size.Ingredients...)
+	}
+
+	for _, ingredient := range ingredients {
+		inventoryItem, err := s.InventoryRepository.GetItemById(ingredient.IngredientID)
+		if err != nil {
+			if err.Error() == "item not found" {
+				continue
+			}
+			return err
+		}
+
+		if _, err := ConvertQuantity(ingredient.Quantity, ingredient.Unit, inventoryItem.Unit); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -102,6 +192,14 @@ func (s *menuService) AddMenuItem(i models.MenuItem) error {
 		return err
 	}
 
+	if err := s.validateIngredientUnits(i); err != nil {
+		return err
+	}
+
+	if err := s.validateComboReferences(i, ""); err != nil {
+		return err
+	}
+
 	if _, err := s.MenuRepository.AddMenuItem(i); err != nil {
 		return err
 	}
@@ -115,7 +213,7 @@ func (s *menuService) RetrieveMenuItems() ([]byte, error) {
 		return nil, err
 	}
 
-	data, err := json.MarshalIndent(menuItems, "", " ")
+	data, err := json.Marshal(menuItems)
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +221,57 @@ func (s *menuService) RetrieveMenuItems() ([]byte, error) {
 	return data, nil
 }
 
+// ListIngredientUsage returns every ingredient referenced by the menu recipes, including the size recipes,
+// with the menu items using it. Combos use the ingredients of their components, resolved by indexMenuItems.
+// Ingredients used by the most menu items come first, ties are sorted by ID.
+func (s *menuService) ListIngredientUsage() ([]models.MenuIngredientUsage, error) {
+	menuItems, err := s.MenuRepository.GetAllMenuItems()
+	if err != nil {
+		return nil, err
+	}
+
+	menuMap := indexMenuItems(menuItems)
+	usageByID := make(map[string]*models.MenuIngredientUsage)
+	for _, item := range menuItems {
+		// Combos that can not be resolved only use their own ingredients
+		if resolved, exists := menuMap[item.ID]; exists {
+			item = resolved
+		}
+
+		ingredients := slices.Clone(item.Ingredients)
+		for _, size := range item.Sizes {
+			ingredients = append(ingredients, size.Ingredients...)
+		}
+
+		for _, ingredient := range ingredients {
+			usage, exists := usageByID[ingredient.IngredientID]
+			if !exists {
+				usage = &models.MenuIngredientUsage{IngredientID: ingredient.IngredientID, MenuItems: []string{}}
+				usageByID[ingredient.IngredientID] = usage
+			}
+
+			// Sizes of the same menu item reuse its ingredients, every item is counted once
+			if !slices.Contains(usage.MenuItems, item.ID) {
+				usage.MenuItems = append(usage.MenuItems, item.ID)
+				usage.MenuItemCount++
+			}
+		}
+	}
+
+	usages := make([]models.MenuIngredientUsage, 0, len(usageByID))
+	for _, usage := range usageByID {
+		usages = append(usages, *usage)
+	}
+	slices.SortFunc(usages, func(a, b models.MenuIngredientUsage) int {
+		if a.MenuItemCount != b.MenuItemCount {
+			return b.MenuItemCount - a.MenuItemCount
+		}
+		return strings.Compare(a.IngredientID, b.IngredientID)
+	})
+
+	return usages, nil
+}
+
 func (s *menuService) RetrieveMenuItem(id string) ([]byte, error) {
 	menuItems, err := s.MenuRepository.GetAllMenuItems()
 	if err != nil {
@@ -147,7 +296,7 @@ func (s *menuService) RetrieveMenuItem(id string) ([]byte, error) {
 		return nil, ErrNoItem
 	}
 
-	data, err := json.MarshalIndent(menuItem, "", " ")
+	data, err := json.Marshal(menuItem)
 	if err != nil {
 		return nil, err
 	}
@@ -156,63 +305,236 @@ func (s *menuService) RetrieveMenuItem(id string) ([]byte, error) {
 }
 
 func (s *menuService) UpdateMenuItem(id string, i models.MenuItem) error {
-	// Existence test of old item
-	if exists, err := s.MenuRepository.MenuItemExists(models.MenuItem{ID: id}); err != nil {
-		return err
-	} else if !exists {
-		return ErrNoItem
-	}
+	// The tests and the rewrite are one locked update of the menu
+	return s.updateMenu(func(menuItems []models.MenuItem) ([]models.MenuItem, error) {
+		// Existence test of old item
+		index := slices.IndexFunc(menuItems, func(item models.MenuItem) bool { return item.ID == id })
+		if index < 0 {
+			return nil, ErrNoItem
+		}
 
-	// Uniqueness test of new item
-	if i.ID != id {
-		if exists, err := s.MenuRepository.MenuItemExists(i); err != nil {
-			return err
-		} else if exists {
-			return ErrNotUniqueMenuID
+		// Uniqueness test of new item
+		if i.ID != id && slices.ContainsFunc(menuItems, func(item models.MenuItem) bool { return item.ID == i.ID }) {
+			return nil, ErrNotUniqueMenuID
 		}
-	}
 
-	// New item validation
-	if err := ValidateMenuItem(i); err != nil {
-		return err
-	}
+		// New item validation
+		if err := ValidateMenuItem(i); err != nil {
+			return nil, err
+		}
 
-	// Rewriting old item in repo
-	err := s.MenuRepository.RewriteMenuItem(id, i)
-	if err != nil {
-		return nil
-	}
+		if err := s.validateIngredientUnits(i); err != nil {
+			return nil, err
+		}
 
-	return nil
+		if err := s.validateComboReferences(i, id); err != nil {
+			return nil, err
+		}
+
+		// Rewriting old item
+		menuItems[index] = i
+		return menuItems, nil
+	})
 }
 
+// DeleteMenuItem deletes a menu item by its ID.
+// Returns nil if the deletion is successful.
+// The following errors may be returned:
+// - ErrNoItem if the item with the specified ID is not found.
+// - ErrMenuItemInCombo, wrapped with the combo IDs, if combos list the item as a component.
+// - ErrMenuItemInUse, wrapped with the referencing order IDs, if an open order contains the item.
+// - An error if there is a failure when retrieving or saving items in the repository.
 func (s *menuService) DeleteMenuItem(id string) error {
-	menuItems, err := s.MenuRepository.GetAllMenuItems()
+	err := s.updateMenu(func(menuItems []models.MenuItem) ([]models.MenuItem, error) {
+		index := slices.IndexFunc(menuItems, func(item models.MenuItem) bool { return item.ID == id })
+		if index < 0 {
+			return nil, ErrNoItem
+		}
+		menuItems = slices.Delete(menuItems, index, index+1)
+
+		if comboIDs := combosWithComponent(id, menuItems); len(comboIDs) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrMenuItemInCombo, strings.Join(comboIDs, ", "))
+		}
+
+		// Closed orders are historical and do not block the deletion
+		orderIDs, err := s.openOrdersWithProduct(id)
+		if err != nil {
+			return nil, err
+		}
+		if len(orderIDs) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrMenuItemInUse, strings.Join(orderIDs, ", "))
+		}
+
+		return menuItems, nil
+	})
+	if err != nil && err.Error() == "EOF" {
+		return ErrNoItem
+	}
+	return err
+}
+
+// SetMenuItemAvailability marks a menu item as available or unavailable for new orders.
+// Returns ErrNoItem if the item with the specified ID is not found.
+func (s *menuService) SetMenuItemAvailability(id string, available bool) error {
+	menuItem, err := s.MenuRepository.GetMenuItemById(id)
 	if err != nil {
-		if err.Error() == "EOF" {
+		if err.Error() == "item not found" {
 			return ErrNoItem
 		}
 		return err
 	}
 
-	isFound := false
-	// deleting from the slice
-	for i, item := range menuItems {
-		if item.ID == id {
-			menuItems = append(menuItems[:i], menuItems[i+1:]...)
-			isFound = true
-			break
+	menuItem.Available = available
+
+	return s.MenuRepository.RewriteMenuItem(id, menuItem)
+}
+
+// UpdateMenuPrices changes the base price of several menu items at once.
+// The update is all or nothing: if any entry is rejected no price is changed and ErrPriceUpdateRejected
+// is returned along with the results, where the rejected entries carry their error.
+// Orders placed before the change keep their totals, as the old prices are pinned on their items first.
+// The following errors may be returned:
+// - ErrEmptyPriceUpdate if there are no entries.
+// - ErrPriceUpdateRejected if an entry has an unknown ID, a non-positive price or a repeated ID.
+func (s *menuService) UpdateMenuPrices(updates []models.MenuPriceUpdate) ([]models.MenuPriceUpdateResult, error) {
+	if len(updates) == 0 {
+		return nil, ErrEmptyPriceUpdate
+	}
+
+	results := make([]models.MenuPriceUpdateResult, len(updates))
+	err := s.updateMenu(func(menuItems []models.MenuItem) ([]models.MenuItem, error) {
+		indexByID := make(map[string]int, len(menuItems))
+		for i, item := range menuItems {
+			indexByID[item.ID] = i
 		}
+
+		newPrices := make(map[string]float64, len(updates))
+		rejected := false
+		for i, update := range updates {
+			results[i] = models.MenuPriceUpdateResult{ID: update.ID, NewPrice: update.Price}
+
+			index, exists := indexByID[update.ID]
+			_, repeated := newPrices[update.ID]
+			switch {
+			case !exists:
+				results[i].Error = ErrNoItem.Error()
+			case repeated:
+				results[i].Error = ErrDuplicatePriceUpdate.Error()
+			case update.Price <= 0 || math.IsNaN(update.Price) || math.IsInf(update.Price, 0):
+				results[i].Error = ErrNotValidPrice.Error()
+			}
+
+			if exists {
+				results[i].OldPrice = menuItems[index].Price
+			}
+			if results[i].Error != "" {
+				rejected = true
+				continue
+			}
+			newPrices[update.ID] = update.Price
+		}
+
+		if rejected {
+			return nil, ErrPriceUpdateRejected
+		}
+
+		for id, price := range newPrices {
+			menuItems[indexByID[id]].Price = price
+		}
+		return menuItems, nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrPriceUpdateRejected) {
+			return results, err
+		}
+		return nil, err
 	}
 
-	if !isFound {
-		return ErrNoItem
+	return results, nil
+}
+
+// updateMenu passes the menu items to update and saves the items it returns, under the menu repository lock,
+// so concurrent changes of the menu are not lost. Before the menu is saved, the current prices are pinned
+// on the order items whose price the update changes, so existing orders keep their totals.
+// Nothing is saved if update returns an error.
+func (s *menuService) updateMenu(update func(menuItems []models.MenuItem) ([]models.MenuItem, error)) error {
+	return s.MenuRepository.UpdateMenuItems(func(menuItems []models.MenuItem) ([]models.MenuItem, error) {
+		// The current items are indexed before update changes them in place
+		currentItems := indexMenuItems(menuItems)
+
+		updatedItems, err := update(menuItems)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.pinOrderPrices(currentItems, indexMenuItems(updatedItems)); err != nil {
+			return nil, err
+		}
+		return updatedItems, nil
+	})
+}
+
+// pinOrderPrices sets the current price as the unit price of order items without one
+// whose price differs between the current and the updated menu items, so existing orders keep their totals.
+// Items whose product or size is no longer on the updated menu are pinned too.
+// The orders are read and saved under the order repository lock, so concurrent order changes are kept.
+func (s *menuService) pinOrderPrices(currentItems, updatedItems map[string]models.MenuItem) error {
+	err := s.OrderRepository.UpdateOrders(func(orders []models.Order) ([]models.Order, error) {
+		pinned := false
+		for i := range orders {
+			for j, item := range orders[i].Items {
+				currentItem, exists := currentItems[item.ProductID]
+				if item.UnitPrice > 0 || !exists {
+					continue
+				}
+				price, err := orderItemPrice(item, currentItem)
+				if err != nil {
+					continue
+				}
+
+				updatedItem, exists := updatedItems[item.ProductID]
+				if newPrice, err := orderItemPrice(item, updatedItem); exists && err == nil && newPrice == price {
+					continue
+				}
+				orders[i].Items[j].UnitPrice = price
+				pinned = true
+			}
+		}
+
+		if !pinned {
+			return nil, errNothingPinned
+		}
+		return orders, nil
+	})
+	if err != nil && !errors.Is(err, errNothingPinned) {
+		return err
 	}
+	return nil
+}
+
+// errNothingPinned skips saving the orders when no price of an order item changed.
+var errNothingPinned = errors.New("nothing pinned")
 
-	err = s.MenuRepository.SaveMenuItems(menuItems)
+// openOrdersWithProduct returns the IDs of the orders that are not closed and contain the product.
+func (s *menuService) openOrdersWithProduct(productID string) ([]string, error) {
+	orders, err := s.OrderRepository.GetAllOrders()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	orderIDs := []string{}
+	for _, order := range orders {
+		if order.Status.Normalize() == models.StatusClosed {
+			continue
+		}
+
+		for _, item := range order.Items {
+			if item.ProductID == productID {
+				orderIDs = append(orderIDs, order.ID)
+				break
+			}
+		}
+	}
+
+	return orderIDs, nil
 }
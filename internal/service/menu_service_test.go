@@ -0,0 +1,224 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+func TestListIngredientUsage(t *testing.T) {
+	breakfast := models.MenuItem{
+		ID: "breakfast", Name: "Breakfast", Available: true,
+		Ingredients: []models.MenuItemIngredient{{IngredientID: "bread", Quantity: 2}},
+		Components:  []models.MenuItemComponent{{ProductID: "latte", Quantity: 1}},
+	}
+	broken := models.MenuItem{
+		ID: "broken", Name: "Broken", Price: 5, Available: true,
+		Ingredients: []models.MenuItemIngredient{{IngredientID: "sugar", Quantity: 1}},
+		Components:  []models.MenuItemComponent{{ProductID: "missing", Quantity: 1}},
+	}
+
+	tests := []struct {
+		name string
+		menu []models.MenuItem
+		want map[string][]string
+	}{
+		{
+			name: "plain items",
+			menu: testMenu(),
+			want: map[string][]string{"milk": {"latte", "cappuccino"}, "espresso": {"latte", "cappuccino"}},
+		},
+		{
+			name: "combo uses the ingredients of its components",
+			menu: append(testMenu(), breakfast),
+			want: map[string][]string{"milk": {"latte", "cappuccino", "breakfast"}, "espresso": {"latte", "cappuccino", "breakfast"}, "bread": {"breakfast"}},
+		},
+		{
+			name: "unresolved combo keeps its own ingredients",
+			menu: append(testMenu(), broken),
+			want: map[string][]string{"milk": {"latte", "cappuccino"}, "espresso": {"latte", "cappuccino"}, "sugar": {"broken"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMenuService(dal.NewInMemoryMenuRepository(tt.menu...), dal.NewInMemoryInventoryRepository(testInventory()...), dal.NewInMemoryOrderRepository())
+
+			usages, err := s.ListIngredientUsage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(usages) != len(tt.want) {
+				t.Fatalf("ListIngredientUsage() returned %d ingredients, want %d: %+v", len(usages), len(tt.want), usages)
+			}
+			for _, usage := range usages {
+				want := tt.want[usage.IngredientID]
+				if !slices.Equal(usage.MenuItems, want) || usage.MenuItemCount != len(want) {
+					t.Errorf("%s is used by %v (%d), want %v", usage.IngredientID, usage.MenuItems, usage.MenuItemCount, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMenuIngredientQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity float64
+		wantErr  error
+	}{
+		{name: "positive", quantity: 200},
+		{name: "fractional", quantity: 0.5},
+		{name: "zero", quantity: 0, wantErr: ErrNotValidRecipeQuantity},
+		{name: "negative", quantity: -1, wantErr: ErrNotValidRecipeQuantity},
+		{name: "not a number", quantity: math.NaN(), wantErr: ErrNotValidRecipeQuantity},
+		{name: "infinite", quantity: math.Inf(1), wantErr: ErrNotValidRecipeQuantity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := models.MenuItem{
+				ID: "latte", Name: "Latte", Description: "Espresso with milk", Price: 3.5,
+				Ingredients: []models.MenuItemIngredient{{IngredientID: "espresso", Quantity: 1}, {IngredientID: "milk", Quantity: tt.quantity}},
+			}
+
+			if err := ValidateMenuItem(item); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateMenuItem() error = %v, want %v", err, tt.wantErr)
+			}
+
+			// The service rejects the item before anything is saved
+			s := NewMenuService(dal.NewInMemoryMenuRepository(), dal.NewInMemoryInventoryRepository(testInventory()...), dal.NewInMemoryOrderRepository())
+			if err := s.AddMenuItem(item); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddMenuItem() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && !strings.Contains(ValidateMenuItem(item).Error(), "'milk'") {
+				t.Errorf("error %q does not name the ingredient", ValidateMenuItem(item))
+			}
+		})
+	}
+}
+
+func TestMenuChangesPinOrderPrices(t *testing.T) {
+	latte := testMenu()[0]
+	latte.Description = "Espresso with milk"
+
+	tests := []struct {
+		name      string
+		change    func(s *menuService) error
+		wantPrice float64
+	}{
+		{
+			name: "bulk price update",
+			change: func(s *menuService) error {
+				_, err := s.UpdateMenuPrices([]models.MenuPriceUpdate{{ID: "latte", Price: 5}})
+				return err
+			},
+			wantPrice: 3.5,
+		},
+		{
+			name: "price changed by an update of the item",
+			change: func(s *menuService) error {
+				item := latte
+				item.Price = 5
+				return s.UpdateMenuItem("latte", item)
+			},
+			wantPrice: 3.5,
+		},
+		{
+			name: "update of the item keeping its price",
+			change: func(s *menuService) error {
+				item := latte
+				item.Name = "Caffe latte"
+				return s.UpdateMenuItem("latte", item)
+			},
+			wantPrice: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders := dal.NewInMemoryOrderRepository(models.Order{
+				ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z",
+				Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}, {ProductID: "cappuccino", Quantity: 1}},
+			})
+			s := NewMenuService(dal.NewInMemoryMenuRepository(testMenu()...), dal.NewInMemoryInventoryRepository(testInventory()...), orders)
+
+			if err := tt.change(s); err != nil {
+				t.Fatal(err)
+			}
+
+			order, err := orders.GetOrderById("orders1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := order.Items[0].UnitPrice; got != tt.wantPrice {
+				t.Errorf("latte unit price = %g, want %g", got, tt.wantPrice)
+			}
+			if got := order.Items[1].UnitPrice; got != 0 {
+				t.Errorf("cappuccino unit price = %g, want it left unpinned", got)
+			}
+		})
+	}
+}
+
+func TestMenuChangesConcurrent(t *testing.T) {
+	orders := dal.NewInMemoryOrderRepository()
+	menu := dal.NewInMemoryMenuRepository(testMenu()...)
+	s := NewMenuService(menu, dal.NewInMemoryInventoryRepository(testInventory()...), orders)
+
+	cappuccino := testMenu()[1]
+	cappuccino.Description = "Espresso with foamed milk"
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if _, err := s.UpdateMenuPrices([]models.MenuPriceUpdate{{ID: "latte", Price: 3.5 + float64(i+1)/100}}); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			item := cappuccino
+			item.Name = fmt.Sprintf("Cappuccino %d", i)
+			if err := s.UpdateMenuItem("cappuccino", item); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			order := models.Order{ID: fmt.Sprintf("orders%d", i), CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}}
+			if _, err := orders.AddOrder(order); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// No order created while prices were pinned is lost
+	savedOrders, err := orders.GetAllOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(savedOrders) != n {
+		t.Errorf("%d orders saved, want %d", len(savedOrders), n)
+	}
+
+	// The renames and the price updates are both kept
+	menuItems, err := menu.GetAllMenuItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if menuItems[0].Price == 3.5 || !strings.HasPrefix(menuItems[1].Name, "Cappuccino ") {
+		t.Errorf("menu = %+v, want the latte repriced and the cappuccino renamed", menuItems)
+	}
+}
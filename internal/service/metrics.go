@@ -0,0 +1,14 @@
+package service
+
+import "hot-coffee/pkg/metrics"
+
+// Metrics is the registry of the order and inventory metrics, served by the server on GET /metrics
+var Metrics = metrics.NewRegistry()
+
+var (
+	ordersCreated = Metrics.NewCounter("hot_coffee_orders_created_total", "Number of orders created.")
+	ordersClosed  = Metrics.NewCounter("hot_coffee_orders_closed_total", "Number of orders closed.")
+
+	inventoryRejections = Metrics.NewCounter("hot_coffee_inventory_insufficient_total",
+		"Number of orders rejected on create or close because the inventory was insufficient.")
+)
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"hot-coffee/models"
+	"hot-coffee/pkg/logger"
+)
+
+// unauditedFields are left out of the change diffs, they change with every write or are derived on read
+var unauditedFields = []string{"history_id", "updated_at", "subtotal", "discount_amount", "tax", "total_price", "fulfillment_seconds"}
+
+// RetrieveOrderHistory returns the audit entries of an order in the order they were recorded.
+// The history follows the order when it is renamed, and deleted orders keep their history
+// under their last ID.
+// The following errors may be returned:
+// - ErrNoOrder if the order has no history and does not exist.
+// - An error if there is a failure when reading the audit log or the orders.
+func (s *orderService) RetrieveOrderHistory(id string) ([]models.OrderAuditEntry, error) {
+	key, err := s.orderHistoryKey(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.OrderAuditRepository.GetEntriesByHistoryId(key)
+}
+
+// orderHistoryKey returns the key of the history of the order with the given ID.
+// The key of a deleted order is taken from the latest entry recorded under the ID.
+// Returns ErrNoOrder if the order does not exist and has no history.
+func (s *orderService) orderHistoryKey(id string) (string, error) {
+	order, err := s.OrderRepository.GetOrderById(id)
+	if err == nil {
+		return historyKey(order), nil
+	}
+	if err.Error() != "order not found" {
+		return "", err
+	}
+
+	entries, err := s.OrderAuditRepository.GetAllEntries()
+	if err != nil {
+		return "", err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].OrderID == id {
+			if entries[i].HistoryID != "" {
+				return entries[i].HistoryID, nil
+			}
+			return id, nil
+		}
+	}
+
+	return "", ErrNoOrder
+}
+
+// historyKey returns the key of the order history, the order ID for orders created without one.
+func historyKey(order models.Order) string {
+	if order.HistoryID != "" {
+		return order.HistoryID
+	}
+	return order.ID
+}
+
+// newHistoryID returns a random key for the history of a new order.
+// IDs of deleted orders can be reused, so the key is not derived from the order ID.
+func newHistoryID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithActor returns a service that records actor as the author of the order changes it makes.
+// The service shares the repositories of s.
+func (s *orderService) WithActor(actor string) OrderService {
+	scoped := *s
+	scoped.actor = actor
+	return &scoped
+}
+
+// recordAudit appends an entry for a change of an order to the audit log.
+// before is nil for created orders and after is nil for deleted ones.
+// The change itself is already saved at this point, so a failed audit write is logged instead of failing the request.
+func (s *orderService) recordAudit(action string, before, after *models.Order) {
+	entry := models.OrderAuditEntry{
+		Action:    action,
+		Actor:     s.actor,
+		Changes:   orderChanges(before, after),
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if after != nil {
+		entry.OrderID, entry.HistoryID = after.ID, historyKey(*after)
+	} else if before != nil {
+		entry.OrderID, entry.HistoryID = before.ID, historyKey(*before)
+	}
+
+	if err := s.OrderAuditRepository.AddEntry(entry); err != nil {
+		logger.LOGGER.PrintErrorMsg("Failed to record %s audit entry of order %s: %v", action, entry.OrderID, err)
+	}
+}
+
+// orderChanges compares the JSON fields of two versions of an order, sorted by field name.
+func orderChanges(before, after *models.Order) []models.OrderFieldChange {
+	oldFields := orderFields(before)
+	newFields := orderFields(after)
+
+	names := []string{}
+	for name := range oldFields {
+		names = append(names, name)
+	}
+	for name := range newFields {
+		if _, exists := oldFields[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	changes := []models.OrderFieldChange{}
+	for _, name := range names {
+		if slices.Contains(unauditedFields, name) {
+			continue
+		}
+		oldValue, newValue := oldFields[name], newFields[name]
+		if bytes.Equal(oldValue, newValue) {
+			continue
+		}
+		changes = append(changes, models.OrderFieldChange{Field: name, Old: oldValue, New: newValue})
+	}
+
+	return changes
+}
+
+// orderFields returns the JSON encoded fields of the order, empty fields are omitted.
+func orderFields(order *models.Order) map[string]json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if order == nil {
+		return fields
+	}
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+
+	for name, value := range fields {
+		if string(value) == `""` || string(value) == "null" {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
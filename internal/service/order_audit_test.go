@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestRetrieveOrderHistory(t *testing.T) {
+	latte := []models.OrderItem{{ProductID: "latte", Quantity: 1}}
+
+	tests := []struct {
+		name        string
+		change      func(t *testing.T, s *orderService, id string) string
+		wantActions []string
+		wantErr     error
+	}{
+		{
+			name:        "created",
+			change:      func(t *testing.T, s *orderService, id string) string { return id },
+			wantActions: []string{models.AuditCreated},
+		},
+		{
+			name: "renamed",
+			change: func(t *testing.T, s *orderService, id string) string {
+				if err := s.UpdateOrder(id, models.Order{ID: "renamed", CustomerName: "Alice", Items: latte}); err != nil {
+					t.Fatal(err)
+				}
+				return "renamed"
+			},
+			wantActions: []string{models.AuditCreated, models.AuditUpdated},
+		},
+		{
+			name: "deleted",
+			change: func(t *testing.T, s *orderService, id string) string {
+				if err := s.DeleteOrder(id); err != nil {
+					t.Fatal(err)
+				}
+				return id
+			},
+			wantActions: []string{models.AuditCreated, models.AuditDeleted},
+		},
+		{
+			name: "ID reused after delete",
+			change: func(t *testing.T, s *orderService, id string) string {
+				if err := s.DeleteOrder(id); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := s.AddOrder(models.Order{ID: id, CustomerName: "Bob", Items: latte}); err != nil {
+					t.Fatal(err)
+				}
+				return id
+			},
+			wantActions: []string{models.AuditCreated},
+		},
+		{
+			name:    "unknown order",
+			change:  func(t *testing.T, s *orderService, id string) string { return "missing" },
+			wantErr: ErrNoOrder,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0)
+			order, err := s.AddOrder(models.Order{CustomerName: "Alice", Items: latte})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := s.RetrieveOrderHistory(tt.change(t, s, order.ID))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RetrieveOrderHistory() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var actions []string
+			for _, entry := range entries {
+				actions = append(actions, entry.Action)
+			}
+			if len(actions) != len(tt.wantActions) {
+				t.Fatalf("history actions = %v, want %v", actions, tt.wantActions)
+			}
+			for i := range actions {
+				if actions[i] != tt.wantActions[i] {
+					t.Errorf("history actions = %v, want %v", actions, tt.wantActions)
+				}
+			}
+		})
+	}
+}
+
+func TestRetrieveOrderHistoryLegacyOrder(t *testing.T) {
+	s := newTestOrderService(0, models.Order{ID: "legacy", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}})
+	if err := s.OrderAuditRepository.AddEntry(models.OrderAuditEntry{OrderID: "legacy", Action: models.AuditCreated}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UpdateOrder("legacy", models.Order{ID: "renamed", CustomerName: "Alice", Items: []models.OrderItem{{ProductID: "latte", Quantity: 2}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.RetrieveOrderHistory("renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("renamed legacy order has %d history entries, want 2 (the legacy entry and the update)", len(entries))
+	}
+}
+
+func TestWithActor(t *testing.T) {
+	s := newTestOrderService(0)
+	latte := []models.OrderItem{{ProductID: "latte", Quantity: 1}}
+
+	order, err := s.WithActor("barista-1").AddOrder(models.Order{CustomerName: "Alice", Items: latte})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WithActor("barista-2").CloseOrder(order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteOrder(order.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.RetrieveOrderHistory(order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"barista-1", "barista-2", ""}
+	if len(entries) != len(want) {
+		t.Fatalf("history has %d entries, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if entry.Actor != want[i] {
+			t.Errorf("%s entry actor = %q, want %q", entry.Action, entry.Actor, want[i])
+		}
+	}
+}
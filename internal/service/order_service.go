@@ -2,331 +2,1532 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"hot-coffee/internal/dal"
 	"hot-coffee/models"
+	"hot-coffee/pkg/logger"
 )
 
 type OrderService interface {
-	AddOrder(o models.Order) error
-	RetrieveOrders() ([]byte, error)
+	AddOrder(o models.Order) (models.Order, error)
+	RetrieveOrders(filter OrderFilter) ([]models.Order, error)
+	RetrieveOrderQueue() ([]models.Order, error)
+	RetrieveOrdersUsingIngredient(ingredientID string) ([]models.Order, error)
+	RetrieveOrderHistory(id string) ([]models.OrderAuditEntry, error)
 	RetrieveOrder(id string) ([]byte, error)
 	UpdateOrder(id string, item models.Order) error
+	PatchOrder(id string, patch models.OrderPatch) (models.Order, error)
 	DeleteOrder(id string) error
 	CloseOrder(id string) error
+	CloseAllOrders() (models.BulkClose, error)
+	ClosePartialOrder(id string) (models.PartialClose, error)
+	ReserveInventory(orderID string) (models.InventoryReservation, error)
+	ReleaseInventory(orderID string) error
+	BackfillReservations() (int, error)
 	IsInventorySufficient(orderItems []models.OrderItem) (bool, error)
-	ReduceIngredients(orderItems []models.OrderItem) error
+	CheckInventory(orderItems []models.OrderItem) ([]models.Shortage, error)
+	RetrieveAvailableMenu() ([]models.MenuItem, error)
+	CheckOrder(o models.Order) (models.InventoryCheck, error)
+	CalculateRequirements(r models.RequirementsRequest) (models.Requirements, error)
+	ReduceIngredients(orderID string, orderItems []models.OrderItem) error
+	SimulateReduce(orderItems []models.OrderItem) ([]models.InventoryItem, error)
 	CalculateTotalSales() (float64, error)
+	ExportOrders() ([]models.OrderExport, error)
+	WithActor(actor string) OrderService
 }
 
 type orderService struct {
-	OrderRepository     dal.OrderRepository
-	MenuRepository      dal.MenuRepository
-	InventoryRepository dal.InventoryRepository
-	ReportRepository    dal.ReportRepository
+	OrderRepository       dal.OrderRepository
+	MenuRepository        dal.MenuRepository
+	InventoryRepository   dal.InventoryRepository
+	ReportRepository      dal.ReportRepository
+	OrderAuditRepository  dal.OrderAuditRepository
+	ReservationRepository dal.ReservationRepository
+
+	// taxRate is the tax in percent applied to the discounted order subtotal
+	taxRate float64
+
+	// actor is who makes the changes, recorded on the audit entries
+	actor string
 }
 
-func NewOrderService(or dal.OrderRepository, menu dal.MenuRepository, ir dal.InventoryRepository, re dal.ReportRepository) *orderService {
-	if or == nil || ir == nil {
+func NewOrderService(or dal.OrderRepository, menu dal.MenuRepository, ir dal.InventoryRepository, re dal.ReportRepository, au dal.OrderAuditRepository, rv dal.ReservationRepository, taxRate float64) *orderService {
+	if or == nil || ir == nil || au == nil || rv == nil {
 		return nil
 	}
-	return &orderService{OrderRepository: or, MenuRepository: menu, InventoryRepository: ir, ReportRepository: re}
+	return &orderService{OrderRepository: or, MenuRepository: menu, InventoryRepository: ir, ReportRepository: re, OrderAuditRepository: au, ReservationRepository: rv, taxRate: taxRate}
 }
 
-func ValidateOrder(o models.Order) error {
-	if strings.Contains(o.ID, " ") {
-		return ErrNotValidOrderID
+// maxNoteLength is the maximum number of characters in order notes and item instructions
+const maxNoteLength = 500
+
+// maxTagLength is the maximum number of characters in an order tag
+const maxTagLength = 32
+
+// maxOrderPriority is the highest order priority, orders without a priority have priority 0
+const maxOrderPriority = 5
+
+// orderIDPattern is the format of client supplied order IDs, the generated "ordersN" IDs match it too
+var orderIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidateOrderID checks that a client supplied order ID matches orderIDPattern.
+// Returns ErrNotValidOrderID wrapped with a description of the expected format otherwise.
+func ValidateOrderID(id string) error {
+	if !orderIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: must be 1 to 64 letters, digits, dashes or underscores", ErrNotValidOrderID)
+	}
+	return nil
+}
+
+// ValidateOrderCollecting checks every field of an incoming order and returns all problems found
+// instead of stopping at the first one. An empty result means the order is valid.
+func ValidateOrderCollecting(o models.Order) []models.FieldError {
+	v := &fieldValidator{}
+
+	// An empty ID is generated on save
+	if o.ID != "" {
+		v.checkErr("order_id", ValidateOrderID(o.ID))
+	}
+
+	v.check(o.CustomerName != "", "customer_name", ErrNotValidOrderCustomerName)
+	validateOrderItemFields(v, o.Items)
+
+	v.check(o.Status == "", "status", ErrNotValidStatusField)
+	v.check(o.CreatedAt == "", "created_at", ErrNotValidCreatedAt)
+	v.check(isAtMost(o.Notes, maxNoteLength), "notes", ErrNotValidOrderNotes)
+	v.check(o.Priority >= 0 && o.Priority <= maxOrderPriority, "priority", ErrNotValidOrderPriority)
+
+	for i, tag := range o.Tags {
+		valid := tag != "" && !strings.ContainsFunc(tag, unicode.IsSpace) && isAtMost(tag, maxTagLength)
+		v.check(valid, fmt.Sprintf("tags[%d]", i), ErrNotValidOrderTag)
+	}
+
+	// The fixed amount is checked against the subtotal once the menu prices are known, see validateDiscountAmount
+	if d := o.Discount; d != nil {
+		switch {
+		case d.Type != models.DiscountPercentage && d.Type != models.DiscountFixed:
+			v.check(false, "discount.type", ErrNotValidDiscountType)
+		case math.IsNaN(d.Value) || math.IsInf(d.Value, 0):
+			v.check(false, "discount.value", ErrNotValidDiscountAmount)
+		case d.Type == models.DiscountPercentage:
+			v.check(d.Value >= 0 && d.Value <= 100, "discount.value", ErrNotValidDiscountPercent)
+		case d.Type == models.DiscountFixed:
+			v.check(d.Value >= 0, "discount.value", ErrNotValidDiscountAmount)
+		}
+	}
+
+	return v.fieldErrors()
+}
+
+// validateOrderItemFields checks the list of order items and the fields of each item.
+func validateOrderItemFields(v *fieldValidator, items []models.OrderItem) {
+	v.check(len(items) > 0, "items", ErrNotValidOrderItems)
+
+	for i, item := range items {
+		field := fmt.Sprintf("items[%d]", i)
+
+		v.check(isValidID(item.ProductID), field+".product_id", ErrNotValidOrderProductID)
+
+		// Only the later of two repeated items is reported
+		v.check(!slices.ContainsFunc(items[:i], func(previous models.OrderItem) bool {
+			return item.ProductID == previous.ProductID && strings.EqualFold(item.Size, previous.Size)
+		}), field, ErrDuplicateOrderItems)
+
+		// Fractional quantities are checked against the menu, only products sold by weight allow them
+		v.check(isPositive(item.Quantity), field+".quantity", ErrNotValidQuantity)
+		v.check(isAtMost(item.Instructions, maxNoteLength), field+".instructions", ErrNotValidItemInstructions)
+	}
+}
+
+// normalizeTags lowercases the order tags and drops repeated ones, keeping the first occurrence.
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// validateProductsExist returns a ValidationError listing every order item whose product is not on the menu
+// and every fractional quantity of a product not sold by weight.
+func validateProductsExist(orderItems []models.OrderItem, menuMap map[string]models.MenuItem) error {
+	fieldErrors := []models.FieldError{}
+	for i, item := range orderItems {
+		if _, exists := menuMap[item.ProductID]; !exists {
+			fieldErrors = append(fieldErrors, models.FieldError{
+				Field:   fmt.Sprintf("items[%d].product_id", i),
+				Message: fmt.Sprintf("%v: '%s'", ErrProductNotFound, item.ProductID),
+			})
+		} else if !isAllowedQuantity(item, menuMap) {
+			fieldErrors = append(fieldErrors, models.FieldError{
+				Field:   fmt.Sprintf("items[%d].quantity", i),
+				Message: ErrFractionalQuantity.Error(),
+			})
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return &ValidationError{Errors: fieldErrors}
+	}
+	return nil
+}
+
+// isAllowedQuantity reports whether the item quantity is allowed by its menu item:
+// products sold by weight take any positive quantity, the others whole numbers only.
+// Items of products missing from the menu are left to the product check.
+func isAllowedQuantity(item models.OrderItem, menuMap map[string]models.MenuItem) bool {
+	menuItem, exists := menuMap[item.ProductID]
+	return !exists || menuItem.SoldByWeight || item.Quantity == math.Trunc(item.Quantity)
+}
+
+// checkWholeQuantities returns ErrFractionalQuantity if a product not sold by weight is ordered in a fractional quantity.
+func checkWholeQuantities(orderItems []models.OrderItem, menuMap map[string]models.MenuItem) error {
+	for _, item := range orderItems {
+		if !isAllowedQuantity(item, menuMap) {
+			return ErrFractionalQuantity
+		}
+	}
+	return nil
+}
+
+// validateDiscountAmount returns a ValidationError if a fixed discount exceeds the order subtotal.
+func validateDiscountAmount(o models.Order, menuMap map[string]models.MenuItem) error {
+	if o.Discount == nil || o.Discount.Type != models.DiscountFixed {
+		return nil
+	}
+
+	if o.Discount.Value > orderSubtotal(o, menuMap) {
+		return &ValidationError{Errors: []models.FieldError{{Field: "discount.value", Message: ErrNotValidDiscountAmount.Error()}}}
+	}
+	return nil
+}
+
+// ValidateOrderItems validates a list of order items on their own, e.g. for an inventory check.
+// Returns the error of the first invalid field, see ValidateOrderCollecting.
+func ValidateOrderItems(items []models.OrderItem) error {
+	v := &fieldValidator{}
+	validateOrderItemFields(v, items)
+	return v.err()
+}
+
+// ValidateCreatedAt checks that a stored creation time is a parseable RFC3339 timestamp
+// that is not in the future. It guards against corrupted or hand-edited data files.
+// The following errors may be returned:
+// - ErrMalformedCreatedAt if the timestamp can not be parsed.
+// - ErrFutureCreatedAt if the timestamp is later than the current time.
+func ValidateCreatedAt(createdAt string) error {
+	t, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return ErrMalformedCreatedAt
+	}
+
+	if t.After(time.Now()) {
+		return ErrFutureCreatedAt
+	}
+
+	return nil
+}
+
+// ValidateStatus checks that a stored order status is a known status in any casing.
+// It guards against corrupted or hand-edited data files.
+// Returns ErrUnknownStatus if the status is not known.
+func ValidateStatus(status models.OrderStatus) error {
+	if !status.IsValid() {
+		return ErrUnknownStatus
+	}
+	return nil
+}
+
+// AddOrder validates the order and saves it as a new open order.
+// If the order ID is empty, a unique ID is generated by the repository.
+// Returns the created order with its ID, status and creation time set.
+func (s *orderService) AddOrder(order models.Order) (models.Order, error) {
+	// Order validation
+	if fieldErrors := ValidateOrderCollecting(order); len(fieldErrors) > 0 {
+		return models.Order{}, &ValidationError{Errors: fieldErrors}
+	}
+
+	// Client supplied IDs must be unique, empty IDs are generated on save
+	if order.ID != "" {
+		if exists, err := s.OrderRepository.OrderExists(order); err != nil {
+			return models.Order{}, err
+		} else if exists {
+			return models.Order{}, ErrNotUniqueOrder
+		}
+	}
+
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	// Unknown products are reported before any inventory math
+	if err := validateProductsExist(order.Items, menuMap); err != nil {
+		return models.Order{}, err
+	}
+
+	if err := s.checkProductsAvailable(order.Items); err != nil {
+		return models.Order{}, err
+	}
+
+	_, err = s.IsInventorySufficient(order.Items)
+	if err != nil {
+		if errors.Is(err, ErrNotEnoughInventoryQuantity) {
+			inventoryRejections.Inc()
+		}
+		return models.Order{}, err
+	}
+
+	clearManagedFields(order.Items)
+
+	if err := validateDiscountAmount(order, menuMap); err != nil {
+		return models.Order{}, err
+	}
+
+	order.Tags = normalizeTags(order.Tags)
+	order.HistoryID = newHistoryID()
+	order.Status = models.StatusOpen
+	order.CreatedAt = time.Now().Format(time.RFC3339)
+	order.UpdatedAt = order.CreatedAt
+	order.ClosedAt = ""
+	order.TaxRate = nil
+
+	createdOrder, err := s.OrderRepository.AddOrder(order)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	// An order that can not hold its stock is not created
+	if _, err := s.reserveOrder(createdOrder); err != nil {
+		if deleteErr := s.OrderRepository.DeleteOrderById(createdOrder.ID); deleteErr != nil {
+			logger.LOGGER.PrintErrorMsg("Failed to delete order %s after failed reservation: %v", createdOrder.ID, deleteErr)
+			return models.Order{}, fmt.Errorf("%w (order rollback failed: %v)", err, deleteErr)
+		}
+		return models.Order{}, err
+	}
+	s.recordAudit(models.AuditCreated, nil, &createdOrder)
+
+	s.setDerivedFields(&createdOrder, menuMap)
+	ordersCreated.Inc()
+
+	return createdOrder, nil
+}
+
+// checkProductsAvailable returns ErrProductUnavailable if any order item references an unavailable menu item.
+// Unknown products are left to the inventory check.
+func (s *orderService) checkProductsAvailable(orderItems []models.OrderItem) error {
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return err
+	}
+
+	for _, orderItem := range orderItems {
+		if menuItem, exists := menuMap[orderItem.ProductID]; exists && !menuItem.Available {
+			return ErrProductUnavailable
+		}
+	}
+
+	return nil
+}
+
+// menuItemsByID returns the current menu items by their ID, with the combos resolved by indexMenuItems.
+func (s *orderService) menuItemsByID() (map[string]models.MenuItem, error) {
+	menuItems, err := s.MenuRepository.GetAllMenuItems()
+	if err != nil {
+		return nil, err
+	}
+
+	menuMap := indexMenuItems(menuItems)
+	return menuMap, nil
+}
+
+// setDerivedFields sets the fields of an order that are computed on read: the price breakdown
+// and, for closed orders, the fulfillment time.
+func (s *orderService) setDerivedFields(order *models.Order, menuMap map[string]models.MenuItem) {
+	priceOrder(*order, menuMap, s.taxRate).setOn(order)
+
+	order.FulfillmentSeconds = nil
+	if seconds, ok := fulfillmentSeconds(*order); ok {
+		order.FulfillmentSeconds = &seconds
+	}
+}
+
+// fulfillmentSeconds returns the whole seconds from the creation to the closing of a closed order.
+// Reports false for open orders and for timestamps that are missing, malformed or out of order.
+func fulfillmentSeconds(order models.Order) (int64, bool) {
+	if order.Status.Normalize() != models.StatusClosed {
+		return 0, false
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, order.CreatedAt)
+	if err != nil {
+		return 0, false
+	}
+	closedAt, err := time.Parse(time.RFC3339, order.ClosedAt)
+	if err != nil || closedAt.Before(createdAt) {
+		return 0, false
+	}
+
+	return int64(closedAt.Sub(createdAt) / time.Second), true
+}
+
+// priceOrder returns the price breakdown of the order with the tax rate it was closed with applied,
+// or the given current tax rate, in percent, if none was recorded.
+func priceOrder(order models.Order, menuMap map[string]models.MenuItem, taxRate float64) orderPricing {
+	return newOrderPricing(orderSubtotal(order, menuMap), order.Discount, orderTaxRate(order, taxRate))
+}
+
+// orderSubtotal sums the price of every order item times its quantity, see orderItemPrice.
+// Items whose product or size is no longer on the menu do not contribute to the subtotal.
+func orderSubtotal(order models.Order, menuMap map[string]models.MenuItem) float64 {
+	total := 0.0
+	for _, item := range order.Items {
+		price, err := orderItemPrice(item, menuMap[item.ProductID])
+		if err != nil {
+			continue
+		}
+		total += price * item.Quantity
+	}
+	return total
+}
+
+// OrderFilter narrows down an order listing, empty fields match every order.
+type OrderFilter struct {
+	Status    models.OrderStatus
+	ProductID string
+	Tag       string
+
+	// CreatedSince keeps the orders created at or after the time, when it is not zero
+	CreatedSince time.Time
+
+	// Sort is the field the orders are sorted by, created_at when empty,
+	// and Order is the direction, asc or desc, desc when empty
+	Sort  string
+	Order string
+}
+
+// orderSortFields maps the sortable fields of an order listing to their comparison
+var orderSortFields = map[string]func(a, b models.Order) int{
+	"created_at": func(a, b models.Order) int { return compareCreatedAt(a.CreatedAt, b.CreatedAt) },
+	"customer_name": func(a, b models.Order) int {
+		return strings.Compare(strings.ToLower(a.CustomerName), strings.ToLower(b.CustomerName))
+	},
+	"status": func(a, b models.Order) int {
+		return strings.Compare(string(a.Status.Normalize()), string(b.Status.Normalize()))
+	},
+}
+
+// orderComparison returns the comparison sorting the orders as the filter asks for.
+// The following errors may be returned:
+// - ErrNotValidSortField if the sort field is not sortable.
+// - ErrNotValidSortOrder if the direction is not asc or desc.
+func orderComparison(filter OrderFilter) (func(a, b models.Order) int, error) {
+	field := filter.Sort
+	if field == "" {
+		field = "created_at"
+	}
+	compare, exists := orderSortFields[field]
+	if !exists {
+		return nil, ErrNotValidSortField
+	}
+
+	switch filter.Order {
+	case "asc":
+		return compare, nil
+	case "", "desc":
+		return func(a, b models.Order) int { return compare(b, a) }, nil
+	default:
+		return nil, ErrNotValidSortOrder
+	}
+}
+
+// RetrieveOrders returns the orders matching the filter with their total prices, sorted as the filter asks for.
+// Encoding is left to the caller, so large listings can be streamed to the client.
+// The following errors may be returned:
+// - ErrUnknownStatus if the status filter is not a known status.
+// - ErrProductNotFound if the product filter is not on the menu.
+// - ErrNotValidSortField or ErrNotValidSortOrder if the sorting is not valid.
+func (s *orderService) RetrieveOrders(filter OrderFilter) ([]models.Order, error) {
+	if filter.Status != "" {
+		if err := ValidateStatus(filter.Status); err != nil {
+			return nil, err
+		}
+	}
+
+	compare, err := orderComparison(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.ProductID != "" {
+		if _, exists := menuMap[filter.ProductID]; !exists {
+			return nil, ErrProductNotFound
+		}
+	}
+
+	orders, err := s.OrderRepository.GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	filteredOrders := []models.Order{}
+	for _, order := range orders {
+		if filter.Status != "" && order.Status.Normalize() != filter.Status.Normalize() {
+			continue
+		}
+		if filter.ProductID != "" && !slices.ContainsFunc(order.Items, func(item models.OrderItem) bool {
+			return item.ProductID == filter.ProductID
+		}) {
+			continue
+		}
+		if filter.Tag != "" && !slices.Contains(order.Tags, strings.ToLower(filter.Tag)) {
+			continue
+		}
+		if !filter.CreatedSince.IsZero() {
+			createdAt, err := time.Parse(time.RFC3339, order.CreatedAt)
+			if err != nil || createdAt.Before(filter.CreatedSince) {
+				continue
+			}
+		}
+
+		s.setDerivedFields(&order, menuMap)
+		filteredOrders = append(filteredOrders, order)
+	}
+
+	// Orders comparing equal keep the order they were saved in
+	slices.SortStableFunc(filteredOrders, compare)
+
+	return filteredOrders, nil
+}
+
+// RetrieveOrderQueue returns the open orders in the order they should be prepared,
+// the highest priority first and the oldest first within the same priority.
+func (s *orderService) RetrieveOrderQueue() ([]models.Order, error) {
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return nil, err
 	}
 
-	if o.CustomerName == "" {
-		return ErrNotValidOrderCustomerName
+	orders, err := s.OrderRepository.GetOpenOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	sortByQueue(orders)
+
+	for i := range orders {
+		s.setDerivedFields(&orders[i], menuMap)
+	}
+
+	return orders, nil
+}
+
+// sortByQueue sorts the orders by priority, the highest first, and by creation time, the oldest first.
+func sortByQueue(orders []models.Order) {
+	slices.SortStableFunc(orders, func(a, b models.Order) int {
+		if a.Priority != b.Priority {
+			return b.Priority - a.Priority
+		}
+		return compareCreatedAt(a.CreatedAt, b.CreatedAt)
+	})
+}
+
+// RetrieveOrdersUsingIngredient returns the open orders with an item whose recipe, for the ordered size
+// and including combo components, uses the ingredient. Items of products no longer on the menu are skipped.
+// Returns ErrNoItem if the ingredient is not in the inventory.
+func (s *orderService) RetrieveOrdersUsingIngredient(ingredientID string) ([]models.Order, error) {
+	if _, err := s.InventoryRepository.GetItemById(ingredientID); err != nil {
+		if err.Error() == "item not found" {
+			return nil, ErrNoItem
+		}
+		return nil, err
+	}
+
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.OrderRepository.GetOpenOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	usesIngredient := func(item models.OrderItem) bool {
+		recipe, err := orderItemRecipe(item, menuMap)
+		if err != nil {
+			return false
+		}
+		return slices.ContainsFunc(recipe, func(ingredient models.MenuItemIngredient) bool {
+			return ingredient.IngredientID == ingredientID
+		})
+	}
+
+	affectedOrders := []models.Order{}
+	for _, order := range orders {
+		if !slices.ContainsFunc(order.Items, usesIngredient) {
+			continue
+		}
+
+		s.setDerivedFields(&order, menuMap)
+		affectedOrders = append(affectedOrders, order)
+	}
+
+	return affectedOrders, nil
+}
+
+// compareCreatedAt compares two RFC3339 creation times, falling back to comparing the strings if either can not be parsed.
+func compareCreatedAt(a, b string) int {
+	timeA, errA := time.Parse(time.RFC3339, a)
+	timeB, errB := time.Parse(time.RFC3339, b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return timeA.Compare(timeB)
+}
+
+func (s *orderService) RetrieveOrder(id string) ([]byte, error) {
+	var order models.Order
+	order, err := s.OrderRepository.GetOrderById(id)
+	if err != nil {
+		if err.Error() == "EOF" {
+			return nil, ErrNoOrder
+		}
+		return nil, err
+	}
+
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return nil, err
+	}
+	s.setDerivedFields(&order, menuMap)
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *orderService) UpdateOrder(id string, order models.Order) error {
+	currentOrder, err := s.OrderRepository.GetOrderById(id)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return ErrNoOrder
+		}
+		return err
+	}
+
+	// Closed orders are final and can not be edited
+	if err := ValidateStatus(currentOrder.Status); err != nil {
+		return err
+	}
+	if currentOrder.Status.Normalize() == models.StatusClosed {
+		return ErrOrderClosed
 	}
 
-	err := ValidateOrderItems(o.Items)
+	if err := ValidateCreatedAt(currentOrder.CreatedAt); err != nil {
+		return err
+	}
+
+	if fieldErrors := ValidateOrderCollecting(order); len(fieldErrors) > 0 {
+		return &ValidationError{Errors: fieldErrors}
+	}
+	clearManagedFields(order.Items)
+
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return err
+	}
+	if err := validateProductsExist(order.Items, menuMap); err != nil {
+		return err
+	}
+	if err := validateDiscountAmount(order, menuMap); err != nil {
+		return err
+	}
+
+	// Renaming the order must not collide with another order
+	if order.ID == "" {
+		order.ID = id
+	} else if order.ID != id {
+		if exists, err := s.OrderRepository.OrderExists(order); err != nil {
+			return err
+		} else if exists {
+			return ErrNotUniqueOrder
+		}
+	}
+	order.Tags = normalizeTags(order.Tags)
+	order.HistoryID = historyKey(currentOrder)
+	order.Status = models.StatusOpen
+	order.CreatedAt = currentOrder.CreatedAt
+	order.UpdatedAt = time.Now().Format(time.RFC3339)
+	order.ClosedAt = ""
+	order.TaxRate = nil
+
+	// The reservation follows the new items, the current reservation of the order is free for them.
+	// It is saved before the order, so no other order can take the stock in between
+	previousReservation, err := s.findReservation(id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.reserveItems(order.ID, order.Items, id); err != nil {
+		if errors.Is(err, ErrNotEnoughInventoryQuantity) {
+			inventoryRejections.Inc()
+		}
+		return err
+	}
+
+	err = s.OrderRepository.RewriteOrder(id, order)
+	if err != nil {
+		s.restoreReservation(order.ID, previousReservation)
+		return err
+	}
+	s.recordAudit(models.AuditUpdated, &currentOrder, &order)
+
+	return nil
+}
+
+// PatchOrder changes the customer name and notes of an open order without touching its items.
+// Returns the updated order.
+// The following errors may be returned:
+// - ErrNotValidOrderIDField, ErrNotValidStatusField or ErrNotValidCreatedAt if the patch tries to change these fields.
+// - ErrEmptyOrderPatch if the patch does not change anything.
+// - ErrNotValidOrderCustomerName or ErrNotValidOrderNotes if the new values are not valid.
+// - ErrNoOrder if the order is not found.
+// - ErrOrderClosed if the order is closed.
+func (s *orderService) PatchOrder(id string, patch models.OrderPatch) (models.Order, error) {
+	if patch.ID != nil {
+		return models.Order{}, ErrNotValidOrderIDField
+	}
+	if patch.Status != nil {
+		return models.Order{}, ErrNotValidStatusField
+	}
+	if patch.CreatedAt != nil {
+		return models.Order{}, ErrNotValidCreatedAt
+	}
+
+	if patch.CustomerName == nil && patch.Notes == nil {
+		return models.Order{}, ErrEmptyOrderPatch
+	}
+
+	order, err := s.OrderRepository.GetOrderById(id)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return models.Order{}, ErrNoOrder
+		}
+		return models.Order{}, err
+	}
+
+	// Closed orders are final and can not be edited
+	if err := ValidateStatus(order.Status); err != nil {
+		return models.Order{}, err
+	}
+	if order.Status.Normalize() == models.StatusClosed {
+		return models.Order{}, ErrOrderClosed
+	}
+
+	currentOrder := order
+	if patch.CustomerName != nil {
+		if *patch.CustomerName == "" {
+			return models.Order{}, ErrNotValidOrderCustomerName
+		}
+		order.CustomerName = *patch.CustomerName
+	}
+
+	if patch.Notes != nil {
+		if utf8.RuneCountInString(*patch.Notes) > maxNoteLength {
+			return models.Order{}, ErrNotValidOrderNotes
+		}
+		order.Notes = *patch.Notes
+	}
+
+	order.UpdatedAt = time.Now().Format(time.RFC3339)
+
+	if err := s.OrderRepository.RewriteOrder(id, order); err != nil {
+		return models.Order{}, err
+	}
+	s.recordAudit(models.AuditUpdated, &currentOrder, &order)
+
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return models.Order{}, err
+	}
+	s.setDerivedFields(&order, menuMap)
+
+	return order, nil
+}
+
+// DeleteOrder deletes an order by its ID and releases its inventory reservation.
+// The following errors may be returned:
+// - ErrNoOrder if the order is not found.
+// - An error if there is a failure when reading or saving the orders.
+func (s *orderService) DeleteOrder(id string) error {
+	order, err := s.OrderRepository.GetOrderById(id)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return ErrNoOrder
+		}
+		return err
+	}
+
+	if err := s.OrderRepository.DeleteOrderById(id); err != nil {
+		if err.Error() == "order not found" {
+			return ErrNoOrder
+		}
+		return err
+	}
+	s.recordAudit(models.AuditDeleted, &order, nil)
+	s.releaseReservation(id)
+
+	return nil
+}
+
+func (s *orderService) CloseOrder(id string) error {
+	// TODO: Когда заказ закрывается через /orders/{id}/close, система считает, что заказ выполнен, и обновляет инвентарь, вычитая количество ингредиентов, необходимых для его выполнения.
+	// ? TODO: Закрытие также означает, что заказ включается в итоговую статистику для расчетов выручки и популярных позиций.
+
+	order, err := s.openOrderToClose(id)
+	if err != nil {
+		return err
+	}
+
+	return s.closeOrder(order, order.Items)
+}
+
+// CloseAllOrders closes every open order the inventory can fulfill, in queue order, so the stock goes
+// to the orders that would be prepared first. Each order is closed whole or not at all: an order the
+// inventory can not fulfill is left open without any deduction. The result of every open order is reported.
+// Returns an error only if the open orders can not be read.
+func (s *orderService) CloseAllOrders() (models.BulkClose, error) {
+	orders, err := s.OrderRepository.GetOpenOrders()
 	if err != nil {
-		return err
+		return models.BulkClose{}, err
 	}
+	sortByQueue(orders)
 
-	if o.Status != "" || o.Status == "closed" {
-		return ErrNotValidStatusField
-	}
+	result := models.BulkClose{Orders: []models.BulkCloseResult{}}
+	for _, order := range orders {
+		orderResult := models.BulkCloseResult{OrderID: order.ID}
+
+		// The order is loaded again, it may have been changed or closed since the list was read
+		err := s.CloseOrder(order.ID)
+		switch {
+		case err == nil:
+			orderResult.Status = models.BulkCloseClosed
+			result.Closed++
+		case errors.Is(err, ErrNotEnoughInventoryQuantity):
+			orderResult.Status = models.BulkCloseInsufficient
+			result.Insufficient++
+		default:
+			logger.LOGGER.PrintErrorMsg("Failed to close order %s: %v", order.ID, err)
+			orderResult.Status = models.BulkCloseFailed
+			orderResult.Code = ErrorCode(err)
+			orderResult.Error = err.Error()
+			result.Failed++
+		}
 
-	if o.CreatedAt != "" {
-		return ErrNotValidCreatedAt
+		result.Orders = append(result.Orders, orderResult)
 	}
 
-	return nil
+	return result, nil
 }
 
-func ValidateOrderItems(items []models.OrderItem) error {
-	if items == nil || len(items) < 1 {
-		return ErrNotValidOrderItems
+// ClosePartialOrder closes the items of an open order the inventory can fulfill and moves the other items
+// to a new open order, so they can be closed once the inventory is restocked. Items are fulfilled whole,
+// in order. The remaining order keeps the customer, notes, tags and creation time of the original order,
+// a percentage discount applies to both orders while a fixed discount stays with the closed one.
+// The remaining order takes over the reservation of the original order, see reserveRemaining.
+// Returns the IDs of the closed order and of the remaining order, which is empty if every item was fulfilled.
+// The following errors may be returned:
+// - ErrNoOrder if the order is not found.
+// - ErrOrderAlreadyClosed if the order is already closed.
+// - ErrNotEnoughInventoryQuantity if none of the items can be fulfilled.
+// - An error if the order can not be priced or saved, the inventory and sales are rolled back then.
+func (s *orderService) ClosePartialOrder(id string) (models.PartialClose, error) {
+	order, err := s.openOrderToClose(id)
+	if err != nil {
+		return models.PartialClose{}, err
 	}
 
-	for k, item := range items {
-		if item.ProductID == "" || strings.Contains(item.ProductID, " ") {
-			return ErrNotValidIngredientID
-		}
-
-		for l, item2 := range items {
-			if item.ProductID == item2.ProductID && k != l {
-				return ErrDuplicateOrderItems
-			}
-		}
+	fulfilledItems, remainingItems, err := s.splitFulfillable(order)
+	if err != nil {
+		return models.PartialClose{}, err
+	}
+	if len(fulfilledItems) == 0 {
+		inventoryRejections.Inc()
+		return models.PartialClose{}, ErrNotEnoughInventoryQuantity
+	}
 
-		if item.Quantity < 1 {
-			return ErrNotValidQuantity
-		}
+	result := models.PartialClose{ClosedOrderID: order.ID}
+	if len(remainingItems) == 0 {
+		return result, s.closeOrder(order, order.Items)
 	}
-	return nil
-}
 
-func (s *orderService) AddOrder(order models.Order) error {
-	if exists, err := s.OrderRepository.OrderExists(order); err != nil {
-		return err
-	} else if exists {
-		return ErrNotUniqueOrder
+	remainingOrder := models.Order{
+		HistoryID:    newHistoryID(),
+		CustomerName: order.CustomerName,
+		Items:        remainingItems,
+		Status:       models.StatusOpen,
+		CreatedAt:    order.CreatedAt,
+		UpdatedAt:    time.Now().Format(time.RFC3339),
+		Notes:        order.Notes,
+		Tags:         order.Tags,
+	}
+	if order.Discount != nil && order.Discount.Type == models.DiscountPercentage {
+		remainingOrder.Discount = order.Discount
 	}
 
-	_, err := s.IsInventorySufficient(order.Items)
+	// Unit prices pinned on the items are kept, the remaining items are still sold at the original prices
+	createdOrder, err := s.OrderRepository.AddOrder(remainingOrder)
 	if err != nil {
-		return err
+		return models.PartialClose{}, err
 	}
 
-	// Order validation
-	if err := ValidateOrder(order); err != nil {
-		return err
+	// The remaining order takes over the reservation of the original order, less the stock of the closed items
+	previousReservation, err := s.findReservation(order.ID)
+	if err == nil {
+		_, err = s.reserveRemaining(createdOrder, order.ID, fulfilledItems)
+	}
+	if err == nil {
+		err = s.closeOrder(order, fulfilledItems)
+	}
+	if err != nil {
+		s.restoreReservation(createdOrder.ID, previousReservation)
+		if deleteErr := s.OrderRepository.DeleteOrderById(createdOrder.ID); deleteErr != nil {
+			logger.LOGGER.PrintErrorMsg("Failed to delete remaining order %s after failed partial close: %v", createdOrder.ID, deleteErr)
+			return models.PartialClose{}, fmt.Errorf("%w (remaining order rollback failed: %v)", err, deleteErr)
+		}
+		return models.PartialClose{}, err
 	}
 
-	order.Status = "Open"
-	order.CreatedAt = time.Now().Format(time.RFC3339)
+	s.recordAudit(models.AuditCreated, nil, &createdOrder)
 
-	if _, err := s.OrderRepository.AddOrder(order); err != nil {
-		return err
-	}
-	return nil
+	result.RemainingOrderID = createdOrder.ID
+	return result, nil
 }
 
-func (s *orderService) RetrieveOrders() ([]byte, error) {
-	orders, err := s.OrderRepository.GetAllOrders()
+// splitFulfillable splits the items of the order into the items the inventory can fulfill together
+// and the items it can not, keeping their order.
+func (s *orderService) splitFulfillable(order models.Order) ([]models.OrderItem, []models.OrderItem, error) {
+	fulfilledItems := []models.OrderItem{}
+	remainingItems := []models.OrderItem{}
+
+	// The stock reserved by the other open orders can not be used, like when the order is closed
+	reserved, err := s.reservedQuantities(order.ID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	data, err := json.MarshalIndent(orders, "", " ")
-	if err != nil {
-		return nil, err
+	for _, item := range order.Items {
+		_, _, err := s.projectReduction(append(slices.Clone(fulfilledItems), item), reserved)
+		if errors.Is(err, ErrNotEnoughInventoryQuantity) {
+			remainingItems = append(remainingItems, item)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		fulfilledItems = append(fulfilledItems, item)
 	}
 
-	return data, nil
+	return fulfilledItems, remainingItems, nil
 }
 
-func (s *orderService) RetrieveOrder(id string) ([]byte, error) {
-	var order models.Order
+// openOrderToClose loads an order and checks that it can be closed.
+// The check is repeated under the order repository lock when the order is closed, see closeOrder.
+func (s *orderService) openOrderToClose(id string) (models.Order, error) {
 	order, err := s.OrderRepository.GetOrderById(id)
 	if err != nil {
-		if err.Error() == "EOF" {
-			return nil, ErrNoOrder
+		if err.Error() == "order not found" {
+			return models.Order{}, ErrNoOrder
 		}
-		return nil, err
+		return models.Order{}, err
 	}
 
-	data, err := json.MarshalIndent(order, "", " ")
-	if err != nil {
-		return nil, err
+	if err := ValidateStatus(order.Status); err != nil {
+		return models.Order{}, err
 	}
 
-	return data, nil
-}
+	// Closing an already closed order must not deduct the ingredients twice
+	if order.Status.Normalize() == models.StatusClosed {
+		return models.Order{}, ErrOrderAlreadyClosed
+	}
 
-func (s *orderService) UpdateOrder(id string, order models.Order) error {
-	// ! TODO: Think about created time
+	return order, nil
+}
 
-	if err := ValidateOrder(order); err != nil {
+// closeOrder deducts the ingredients of the given items of an open order, records their sale
+// and saves the order as closed with only these items.
+// The status check, the deduction, the sale and the save are one locked update of the orders,
+// so of two concurrent closes of the same order the second finds it closed and deducts nothing.
+func (s *orderService) closeOrder(order models.Order, items []models.OrderItem) error {
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
 		return err
 	}
-	if order.ID == "" {
-		order.ID = id
-	}
-	order.Status = "open"
 
-	err := s.OrderRepository.RewriteOrder(id, order)
+	// The deduction and the rollback use the snapshots too, so exactly the recorded recipes are deducted
+	order.Items = snapshotItems(items, menuMap)
+
+	// The order keeps the tax rate it was sold with
+	taxRate := s.taxRate
+	order.TaxRate = &taxRate
+
+	pricing := priceOrder(order, menuMap, s.taxRate)
+	logger.LOGGER.PrintDebugMsg("Order %s total price: %.2f", order.ID, pricing.total)
+
+	var currentOrder models.Order
+	var sale models.TotalSales
+	deducted := false
+	err = s.OrderRepository.UpdateOrders(func(orders []models.Order) ([]models.Order, error) {
+		index := slices.IndexFunc(orders, func(o models.Order) bool { return o.ID == order.ID })
+		if index < 0 {
+			return nil, ErrNoOrder
+		}
+		if orders[index].Status.Normalize() == models.StatusClosed {
+			return nil, ErrOrderAlreadyClosed
+		}
+		currentOrder = orders[index]
+
+		if err := s.ReduceIngredients(order.ID, order.Items); err != nil {
+			if errors.Is(err, ErrNotEnoughInventoryQuantity) {
+				inventoryRejections.Inc()
+			}
+			return nil, err
+		}
+
+		pricing.add(&sale)
+		if err := s.ReportRepository.RecordSale(sale); err != nil {
+			return nil, s.rollbackClose(err, order.Items, nil)
+		}
+		deducted = true
+
+		order.Status = models.StatusClosed
+		order.ClosedAt = time.Now().Format(time.RFC3339)
+		order.UpdatedAt = order.ClosedAt
+		orders[index] = order
+		return orders, nil
+	})
 	if err != nil {
+		// The deduction and the sale are undone if the order can not be saved as closed,
+		// otherwise a retry would deduct the ingredients twice
+		if deducted {
+			return s.rollbackClose(err, order.Items, &sale)
+		}
 		return err
 	}
+	ordersClosed.Inc()
+	s.recordAudit(models.AuditClosed, &currentOrder, &order)
+	s.releaseReservation(order.ID)
 
 	return nil
 }
 
-func (s *orderService) DeleteOrder(id string) error {
-	return s.OrderRepository.DeleteOrderById(id)
-}
+// rollbackClose puts the ingredients of the closed order items back into the inventory and, if given,
+// takes the recorded sale of the order back out of the total sales. Sales recorded by other orders are kept.
+// Returns the error that caused the rollback, annotated if the rollback itself failed.
+func (s *orderService) rollbackClose(cause error, orderItems []models.OrderItem, sale *models.TotalSales) error {
+	if err := s.restockIngredients(orderItems); err != nil {
+		logger.LOGGER.PrintErrorMsg("Failed to restore inventory after failed order close: %v", err)
+		return fmt.Errorf("%w (inventory rollback failed: %v)", cause, err)
+	}
 
-func (s *orderService) CloseOrder(id string) error {
-	// TODO: Когда заказ закрывается через /orders/{id}/close, система считает, что заказ выполнен, и обновляет инвентарь, вычитая количество ингредиентов, необходимых для его выполнения.
-	// TODO: После успешного вычитания ингредиентов заказ считается закрытым( "status": "open", -> "status": "closed",), и он больше не будет доступен для изменений (Изменить Update, проверять статус closed or open).
-	// ? TODO: Закрытие также означает, что заказ включается в итоговую статистику для расчетов выручки и популярных позиций.
+	if sale != nil {
+		refund := models.TotalSales{
+			Subtotal:   -sale.Subtotal,
+			Discounts:  -sale.Discounts,
+			Tax:        -sale.Tax,
+			TotalSales: -sale.TotalSales,
+		}
+		if err := s.ReportRepository.RecordSale(refund); err != nil {
+			logger.LOGGER.PrintErrorMsg("Failed to restore total sales after failed order close: %v", err)
+			return fmt.Errorf("%w (total sales rollback failed: %v)", cause, err)
+		}
+	}
 
-	order, err := s.OrderRepository.GetOrderById(id)
+	return cause
+}
+
+// restockIngredients adds the ingredients of the order items back to the inventory, undoing ReduceIngredients.
+// Other changes saved since the deduction are kept.
+func (s *orderService) restockIngredients(orderItems []models.OrderItem) error {
+	menuMap, err := s.menuItemsByID()
 	if err != nil {
 		return err
 	}
 
-	if order.Status != "open" {
-		return ErrOrderClosed
+	return s.InventoryRepository.UpdateItems(func(inventoryItems []models.InventoryItem) ([]models.InventoryItem, error) {
+		inventoryMap := make(map[string]models.InventoryItem)
+		for _, item := range inventoryItems {
+			inventoryMap[item.IngredientID] = item
+		}
+
+		deducted, _, err := sumIngredients(orderItems, menuMap, inventoryMap)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range inventoryItems {
+			inventoryItems[i].Quantity += deducted[inventoryItems[i].IngredientID]
+		}
+		return inventoryItems, nil
+	})
+}
+
+// IsInventorySufficient reports whether the inventory can fulfill the order items.
+// It delegates to CheckInventory and returns ErrNotEnoughInventoryQuantity if any ingredient runs short.
+// The answer may be stale by the time the order is closed, ReduceIngredients checks again as it deducts.
+func (s *orderService) IsInventorySufficient(orderItems []models.OrderItem) (bool, error) {
+	shortages, err := s.CheckInventory(orderItems)
+	if err != nil {
+		return false, err
+	}
+
+	if len(shortages) > 0 {
+		return false, ErrNotEnoughInventoryQuantity
+	}
+
+	return true, nil
+}
+
+// CheckOrder reports whether the inventory can fulfill the order without changing any state.
+// Returns every ingredient that would run short instead of failing on the first one,
+// or the inventory as it would be after closing the order if nothing runs short.
+func (s *orderService) CheckOrder(o models.Order) (models.InventoryCheck, error) {
+	if err := ValidateOrderItems(o.Items); err != nil {
+		return models.InventoryCheck{}, err
 	}
 
-	err = s.ReduceIngredients(order.Items)
+	menuMap, err := s.menuItemsByID()
 	if err != nil {
-		return err
+		return models.InventoryCheck{}, err
+	}
+	if err := checkWholeQuantities(o.Items, menuMap); err != nil {
+		return models.InventoryCheck{}, err
 	}
 
-	totalOrderPrice, err := s.CalculateTotalSales()
+	shortages, err := s.CheckInventory(o.Items)
 	if err != nil {
-		return err
+		return models.InventoryCheck{}, err
+	}
+	if len(shortages) > 0 {
+		return models.InventoryCheck{Sufficient: false, Shortages: shortages}, nil
 	}
 
-	fmt.Println("TOTAL ORDER PRICE:", totalOrderPrice)
-	err = s.ReportRepository.UpdateTotalSales(totalOrderPrice)
+	projectedInventory, err := s.SimulateReduce(o.Items)
 	if err != nil {
-		return err
+		return models.InventoryCheck{}, err
 	}
 
-	order.Status = "closed"
+	return models.InventoryCheck{Sufficient: true, Shortages: shortages, ProjectedInventory: projectedInventory}, nil
+}
 
-	// TODO: Use report repo and add income to total_sales.json
+// CheckInventory accumulates all ingredients that would go negative if the order items were fulfilled.
+// Quantities reserved by open orders are taken into account.
+// The following errors may be returned:
+// - ErrOrderProductNotFound if an order item references a product that is not on the menu.
+// - ErrInventoryItemNotFound if a recipe references an ingredient that is not in the inventory.
+func (s *orderService) CheckInventory(orderItems []models.OrderItem) ([]models.Shortage, error) {
+	inventoryMap, err := s.unreservedInventory()
+	if err != nil {
+		return nil, err
+	}
 
-	err = s.OrderRepository.RewriteOrder(id, order)
+	menuMap, err := s.menuItemsByID()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return findShortages(orderItems, menuMap, inventoryMap)
 }
 
-// write status code 422
-func (s *orderService) IsInventorySufficient(orderItems []models.OrderItem) (bool, error) {
+// unreservedInventory returns the inventory items by their ID with the quantities reserved by open orders subtracted.
+func (s *orderService) unreservedInventory() (map[string]models.InventoryItem, error) {
 	inventoryMap := make(map[string]models.InventoryItem)
 	inventoryItems, err := s.InventoryRepository.GetAllItems()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	for _, item := range inventoryItems {
 		inventoryMap[item.IngredientID] = item
 	}
 
-	existingOrders, err := s.OrderRepository.GetAllOrders()
+	reserved, err := s.reservedQuantities("")
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	// Subtracting quantities reserved by open orders
+	for id, quantity := range reserved {
+		if inventoryItem, exists := inventoryMap[id]; exists {
+			inventoryItem.Quantity -= quantity
+			inventoryMap[id] = inventoryItem
+		}
+	}
+
+	return inventoryMap, nil
+}
+
+// RetrieveAvailableMenu returns the menu items that can be ordered right now: items marked available
+// whose stock not reserved by open orders covers a single unit. An item with sizes is listed
+// if its base recipe or any of its sizes can be made, with only the sizes that can be made.
+// Combos are checked with the recipes of their components.
+func (s *orderService) RetrieveAvailableMenu() ([]models.MenuItem, error) {
+	menuItems, err := s.MenuRepository.GetAllMenuItems()
+	if err != nil {
+		return nil, err
+	}
+	menuMap := indexMenuItems(menuItems)
+
+	inventoryMap, err := s.unreservedInventory()
+	if err != nil {
+		return nil, err
+	}
+
+	// Items that can not be resolved, e.g. with an unknown ingredient, can not be ordered either
+	canMake := func(productID, size string) bool {
+		shortages, err := findShortages([]models.OrderItem{{ProductID: productID, Size: size, Quantity: 1}}, menuMap, inventoryMap)
+		return err == nil && len(shortages) == 0
 	}
 
-	for _, existingOrder := range existingOrders {
-		if existingOrder.Status == "closed" {
+	availableItems := []models.MenuItem{}
+	for _, item := range menuItems {
+		if resolved, exists := menuMap[item.ID]; !exists || !resolved.Available {
 			continue
 		}
-		for _, existingOrderItem := range existingOrder.Items {
-			menuItem, err := s.MenuRepository.GetMenuItemById(existingOrderItem.ProductID)
-			if err != nil {
-				return false, err
-			}
 
-			for _, ingredient := range menuItem.Ingredients {
-				inventoryItem, exists := inventoryMap[ingredient.IngredientID]
-				if exists {
-					reservedQuantity := ingredient.Quantity * float64(existingOrderItem.Quantity)
-					inventoryItem.Quantity -= reservedQuantity
-					inventoryMap[ingredient.IngredientID] = inventoryItem
-				}
+		var sizes []models.MenuItemSize
+		for _, size := range item.Sizes {
+			if canMake(item.ID, size.Name) {
+				sizes = append(sizes, size)
 			}
 		}
+		if len(sizes) == 0 && !canMake(item.ID, "") {
+			continue
+		}
+
+		item.Sizes = sizes
+		availableItems = append(availableItems, item)
 	}
 
-	menuMap := make(map[string]models.MenuItem)
-	menuItems, err := s.MenuRepository.GetAllMenuItems()
+	return availableItems, nil
+}
+
+// findShortages returns every ingredient of the order items the inventory does not have enough of.
+func findShortages(orderItems []models.OrderItem, menuMap map[string]models.MenuItem, inventoryMap map[string]models.InventoryItem) ([]models.Shortage, error) {
+	required, ingredientIDs, err := sumIngredients(orderItems, menuMap, inventoryMap)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	for _, item := range menuItems {
-		menuMap[item.ID] = item
+
+	return compareRequired(required, ingredientIDs, inventoryMap), nil
+}
+
+// compareRequired returns every ingredient whose required quantity exceeds the quantity in the inventory.
+// It is the shortage rule shared by findShortages and deductIngredients.
+func compareRequired(required map[string]float64, ingredientIDs []string, inventoryMap map[string]models.InventoryItem) []models.Shortage {
+	shortages := []models.Shortage{}
+	for _, id := range ingredientIDs {
+		available := inventoryMap[id].Quantity
+		if required[id] > available {
+			shortages = append(shortages, models.Shortage{
+				IngredientID: id,
+				Required:     required[id],
+				Available:    available,
+				Missing:      required[id] - available,
+			})
+		}
+	}
+
+	return shortages
+}
+
+// orderItemRecipe returns the recipe of an order item: the snapshot saved when its order was closed,
+// or else the current recipe of the ordered size.
+// Returns ErrOrderProductNotFound or ErrOrderSizeNotFound if the current recipe can not be resolved.
+func orderItemRecipe(item models.OrderItem, menuMap map[string]models.MenuItem) ([]models.MenuItemIngredient, error) {
+	if item.RecipeSnapshot != nil {
+		return item.RecipeSnapshot, nil
+	}
+
+	menuItem, exists := menuMap[item.ProductID]
+	if !exists {
+		return nil, ErrOrderProductNotFound
+	}
+
+	_, recipe, err := menuItemVariant(menuItem, item.Size)
+	return recipe, err
+}
+
+// snapshotItems returns a copy of the order items, each with a snapshot of its current recipe
+// and its unit price pinned, so the reports of a closed order do not follow later menu changes.
+// Items whose recipe or price can not be resolved are copied without that snapshot.
+func snapshotItems(orderItems []models.OrderItem, menuMap map[string]models.MenuItem) []models.OrderItem {
+	snapshots := slices.Clone(orderItems)
+	for i, item := range snapshots {
+		if recipe, err := orderItemRecipe(item, menuMap); err == nil {
+			snapshots[i].RecipeSnapshot = slices.Clone(recipe)
+		}
+		if price, err := orderItemPrice(item, menuMap[item.ProductID]); err == nil {
+			snapshots[i].UnitPrice = price
+		}
 	}
+	return snapshots
+}
 
+// sumIngredients sums the quantities of every ingredient the order items need, in the inventory units.
+// Items with a recipe snapshot are summed with the snapshot instead of the current recipe.
+// The IDs of the ingredients are returned in the order of their first appearance.
+// The following errors may be returned:
+// - ErrOrderProductNotFound if an order item references a product that is not on the menu.
+// - ErrOrderSizeNotFound if an order item references a size the product does not have.
+// - ErrInventoryItemNotFound if a recipe references an ingredient that is not in the inventory.
+func sumIngredients(orderItems []models.OrderItem, menuMap map[string]models.MenuItem, inventoryMap map[string]models.InventoryItem) (map[string]float64, []string, error) {
+	required := make(map[string]float64)
+	ingredientIDs := []string{}
 	for _, orderItem := range orderItems {
-		menuItem, exists := menuMap[orderItem.ProductID]
-		if !exists {
-			return false, ErrOrderProductNotFound
+		recipe, err := orderItemRecipe(orderItem, menuMap)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		for _, ingredient := range menuItem.Ingredients {
+		for _, ingredient := range recipe {
 			inventoryItem, exists := inventoryMap[ingredient.IngredientID]
 			if !exists {
-				return false, ErrInventoryItemNotFound
+				return nil, nil, ErrInventoryItemNotFound
 			}
 
-			requiredQuantity := ingredient.Quantity * float64(orderItem.Quantity)
-			if requiredQuantity > inventoryItem.Quantity {
-				return false, ErrNotEnoughInventoryQuantity
+			quantity, err := ConvertQuantity(ingredient.Quantity, ingredient.Unit, inventoryItem.Unit)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if _, seen := required[ingredient.IngredientID]; !seen {
+				ingredientIDs = append(ingredientIDs, ingredient.IngredientID)
 			}
+			required[ingredient.IngredientID] += quantity * orderItem.Quantity
 		}
 	}
 
-	return true, nil
+	return required, ingredientIDs, nil
 }
 
-func (s *orderService) ReduceIngredients(orderItems []models.OrderItem) error {
-	inventoryMap := make(map[string]models.InventoryItem)
+// CalculateRequirements aggregates the ingredients needed to fulfill the given orders and order items,
+// and marks the ingredients the current stock is short of. Reservations of other open orders are not subtracted.
+// The following errors may be returned:
+// - ErrNotValidOrderItems if neither order IDs nor order items are given.
+// - ErrNoOrder if any of the orders is not found.
+// - Any error of ValidateOrderItems or sumIngredients.
+func (s *orderService) CalculateRequirements(r models.RequirementsRequest) (models.Requirements, error) {
+	if len(r.OrderIDs) == 0 && len(r.Items) == 0 {
+		return models.Requirements{}, ErrNotValidOrderItems
+	}
+
+	if len(r.Items) > 0 {
+		if err := ValidateOrderItems(r.Items); err != nil {
+			return models.Requirements{}, err
+		}
+	}
+
+	orderItems := slices.Clone(r.Items)
+	for _, id := range r.OrderIDs {
+		order, err := s.OrderRepository.GetOrderById(id)
+		if err != nil {
+			if err.Error() == "order not found" {
+				return models.Requirements{}, ErrNoOrder
+			}
+			return models.Requirements{}, err
+		}
+		orderItems = append(orderItems, order.Items...)
+	}
+
 	inventoryItems, err := s.InventoryRepository.GetAllItems()
 	if err != nil {
-		return err
+		return models.Requirements{}, err
 	}
-
+	inventoryMap := make(map[string]models.InventoryItem)
 	for _, item := range inventoryItems {
 		inventoryMap[item.IngredientID] = item
 	}
 
-	menuMap := make(map[string]models.MenuItem)
-	menuItems, err := s.MenuRepository.GetAllMenuItems()
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return models.Requirements{}, err
+	}
+	if err := checkWholeQuantities(r.Items, menuMap); err != nil {
+		return models.Requirements{}, err
+	}
+
+	required, ingredientIDs, err := sumIngredients(orderItems, menuMap, inventoryMap)
+	if err != nil {
+		return models.Requirements{}, err
+	}
+
+	requirements := models.Requirements{Sufficient: true, Ingredients: []models.IngredientRequirement{}}
+	for _, id := range ingredientIDs {
+		inventoryItem := inventoryMap[id]
+		requirement := models.IngredientRequirement{
+			IngredientID: id,
+			Unit:         inventoryItem.Unit,
+			Required:     required[id],
+			Available:    inventoryItem.Quantity,
+		}
+		if requirement.Required > requirement.Available {
+			requirement.Short = true
+			requirement.Missing = requirement.Required - requirement.Available
+			requirements.Sufficient = false
+		}
+		requirements.Ingredients = append(requirements.Ingredients, requirement)
+	}
+
+	return requirements, nil
+}
+
+// ReduceIngredients deducts the ingredients required by the items of the order with the given ID from the inventory.
+// The sufficiency check and the deduction are a single pass under the inventory repository lock:
+// the stock on hand at that moment, less the reservations of the other open orders, decides. Earlier checks
+// such as IsInventorySufficient on create are not relied upon, so nothing written in between can be overdrawn.
+// The reservation of the order itself is not subtracted, it holds the stock the order is deducting.
+// Items are updated in place in the loaded inventory, so items untouched by the order
+// are saved back exactly as they were read.
+// Returns ErrNotEnoughInventoryQuantity, with nothing deducted, if an ingredient runs short.
+func (s *orderService) ReduceIngredients(orderID string, orderItems []models.OrderItem) error {
+	menuMap, err := s.menuItemsByID()
 	if err != nil {
 		return err
 	}
-	for _, item := range menuItems {
-		menuMap[item.ID] = item
+
+	// The check and the deduction happen under the repository lock, two orders closed at the same time
+	// can not both deduct from the same starting quantity
+	var updatedItems []models.InventoryItem
+	var affectedIDs []string
+	err = s.InventoryRepository.UpdateItems(func(inventoryItems []models.InventoryItem) ([]models.InventoryItem, error) {
+		reserved, err := s.reservedQuantities(orderID)
+		if err != nil {
+			return nil, err
+		}
+
+		updatedItems, affectedIDs, err = deductIngredients(inventoryItems, orderItems, menuMap, reserved)
+		return updatedItems, err
+	})
+	if err != nil {
+		return err
 	}
 
-	for _, orderItem := range orderItems {
-		menuItem, exists := menuMap[orderItem.ProductID]
-		if !exists {
-			return ErrOrderProductNotFound
+	// Warning once per ingredient that reached its reorder level
+	for _, item := range updatedItems {
+		index := slices.Index(affectedIDs, item.IngredientID)
+		if index < 0 {
+			continue
 		}
+		affectedIDs = slices.Delete(affectedIDs, index, index+1)
 
-		for _, ingredient := range menuItem.Ingredients {
-			inventoryItem, exists := inventoryMap[ingredient.IngredientID]
-			if !exists {
-				return ErrInventoryItemNotFound
-			}
+		if item.ReorderLevel > 0 && item.Quantity <= item.ReorderLevel {
+			logger.LOGGER.PrintWarnMsg("Inventory item %s is low on stock: %g %s left (reorder level %g)", item.IngredientID, item.Quantity, item.Unit, item.ReorderLevel)
+		}
+	}
 
-			requiredQuantity := ingredient.Quantity * float64(orderItem.Quantity)
-			if requiredQuantity > inventoryItem.Quantity {
-				return ErrNotEnoughInventoryQuantity
-			}
+	return nil
+}
+
+// SimulateReduce is a dry run of ReduceIngredients. It returns the inventory left available after deducting
+// the ingredients of the order items, with the quantities reserved by open orders subtracted as well,
+// the stored inventory is left unchanged.
+// The same errors as ReduceIngredients may be returned.
+func (s *orderService) SimulateReduce(orderItems []models.OrderItem) ([]models.InventoryItem, error) {
+	reserved, err := s.reservedQuantities("")
+	if err != nil {
+		return nil, err
+	}
+
+	projectedItems, _, err := s.projectReduction(orderItems, reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	// The reserved stock stays in the saved inventory, but it is promised to the open orders
+	for i := range projectedItems {
+		projectedItems[i].Quantity -= reserved[projectedItems[i].IngredientID]
+	}
+	return projectedItems, nil
+}
+
+// projectReduction computes the inventory after deducting the ingredients of the order items, sorted by ID,
+// together with the IDs of the ingredients used. The reserved quantities can not be deducted. Nothing is saved.
+func (s *orderService) projectReduction(orderItems []models.OrderItem, reserved map[string]float64) ([]models.InventoryItem, []string, error) {
+	inventoryItems, err := s.InventoryRepository.GetAllItems()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return deductIngredients(inventoryItems, orderItems, menuMap, reserved)
+}
 
-			inventoryItem.Quantity -= requiredQuantity
-			inventoryMap[ingredient.IngredientID] = inventoryItem
+// deductIngredients subtracts the ingredients of the order items from the inventory items and returns them
+// sorted by ID, together with the IDs of the ingredients used. The reserved quantities are held for other
+// orders: they stay in the inventory but can not be deducted. The recipes are summed and the shortages
+// found with the same rules as findShortages, so an order is short of stock here exactly when
+// CheckInventory reports a shortage on the same inventory and reservations.
+// The inventory items are changed in place only when nothing runs short.
+// Returns ErrNotEnoughInventoryQuantity if an ingredient would go negative, or any error of sumIngredients.
+func deductIngredients(inventoryItems []models.InventoryItem, orderItems []models.OrderItem, menuMap map[string]models.MenuItem, reserved map[string]float64) ([]models.InventoryItem, []string, error) {
+	indexByID := make(map[string]int)
+	inventoryMap := make(map[string]models.InventoryItem)
+	for i, item := range inventoryItems {
+		if _, exists := indexByID[item.IngredientID]; !exists {
+			indexByID[item.IngredientID] = i
+			// The reserved quantities are not available, like in unreservedInventory
+			item.Quantity -= reserved[item.IngredientID]
+			inventoryMap[item.IngredientID] = item
 		}
 	}
 
-	var updatedItems []models.InventoryItem
-	for _, item := range inventoryMap {
-		updatedItems = append(updatedItems, item)
+	required, ingredientIDs, err := sumIngredients(orderItems, menuMap, inventoryMap)
+	if err != nil {
+		return nil, nil, err
+	}
+	if shortages := compareRequired(required, ingredientIDs, inventoryMap); len(shortages) > 0 {
+		return nil, nil, ErrNotEnoughInventoryQuantity
 	}
 
-	if err := s.InventoryRepository.SaveItems(updatedItems); err != nil {
-		return err
+	for _, id := range ingredientIDs {
+		inventoryItems[indexByID[id]].Quantity -= required[id]
 	}
 
-	return nil
+	// Sorting keeps the saved file stable between writes
+	sort.SliceStable(inventoryItems, func(i, j int) bool {
+		return inventoryItems[i].IngredientID < inventoryItems[j].IngredientID
+	})
+
+	return inventoryItems, ingredientIDs, nil
 }
 
 func (s *orderService) CalculateTotalSales() (float64, error) {
@@ -337,17 +1538,54 @@ func (s *orderService) CalculateTotalSales() (float64, error) {
 		return 0.0, err
 	}
 
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return 0.0, err
+	}
+
 	for _, order := range orders {
+		orderSubtotal := 0.0
 		for _, orderItem := range order.Items {
-			menuItem, err := s.MenuRepository.GetMenuItemById(orderItem.ProductID)
+			menuItem, exists := menuMap[orderItem.ProductID]
+			if !exists {
+				return 0.0, ErrOrderProductNotFound
+			}
+
+			price, err := orderItemPrice(orderItem, menuItem)
 			if err != nil {
 				return 0.0, err
 			}
 
-			itemTotal := menuItem.Price * float64(orderItem.Quantity)
-			totalSales += itemTotal
+			orderSubtotal += price * orderItem.Quantity
 		}
+		totalSales += newOrderPricing(orderSubtotal, order.Discount, orderTaxRate(order, s.taxRate)).total
 	}
 
 	return totalSales, nil
 }
+
+// ExportOrders returns a flat summary of every order with its total price computed from the current menu prices.
+func (s *orderService) ExportOrders() ([]models.OrderExport, error) {
+	orders, err := s.OrderRepository.GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return nil, err
+	}
+
+	exports := []models.OrderExport{}
+	for _, order := range orders {
+		exports = append(exports, models.OrderExport{
+			ID:           order.ID,
+			CustomerName: order.CustomerName,
+			Status:       string(order.Status.Normalize()),
+			CreatedAt:    order.CreatedAt,
+			TotalPrice:   priceOrder(order, menuMap, s.taxRate).total,
+		})
+	}
+
+	return exports, nil
+}
@@ -0,0 +1,1090 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// testInventory and testMenu are a small cafe: lattes and cappuccinos made from milk and espresso.
+func testInventory() []models.InventoryItem {
+	return []models.InventoryItem{
+		{IngredientID: "espresso", Name: "Espresso", Quantity: 100, Unit: "shots"},
+		{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml"},
+	}
+}
+
+func testMenu() []models.MenuItem {
+	return []models.MenuItem{
+		{
+			ID: "latte", Name: "Latte", Price: 3.5, Available: true,
+			Ingredients: []models.MenuItemIngredient{{IngredientID: "milk", Quantity: 200}, {IngredientID: "espresso", Quantity: 1}},
+		},
+		{
+			ID: "cappuccino", Name: "Cappuccino", Price: 4, Available: true,
+			Ingredients: []models.MenuItemIngredient{{IngredientID: "milk", Quantity: 150}, {IngredientID: "espresso", Quantity: 2}},
+		},
+	}
+}
+
+func newTestOrderService(taxRate float64, orders ...models.Order) *orderService {
+	return NewOrderService(
+		dal.NewInMemoryOrderRepository(orders...),
+		dal.NewInMemoryMenuRepository(testMenu()...),
+		dal.NewInMemoryInventoryRepository(testInventory()...),
+		dal.NewInMemoryReportRepository(0),
+		dal.NewInMemoryOrderAuditRepository(),
+		dal.NewInMemoryReservationRepository(),
+		taxRate,
+	)
+}
+
+// retrieveOrder returns the order as GET /orders/{id} reports it.
+func retrieveOrder(t *testing.T, s *orderService, id string) models.Order {
+	t.Helper()
+
+	data, err := s.RetrieveOrder(id)
+	if err != nil {
+		t.Fatalf("RetrieveOrder(%q) error = %v", id, err)
+	}
+	var order models.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestClosedOrderKeepsTaxRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		closeRate float64
+		laterRate float64
+		wantTax   float64
+	}{
+		{name: "rate raised after close", closeRate: 10, laterRate: 20, wantTax: 0.7},
+		{name: "rate introduced after close", closeRate: 0, laterRate: 20, wantTax: 0},
+		{name: "rate unchanged", closeRate: 5, laterRate: 5, wantTax: 0.35},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(tt.closeRate)
+			order, err := s.AddOrder(models.Order{CustomerName: "Alice", Items: []models.OrderItem{{ProductID: "latte", Quantity: 2}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CloseOrder(order.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			s.taxRate = tt.laterRate
+			closed := retrieveOrder(t, s, order.ID)
+			if closed.TaxRate == nil || *closed.TaxRate != tt.closeRate {
+				t.Errorf("tax rate = %v, want %g", closed.TaxRate, tt.closeRate)
+			}
+			if closed.Tax != tt.wantTax || closed.TotalPrice != 7+tt.wantTax {
+				t.Errorf("tax = %g and total = %g, want %g and %g", closed.Tax, closed.TotalPrice, tt.wantTax, 7+tt.wantTax)
+			}
+		})
+	}
+}
+
+func TestLegacyClosedOrderUsesCurrentTaxRate(t *testing.T) {
+	s := newTestOrderService(10, models.Order{
+		ID: "orders1", Status: models.StatusClosed, CreatedAt: "2026-10-14T08:00:00Z",
+		Items: []models.OrderItem{{ProductID: "latte", Quantity: 2}},
+	})
+
+	if order := retrieveOrder(t, s, "orders1"); order.Tax != 0.7 {
+		t.Errorf("tax = %g, want %g", order.Tax, 0.7)
+	}
+}
+
+func TestClientTaxRateIsIgnored(t *testing.T) {
+	s := newTestOrderService(10)
+	rate := 0.0
+
+	order, err := s.AddOrder(models.Order{CustomerName: "Alice", Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}, TaxRate: &rate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.TaxRate != nil {
+		t.Errorf("created order has tax rate %g, want none", *order.TaxRate)
+	}
+}
+
+func TestCalculateTotalSales(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []models.OrderItem
+		want    float64
+		wantErr error
+	}{
+		{name: "menu prices", items: []models.OrderItem{{ProductID: "latte", Quantity: 2}, {ProductID: "cappuccino", Quantity: 1}}, want: 11},
+		{name: "unknown product", items: []models.OrderItem{{ProductID: "mocha", Quantity: 1}}, wantErr: ErrOrderProductNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0, models.Order{ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z", Items: tt.items})
+
+			got, err := s.CalculateTotalSales()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CalculateTotalSales() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CalculateTotalSales() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeductIngredientsMatchesFindShortages(t *testing.T) {
+	menuMap := indexMenuItems(testMenu())
+
+	tests := []struct {
+		name      string
+		items     []models.OrderItem
+		milk      float64
+		reserved  map[string]float64
+		wantShort bool
+	}{
+		{name: "enough", items: []models.OrderItem{{ProductID: "latte", Quantity: 2}}, milk: 1000},
+		{name: "exactly enough", items: []models.OrderItem{{ProductID: "latte", Quantity: 5}}, milk: 1000},
+		{name: "short", items: []models.OrderItem{{ProductID: "latte", Quantity: 6}}, milk: 1000, wantShort: true},
+		{name: "short across items", items: []models.OrderItem{{ProductID: "latte", Quantity: 3}, {ProductID: "cappuccino", Quantity: 3}}, milk: 1000, wantShort: true},
+		{name: "reserved by another order", items: []models.OrderItem{{ProductID: "latte", Quantity: 2}}, milk: 1000, reserved: map[string]float64{"milk": 700}, wantShort: true},
+		{name: "enough besides the reservation", items: []models.OrderItem{{ProductID: "latte", Quantity: 2}}, milk: 1000, reserved: map[string]float64{"milk": 600}},
+		{name: "out of stock", items: []models.OrderItem{{ProductID: "cappuccino", Quantity: 1}}, milk: 0, wantShort: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventory := testInventory()
+			inventory[1].Quantity = tt.milk
+
+			available := make(map[string]models.InventoryItem)
+			for _, item := range inventory {
+				item.Quantity -= tt.reserved[item.IngredientID]
+				available[item.IngredientID] = item
+			}
+			shortages, err := findShortages(tt.items, menuMap, available)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deducted, _, err := deductIngredients(inventory, tt.items, menuMap, tt.reserved)
+			if (len(shortages) > 0) != tt.wantShort {
+				t.Errorf("findShortages() = %+v, want short %v", shortages, tt.wantShort)
+			}
+			if errors.Is(err, ErrNotEnoughInventoryQuantity) != tt.wantShort {
+				t.Errorf("deductIngredients() error = %v, want short %v", err, tt.wantShort)
+			}
+			for _, item := range deducted {
+				if item.Quantity < tt.reserved[item.IngredientID] {
+					t.Errorf("%s deducted to %g, below the reserved %g", item.IngredientID, item.Quantity, tt.reserved[item.IngredientID])
+				}
+			}
+		})
+	}
+}
+
+func TestCloseOrderConcurrent(t *testing.T) {
+	// 1000 ml of milk makes 5 lattes, only 5 of the orders can be closed
+	const orders = 20
+	var stored []models.Order
+	for i := 0; i < orders; i++ {
+		stored = append(stored, models.Order{
+			ID: fmt.Sprintf("orders%d", i), CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z",
+			Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}},
+		})
+	}
+	s := newTestOrderService(0, stored...)
+
+	var wg sync.WaitGroup
+	var closed atomic.Int32
+	for _, order := range stored {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			err := s.CloseOrder(id)
+			switch {
+			case err == nil:
+				closed.Add(1)
+			case !errors.Is(err, ErrNotEnoughInventoryQuantity):
+				t.Errorf("CloseOrder(%s) error = %v", id, err)
+			}
+		}(order.ID)
+	}
+	wg.Wait()
+
+	if got := closed.Load(); got != 5 {
+		t.Errorf("%d orders closed, want 5", got)
+	}
+	milk, err := s.InventoryRepository.GetItemById("milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if milk.Quantity != 0 {
+		t.Errorf("milk left = %g, want 0", milk.Quantity)
+	}
+}
+
+func TestFractionalQuantities(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     []models.OrderItem
+		wantField string
+	}{
+		{name: "whole quantity", items: []models.OrderItem{{ProductID: "latte", Quantity: 2}}},
+		{name: "fractional quantity sold by weight", items: []models.OrderItem{{ProductID: "beans", Quantity: 0.25}}},
+		{name: "fractional quantity not sold by weight", items: []models.OrderItem{{ProductID: "latte", Quantity: 1.5}}, wantField: "items[0].quantity"},
+		{name: "second item fractional", items: []models.OrderItem{{ProductID: "beans", Quantity: 1.5}, {ProductID: "cappuccino", Quantity: 0.5}}, wantField: "items[1].quantity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0)
+			beans := models.MenuItem{
+				ID: "beans", Name: "Espresso beans", Price: 20, Available: true, SoldByWeight: true,
+				Ingredients: []models.MenuItemIngredient{{IngredientID: "espresso", Quantity: 10}},
+			}
+			if _, err := s.MenuRepository.AddMenuItem(beans); err != nil {
+				t.Fatalf("AddMenuItem() error = %v", err)
+			}
+
+			_, err := s.AddOrder(models.Order{CustomerName: "Alice", Items: tt.items})
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("AddOrder() error = %v", err)
+				}
+			} else {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 || validationErr.Errors[0].Field != tt.wantField {
+					t.Fatalf("AddOrder() error = %v, want a validation error on %s", err, tt.wantField)
+				}
+			}
+
+			var wantErr error
+			if tt.wantField != "" {
+				wantErr = ErrFractionalQuantity
+			}
+			if _, err := s.CheckOrder(models.Order{Items: tt.items}); !errors.Is(err, wantErr) {
+				t.Fatalf("CheckOrder() error = %v, want %v", err, wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckOrderProjectsReservations(t *testing.T) {
+	tests := []struct {
+		name         string
+		openOrders   [][]models.OrderItem
+		items        []models.OrderItem
+		wantMilk     float64
+		wantEspresso float64
+	}{
+		{name: "no open orders", items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}, wantMilk: 800, wantEspresso: 99},
+		{
+			name:       "open order reserved",
+			openOrders: [][]models.OrderItem{{{ProductID: "cappuccino", Quantity: 2}}},
+			items:      []models.OrderItem{{ProductID: "latte", Quantity: 1}},
+			wantMilk:   500, wantEspresso: 95,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0)
+			for _, items := range tt.openOrders {
+				if _, err := s.AddOrder(models.Order{CustomerName: "Alice", Items: items}); err != nil {
+					t.Fatalf("AddOrder() error = %v", err)
+				}
+			}
+
+			check, err := s.CheckOrder(models.Order{Items: tt.items})
+			if err != nil {
+				t.Fatalf("CheckOrder() error = %v", err)
+			}
+
+			projected := make(map[string]float64)
+			for _, item := range check.ProjectedInventory {
+				projected[item.IngredientID] = item.Quantity
+			}
+			if projected["milk"] != tt.wantMilk || projected["espresso"] != tt.wantEspresso {
+				t.Errorf("projected milk = %g, espresso = %g, want %g and %g", projected["milk"], projected["espresso"], tt.wantMilk, tt.wantEspresso)
+			}
+
+			// The check is a dry run, the stored inventory keeps the reserved stock
+			stored, err := s.InventoryRepository.GetAllItems()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(stored, testInventory()) {
+				t.Errorf("stored inventory = %+v, want %+v", stored, testInventory())
+			}
+		})
+	}
+}
+
+func TestValidateOrderItems(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []models.OrderItem
+		wantErr error
+	}{
+		{name: "valid", items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}},
+		{name: "no items", wantErr: ErrNotValidOrderItems},
+		{name: "empty product ID", items: []models.OrderItem{{Quantity: 1}}, wantErr: ErrNotValidOrderProductID},
+		{name: "product ID with spaces", items: []models.OrderItem{{ProductID: "flat white", Quantity: 1}}, wantErr: ErrNotValidOrderProductID},
+		{name: "zero quantity", items: []models.OrderItem{{ProductID: "latte"}}, wantErr: ErrNotValidQuantity},
+		{name: "repeated item", items: []models.OrderItem{{ProductID: "latte", Quantity: 1}, {ProductID: "latte", Quantity: 2}}, wantErr: ErrDuplicateOrderItems},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateOrderItems(tt.items); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateOrderItems() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCloseOrderTwice(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   models.OrderStatus
+		wantErrs []error
+		wantMilk float64
+	}{
+		{name: "open order", status: models.StatusOpen, wantErrs: []error{nil, ErrOrderAlreadyClosed}, wantMilk: 800},
+		{name: "closed order", status: models.StatusClosed, wantErrs: []error{ErrOrderAlreadyClosed, ErrOrderAlreadyClosed}, wantMilk: 1000},
+		{name: "mixed-case closed order", status: "Closed", wantErrs: []error{ErrOrderAlreadyClosed}, wantMilk: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0, models.Order{
+				ID: "orders1", CustomerName: "Alice", Status: tt.status, CreatedAt: "2024-01-02T10:00:00Z",
+				Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}},
+			})
+
+			for i, wantErr := range tt.wantErrs {
+				if err := s.CloseOrder("orders1"); !errors.Is(err, wantErr) {
+					t.Fatalf("close %d: CloseOrder() error = %v, want %v", i+1, err, wantErr)
+				}
+			}
+
+			milk, err := s.InventoryRepository.GetItemById("milk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if milk.Quantity != tt.wantMilk {
+				t.Errorf("milk left = %g, want %g", milk.Quantity, tt.wantMilk)
+			}
+		})
+	}
+}
+
+func TestCloseOrderTwiceConcurrent(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(s *orderService, id string) error
+	}{
+		{name: "close", close: func(s *orderService, id string) error { return s.CloseOrder(id) }},
+		{name: "partial close", close: func(s *orderService, id string) error {
+			_, err := s.ClosePartialOrder(id)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0, models.Order{
+				ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z",
+				Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}},
+			})
+			// Slow inventory writes keep the closes overlapping
+			inventory := dal.NewInventoryRepositoryWithStorage(&hookedStorage{
+				Storage:     dal.NewInMemoryStorage(),
+				beforeWrite: func() error { time.Sleep(5 * time.Millisecond); return nil },
+			}, "inventory")
+			if err := inventory.SaveItems(testInventory()); err != nil {
+				t.Fatal(err)
+			}
+			s.InventoryRepository = inventory
+
+			const attempts = 10
+			var wg sync.WaitGroup
+			var closed atomic.Int32
+			for range attempts {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := tt.close(s, "orders1")
+					switch {
+					case err == nil:
+						closed.Add(1)
+					case !errors.Is(err, ErrOrderAlreadyClosed):
+						t.Errorf("close error = %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := closed.Load(); got != 1 {
+				t.Errorf("order closed %d times, want once", got)
+			}
+			milk, err := s.InventoryRepository.GetItemById("milk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if milk.Quantity != 800 {
+				t.Errorf("milk left = %g, want 800", milk.Quantity)
+			}
+			sales, err := s.ReportRepository.GetTotalSales()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sales.TotalSales != 3.5 {
+				t.Errorf("total sales = %g, want the single sale of 3.5", sales.TotalSales)
+			}
+		})
+	}
+}
+
+func TestValidateCreatedAt(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt string
+		wantErr   error
+	}{
+		{name: "past timestamp", createdAt: "2024-01-02T10:00:00Z"},
+		{name: "timestamp with offset", createdAt: "2024-01-02T10:00:00+05:00"},
+		{name: "empty", createdAt: "", wantErr: ErrMalformedCreatedAt},
+		{name: "date only", createdAt: "2024-01-02", wantErr: ErrMalformedCreatedAt},
+		{name: "not a timestamp", createdAt: "yesterday", wantErr: ErrMalformedCreatedAt},
+		{name: "future timestamp", createdAt: time.Now().Add(time.Hour).Format(time.RFC3339), wantErr: ErrFutureCreatedAt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateCreatedAt(tt.createdAt); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateCreatedAt(%q) error = %v, want %v", tt.createdAt, err, tt.wantErr)
+			}
+
+			// A stored order with the timestamp can only be updated if it is valid
+			s := newTestOrderService(0, models.Order{
+				ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: tt.createdAt,
+				Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}},
+			})
+			update := models.Order{CustomerName: "Bob", Items: []models.OrderItem{{ProductID: "latte", Quantity: 2}}}
+			if err := s.UpdateOrder("orders1", update); !errors.Is(err, tt.wantErr) {
+				t.Errorf("UpdateOrder() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReduceIngredientsSavesInIDOrder(t *testing.T) {
+	sugar := models.InventoryItem{IngredientID: "sugar", Name: "Sugar", Quantity: 500, Unit: "g"}
+	tests := []struct {
+		name  string
+		items []models.InventoryItem
+	}{
+		{name: "sorted", items: append(testInventory(), sugar)},
+		{name: "reversed", items: []models.InventoryItem{sugar, testInventory()[1], testInventory()[0]}},
+		{name: "shuffled", items: []models.InventoryItem{testInventory()[1], sugar, testInventory()[0]}},
+	}
+
+	var want []byte
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := dal.NewInMemoryStorage()
+			inventory := dal.NewInventoryRepositoryWithStorage(storage, "inventory.json")
+			if err := inventory.SaveItems(tt.items); err != nil {
+				t.Fatal(err)
+			}
+			s := newTestOrderService(0)
+			s.InventoryRepository = inventory
+
+			if err := s.ReduceIngredients("orders1", []models.OrderItem{{ProductID: "latte", Quantity: 1}}); err != nil {
+				t.Fatalf("ReduceIngredients() error = %v", err)
+			}
+
+			saved, err := storage.Read("inventory.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want == nil {
+				want = saved
+			} else if !bytes.Equal(saved, want) {
+				t.Errorf("saved inventory = %s, want %s", saved, want)
+			}
+		})
+	}
+}
+
+func TestCalculateRequirements(t *testing.T) {
+	stored := []models.Order{
+		{ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "latte", Quantity: 2}}},
+		{ID: "orders2", CustomerName: "Bob", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "cappuccino", Quantity: 4}}},
+	}
+
+	tests := []struct {
+		name           string
+		request        models.RequirementsRequest
+		wantEspresso   float64
+		wantMilk       float64
+		wantSufficient bool
+		wantErr        error
+	}{
+		{
+			name:         "overlapping orders",
+			request:      models.RequirementsRequest{OrderIDs: []string{"orders1", "orders2"}},
+			wantEspresso: 10, wantMilk: 1000, wantSufficient: true,
+		},
+		{
+			name:         "orders and items",
+			request:      models.RequirementsRequest{OrderIDs: []string{"orders1", "orders2"}, Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}},
+			wantEspresso: 11, wantMilk: 1200, wantSufficient: false,
+		},
+		{name: "nothing requested", wantErr: ErrNotValidOrderItems},
+		{name: "unknown order", request: models.RequirementsRequest{OrderIDs: []string{"orders9"}}, wantErr: ErrNoOrder},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0, stored...)
+
+			requirements, err := s.CalculateRequirements(tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CalculateRequirements() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			required := make(map[string]models.IngredientRequirement)
+			for _, ingredient := range requirements.Ingredients {
+				required[ingredient.IngredientID] = ingredient
+			}
+			if required["espresso"].Required != tt.wantEspresso || required["milk"].Required != tt.wantMilk {
+				t.Errorf("required espresso = %g, milk = %g, want %g and %g", required["espresso"].Required, required["milk"].Required, tt.wantEspresso, tt.wantMilk)
+			}
+			if requirements.Sufficient != tt.wantSufficient || required["milk"].Short == tt.wantSufficient {
+				t.Errorf("sufficient = %v, milk short = %v, want sufficient %v", requirements.Sufficient, required["milk"].Short, tt.wantSufficient)
+			}
+		})
+	}
+}
+
+func TestPatchOrder(t *testing.T) {
+	name, empty, id := "Bob", "", "orders2"
+	status := models.StatusClosed
+
+	tests := []struct {
+		name     string
+		status   models.OrderStatus
+		patch    models.OrderPatch
+		wantName string
+		wantErr  error
+	}{
+		{name: "customer name", status: models.StatusOpen, patch: models.OrderPatch{CustomerName: &name}, wantName: "Bob"},
+		{name: "empty customer name", status: models.StatusOpen, patch: models.OrderPatch{CustomerName: &empty}, wantErr: ErrNotValidOrderCustomerName},
+		{name: "nothing to change", status: models.StatusOpen, wantErr: ErrEmptyOrderPatch},
+		{name: "order ID", status: models.StatusOpen, patch: models.OrderPatch{CustomerName: &name, ID: &id}, wantErr: ErrNotValidOrderIDField},
+		{name: "status", status: models.StatusOpen, patch: models.OrderPatch{CustomerName: &name, Status: &status}, wantErr: ErrNotValidStatusField},
+		{name: "created_at", status: models.StatusOpen, patch: models.OrderPatch{CustomerName: &name, CreatedAt: &empty}, wantErr: ErrNotValidCreatedAt},
+		{name: "closed order", status: models.StatusClosed, patch: models.OrderPatch{CustomerName: &name}, wantErr: ErrOrderClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := []models.OrderItem{{ProductID: "latte", Quantity: 2}}
+			s := newTestOrderService(0, models.Order{ID: "orders1", CustomerName: "Alice", Status: tt.status, CreatedAt: "2024-01-02T10:00:00Z", Items: items})
+
+			_, err := s.PatchOrder("orders1", tt.patch)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PatchOrder() error = %v, want %v", err, tt.wantErr)
+			}
+
+			order, err := s.OrderRepository.GetOrderById("orders1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantName := "Alice"
+			if tt.wantErr == nil {
+				wantName = tt.wantName
+			}
+			if order.CustomerName != wantName || len(order.Items) != 1 || order.Items[0].Quantity != 2 {
+				t.Errorf("stored order = %+v, want customer %s with the items unchanged", order, wantName)
+			}
+		})
+	}
+}
+
+func TestStoredStatusIsCanonical(t *testing.T) {
+	tests := []struct {
+		name       string
+		close      bool
+		wantStatus models.OrderStatus
+	}{
+		{name: "created order", wantStatus: models.StatusOpen},
+		{name: "closed order", close: true, wantStatus: models.StatusClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0)
+			order, err := s.AddOrder(models.Order{CustomerName: "Alice", Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.close {
+				if err := s.CloseOrder(order.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stored, err := s.OrderRepository.GetOrderById(order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.wantStatus {
+				t.Errorf("stored status = %q, want %q", stored.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAddOrderUnknownProducts(t *testing.T) {
+	tests := []struct {
+		name       string
+		items      []models.OrderItem
+		wantFields []string
+	}{
+		{name: "known product", items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}},
+		{name: "unknown product", items: []models.OrderItem{{ProductID: "mocha", Quantity: 1}}, wantFields: []string{"items[0].product_id"}},
+		{
+			name:       "every unknown product listed",
+			items:      []models.OrderItem{{ProductID: "mocha", Quantity: 1}, {ProductID: "latte", Quantity: 1}, {ProductID: "chai", Quantity: 1}},
+			wantFields: []string{"items[0].product_id", "items[2].product_id"},
+		},
+		{
+			// The products are checked before any inventory math, an unknown product is not reported as a shortage
+			name:       "unknown product in a large order",
+			items:      []models.OrderItem{{ProductID: "latte", Quantity: 100}, {ProductID: "mocha", Quantity: 1}},
+			wantFields: []string{"items[1].product_id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0)
+
+			_, err := s.AddOrder(models.Order{CustomerName: "Alice", Items: tt.items})
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Fatalf("AddOrder() error = %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("AddOrder() error = %v, want a validation error", err)
+			}
+			fields := []string{}
+			for _, fieldErr := range validationErr.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("invalid fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestRetrieveOrderQueue(t *testing.T) {
+	order := func(id string, priority int, createdAt string, status models.OrderStatus) models.Order {
+		return models.Order{
+			ID: id, CustomerName: "Alice", Status: status, CreatedAt: createdAt, Priority: priority,
+			Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		orders []models.Order
+		want   []string
+	}{
+		{
+			name: "same priority by creation time",
+			orders: []models.Order{
+				order("orders1", 0, "2024-01-02T10:05:00Z", models.StatusOpen),
+				order("orders2", 0, "2024-01-02T10:00:00Z", models.StatusOpen),
+			},
+			want: []string{"orders2", "orders1"},
+		},
+		{
+			name: "mixed priorities",
+			orders: []models.Order{
+				order("orders1", 1, "2024-01-02T10:00:00Z", models.StatusOpen),
+				order("orders2", 5, "2024-01-02T10:10:00Z", models.StatusOpen),
+				order("orders3", 0, "2024-01-02T09:00:00Z", models.StatusOpen),
+				order("orders4", 5, "2024-01-02T10:05:00Z", models.StatusOpen),
+			},
+			want: []string{"orders4", "orders2", "orders1", "orders3"},
+		},
+		{
+			name: "closed orders left out",
+			orders: []models.Order{
+				order("orders1", 5, "2024-01-02T10:00:00Z", models.StatusClosed),
+				order("orders2", 0, "2024-01-02T10:00:00Z", models.StatusOpen),
+			},
+			want: []string{"orders2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0, tt.orders...)
+
+			queue, err := s.RetrieveOrderQueue()
+			if err != nil {
+				t.Fatalf("RetrieveOrderQueue() error = %v", err)
+			}
+			ids := []string{}
+			for _, order := range queue {
+				ids = append(ids, order.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("queue = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderPriorityRange(t *testing.T) {
+	tests := []struct {
+		priority int
+		wantErr  bool
+	}{
+		{priority: 0},
+		{priority: 5},
+		{priority: -1, wantErr: true},
+		{priority: 6, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.priority), func(t *testing.T) {
+			s := newTestOrderService(0)
+
+			_, err := s.AddOrder(models.Order{CustomerName: "Alice", Priority: tt.priority, Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}})
+			var validationErr *ValidationError
+			if gotErr := errors.As(err, &validationErr) && validationErr.Errors[0].Field == "priority"; gotErr != tt.wantErr {
+				t.Errorf("AddOrder() with priority %d error = %v, want a priority error %v", tt.priority, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetrieveOrdersUsingIngredient(t *testing.T) {
+	order := func(id, productID string, status models.OrderStatus) models.Order {
+		return models.Order{ID: id, CustomerName: "Alice", Status: status, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: productID, Quantity: 1}}}
+	}
+	stored := []models.Order{
+		order("orders1", "latte", models.StatusOpen),
+		order("orders2", "shot", models.StatusOpen),
+		order("orders3", "latte", models.StatusClosed),
+		order("orders4", "mocha", models.StatusOpen),
+	}
+
+	tests := []struct {
+		ingredientID string
+		want         []string
+		wantErr      error
+	}{
+		{ingredientID: "milk", want: []string{"orders1"}},
+		{ingredientID: "espresso", want: []string{"orders1", "orders2"}},
+		{ingredientID: "sugar", wantErr: ErrNoItem},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ingredientID, func(t *testing.T) {
+			s := newTestOrderService(0, stored...)
+			shot := models.MenuItem{ID: "shot", Name: "Espresso shot", Price: 2, Available: true, Ingredients: []models.MenuItemIngredient{{IngredientID: "espresso", Quantity: 1}}}
+			if _, err := s.MenuRepository.AddMenuItem(shot); err != nil {
+				t.Fatal(err)
+			}
+
+			orders, err := s.RetrieveOrdersUsingIngredient(tt.ingredientID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RetrieveOrdersUsingIngredient() error = %v, want %v", err, tt.wantErr)
+			}
+			ids := []string{}
+			for _, order := range orders {
+				ids = append(ids, order.ID)
+			}
+			if tt.wantErr == nil && !slices.Equal(ids, tt.want) {
+				t.Errorf("RetrieveOrdersUsingIngredient(%s) = %v, want %v", tt.ingredientID, ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseAllOrders(t *testing.T) {
+	order := func(id, createdAt, productID string, quantity float64) models.Order {
+		return models.Order{ID: id, CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: createdAt, Items: []models.OrderItem{{ProductID: productID, Quantity: quantity}}}
+	}
+
+	tests := []struct {
+		name         string
+		orders       []models.Order
+		wantStatuses map[string]string
+		wantMilk     float64
+		wantEspresso float64
+	}{
+		{
+			name: "every order fulfillable",
+			orders: []models.Order{
+				order("orders1", "2024-01-02T10:00:00Z", "latte", 2),
+				order("orders2", "2024-01-02T10:05:00Z", "cappuccino", 2),
+			},
+			wantStatuses: map[string]string{"orders1": models.BulkCloseClosed, "orders2": models.BulkCloseClosed},
+			wantMilk:     300,
+			wantEspresso: 94,
+		},
+		{
+			name: "later order left without enough milk",
+			orders: []models.Order{
+				order("orders1", "2024-01-02T10:00:00Z", "latte", 3),
+				order("orders2", "2024-01-02T10:05:00Z", "latte", 3),
+				order("orders3", "2024-01-02T10:10:00Z", "cappuccino", 2),
+			},
+			wantStatuses: map[string]string{"orders1": models.BulkCloseClosed, "orders2": models.BulkCloseInsufficient, "orders3": models.BulkCloseClosed},
+			wantMilk:     100,
+			wantEspresso: 93,
+		},
+		{
+			name: "no order fulfillable",
+			orders: []models.Order{
+				order("orders1", "2024-01-02T10:00:00Z", "latte", 6),
+			},
+			wantStatuses: map[string]string{"orders1": models.BulkCloseInsufficient},
+			wantMilk:     1000,
+			wantEspresso: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0, tt.orders...)
+
+			result, err := s.CloseAllOrders()
+			if err != nil {
+				t.Fatalf("CloseAllOrders() error = %v", err)
+			}
+			if len(result.Orders) != len(tt.wantStatuses) {
+				t.Fatalf("CloseAllOrders() reported %d orders, want %d", len(result.Orders), len(tt.wantStatuses))
+			}
+			for _, orderResult := range result.Orders {
+				if want := tt.wantStatuses[orderResult.OrderID]; orderResult.Status != want {
+					t.Errorf("order %s status = %q, want %q", orderResult.OrderID, orderResult.Status, want)
+				}
+
+				wantStatus := models.StatusClosed
+				if orderResult.Status != models.BulkCloseClosed {
+					wantStatus = models.StatusOpen
+				}
+				if got := retrieveOrder(t, s, orderResult.OrderID).Status; got != wantStatus {
+					t.Errorf("stored order %s status = %q, want %q", orderResult.OrderID, got, wantStatus)
+				}
+			}
+
+			for id, want := range map[string]float64{"milk": tt.wantMilk, "espresso": tt.wantEspresso} {
+				item, err := s.InventoryRepository.GetItemById(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if item.Quantity != want {
+					t.Errorf("%s left = %g, want %g", id, item.Quantity, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetrieveAvailableMenu(t *testing.T) {
+	tests := []struct {
+		name     string
+		milk     float64
+		espresso float64
+		// lattes are ordered before the menu is read, their reservation holds stock
+		lattes float64
+		want   []string
+	}{
+		{name: "everything in stock", milk: 1000, espresso: 100, want: []string{"latte", "cappuccino"}},
+		{name: "milk for a cappuccino only", milk: 170, espresso: 100, want: []string{"cappuccino"}},
+		{name: "espresso for a latte only", milk: 1000, espresso: 1, want: []string{"latte"}},
+		{name: "milk depleted", milk: 0, espresso: 100, want: []string{}},
+		{name: "milk reserved by open orders", milk: 1000, espresso: 100, lattes: 5, want: []string{}},
+		{name: "milk partly reserved", milk: 1000, espresso: 100, lattes: 4, want: []string{"latte", "cappuccino"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0)
+			if tt.lattes > 0 {
+				if _, err := addLatteOrder(s, tt.lattes); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.InventoryRepository.UpdateItems(func(items []models.InventoryItem) ([]models.InventoryItem, error) {
+				for i := range items {
+					switch items[i].IngredientID {
+					case "milk":
+						items[i].Quantity = tt.milk
+					case "espresso":
+						items[i].Quantity = tt.espresso
+					}
+				}
+				return items, nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			items, err := s.RetrieveAvailableMenu()
+			if err != nil {
+				t.Fatalf("RetrieveAvailableMenu() error = %v", err)
+			}
+			ids := []string{}
+			for _, item := range items {
+				ids = append(ids, item.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("RetrieveAvailableMenu() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetrieveOrdersSorting(t *testing.T) {
+	order := func(id, customerName string, status models.OrderStatus, createdAt string) models.Order {
+		return models.Order{ID: id, CustomerName: customerName, Status: status, CreatedAt: createdAt, Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}}
+	}
+	stored := []models.Order{
+		order("orders1", "bob", models.StatusOpen, "2024-01-02T10:00:00Z"),
+		order("orders2", "Carol", models.StatusClosed, "2024-01-02T12:00:00Z"),
+		order("orders3", "alice", "Open", "2024-01-02T11:00:00Z"),
+	}
+
+	tests := []struct {
+		sort    string
+		order   string
+		want    []string
+		wantErr error
+	}{
+		{want: []string{"orders2", "orders3", "orders1"}},
+		{sort: "created_at", order: "asc", want: []string{"orders1", "orders3", "orders2"}},
+		{sort: "customer_name", order: "asc", want: []string{"orders3", "orders1", "orders2"}},
+		{sort: "customer_name", want: []string{"orders2", "orders1", "orders3"}},
+		{sort: "status", order: "asc", want: []string{"orders2", "orders1", "orders3"}},
+		{sort: "total", wantErr: ErrNotValidSortField},
+		{sort: "created_at", order: "up", wantErr: ErrNotValidSortOrder},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort+" "+tt.order, func(t *testing.T) {
+			s := newTestOrderService(0, stored...)
+
+			orders, err := s.RetrieveOrders(OrderFilter{Sort: tt.sort, Order: tt.order})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RetrieveOrders() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			ids := []string{}
+			for _, order := range orders {
+				ids = append(ids, order.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("RetrieveOrders(sort=%q, order=%q) = %v, want %v", tt.sort, tt.order, ids, tt.want)
+			}
+		})
+	}
+}
+
+// hookedStorage runs beforeWrite, when set, before every write and fails the write with its error.
+type hookedStorage struct {
+	dal.Storage
+	beforeWrite func() error
+}
+
+func (s *hookedStorage) Write(key string, data []byte) error {
+	if s.beforeWrite != nil {
+		if err := s.beforeWrite(); err != nil {
+			return err
+		}
+	}
+	return s.Storage.Write(key, data)
+}
+
+func TestCloseOrderRollbackKeepsOtherSales(t *testing.T) {
+	storage := &hookedStorage{Storage: dal.NewInMemoryStorage()}
+	orders := dal.NewOrderRepositoryWithStorage(storage, "orders")
+	if err := orders.SaveOrders([]models.Order{{
+		ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z",
+		Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewOrderService(
+		orders,
+		dal.NewInMemoryMenuRepository(testMenu()...),
+		dal.NewInMemoryInventoryRepository(testInventory()...),
+		dal.NewInMemoryReportRepository(0),
+		dal.NewInMemoryOrderAuditRepository(),
+		dal.NewInMemoryReservationRepository(),
+		0,
+	)
+
+	// Another order is sold after this sale was recorded, then saving the closed order fails
+	storage.beforeWrite = func() error {
+		if err := s.ReportRepository.RecordSale(models.TotalSales{Subtotal: 10, TotalSales: 10}); err != nil {
+			t.Fatal(err)
+		}
+		return errors.New("disk full")
+	}
+
+	if err := s.CloseOrder("orders1"); err == nil {
+		t.Fatal("CloseOrder() error = nil, want the failed write")
+	}
+
+	sales, err := s.ReportRepository.GetTotalSales()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sales.Subtotal != 10 || sales.TotalSales != 10 {
+		t.Errorf("total sales = %+v, want only the other sale of 10", sales)
+	}
+	milk, err := s.InventoryRepository.GetItemById("milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if milk.Quantity != 1000 {
+		t.Errorf("milk left = %g, want 1000 after the rollback", milk.Quantity)
+	}
+}
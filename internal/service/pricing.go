@@ -0,0 +1,77 @@
+package service
+
+import (
+	"math"
+
+	"hot-coffee/models"
+)
+
+// orderTaxRate returns the tax rate, in percent, the order was closed with, or the current rate if none was recorded.
+func orderTaxRate(order models.Order, currentRate float64) float64 {
+	if order.TaxRate != nil {
+		return *order.TaxRate
+	}
+	return currentRate
+}
+
+// applyDiscount returns the subtotal reduced by the discount, never below zero.
+// A fixed discount larger than the subtotal, e.g. after a menu item was removed, makes the total zero.
+func applyDiscount(subtotal float64, discount *models.Discount) float64 {
+	if discount == nil {
+		return subtotal
+	}
+
+	total := subtotal
+	switch discount.Type {
+	case models.DiscountPercentage:
+		total -= subtotal * discount.Value / 100
+	case models.DiscountFixed:
+		total -= discount.Value
+	}
+
+	return max(total, 0)
+}
+
+// roundCents rounds an amount to two decimal places.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// orderPricing is the price breakdown of an order. Every amount is rounded to cents before
+// the next one is derived from it, so the total is always the sum of the rounded parts.
+type orderPricing struct {
+	subtotal float64
+	discount float64
+	tax      float64
+	total    float64
+}
+
+// newOrderPricing applies the discount and then the tax rate, in percent, to the subtotal.
+func newOrderPricing(subtotal float64, discount *models.Discount, taxRate float64) orderPricing {
+	subtotal = roundCents(subtotal)
+	discounted := roundCents(applyDiscount(subtotal, discount))
+	tax := roundCents(discounted * taxRate / 100)
+
+	return orderPricing{
+		subtotal: subtotal,
+		discount: roundCents(subtotal - discounted),
+		tax:      tax,
+		total:    roundCents(discounted + tax),
+	}
+}
+
+// setOn copies the breakdown into the derived price fields of the order.
+func (p orderPricing) setOn(order *models.Order) {
+	order.Subtotal = p.subtotal
+	order.DiscountAmount = p.discount
+	order.Tax = p.tax
+	order.TotalPrice = p.total
+}
+
+// add sums the breakdown into a sales report.
+func (p orderPricing) add(sales *models.TotalSales) {
+	sales.Subtotal = roundCents(sales.Subtotal + p.subtotal)
+	sales.Discounts = roundCents(sales.Discounts + p.discount)
+	sales.Tax = roundCents(sales.Tax + p.tax)
+	sales.TotalSales = roundCents(sales.TotalSales + p.total)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"sort"
+	"time"
 
 	"hot-coffee/internal/dal"
 	"hot-coffee/models"
@@ -9,7 +10,15 @@ import (
 
 type ReportService interface {
 	GetTotalSales() (models.TotalSales, error)
+	GetTotalSalesInRange(from, to time.Time) (models.TotalSales, error)
+	GetAverageOrderValue(from, to time.Time) (models.AverageOrderValue, error)
 	GetPopularItems() ([]models.MenuItem, error)
+	GetOrderVolume(bucket string) (map[string]int, error)
+	GetIngredientUsage() ([]models.IngredientUsage, error)
+	GetOrderCounts() (models.OrderCounts, error)
+	GetAverageFulfillmentTime() (models.AverageFulfillmentTime, error)
+	GetInventoryValue() (models.InventoryValue, error)
+	GetRevenueByItem() ([]models.ItemRevenue, error)
 }
 
 type reportService struct {
@@ -17,26 +26,99 @@ type reportService struct {
 	menuReposipory      dal.MenuRepository
 	inventoryRepository dal.InventoryRepository
 	reportRepository    dal.ReportRepository
+
+	// taxRate is the tax in percent applied to the discounted order subtotal
+	taxRate float64
 }
 
-func NewReportService(o dal.OrderRepository, m dal.MenuRepository, i dal.InventoryRepository, r dal.ReportRepository) *reportService {
+func NewReportService(o dal.OrderRepository, m dal.MenuRepository, i dal.InventoryRepository, r dal.ReportRepository, taxRate float64) *reportService {
 	if o == nil || m == nil || i == nil || r == nil {
 		return nil
 	}
-	return &reportService{orderRepository: o, menuReposipory: m, inventoryRepository: i, reportRepository: r}
+	return &reportService{orderRepository: o, menuReposipory: m, inventoryRepository: i, reportRepository: r, taxRate: taxRate}
 }
 
 func (rs *reportService) GetTotalSales() (models.TotalSales, error) {
 	return rs.reportRepository.GetTotalSales()
 }
 
+// GetTotalSalesInRange sums the revenue of closed orders whose ClosedAt falls within [from, to].
+// A zero from or to leaves that side of the range open.
+// Closed orders without a valid ClosedAt are excluded. Items are priced at the unit price pinned when
+// the order closed and taxed at the rate it closed with; items closed before prices were pinned use
+// the current menu price and are excluded once they are no longer on the menu.
+// Returns ErrNotValidTimeRange if from is after to.
+func (rs *reportService) GetTotalSalesInRange(from, to time.Time) (models.TotalSales, error) {
+	sales, _, err := rs.closedOrderSales(from, to)
+	if err != nil {
+		return models.TotalSales{}, err
+	}
+
+	return sales, nil
+}
+
+// GetAverageOrderValue divides the revenue of closed orders by their number, optionally within [from, to].
+// The average is 0 when there are no closed orders.
+// Returns ErrNotValidTimeRange if from is after to.
+func (rs *reportService) GetAverageOrderValue(from, to time.Time) (models.AverageOrderValue, error) {
+	sales, count, err := rs.closedOrderSales(from, to)
+	if err != nil {
+		return models.AverageOrderValue{}, err
+	}
+
+	average := models.AverageOrderValue{ClosedOrders: count}
+	if count > 0 {
+		average.AverageOrderValue = roundCents(sales.TotalSales / float64(count))
+	}
+
+	return average, nil
+}
+
+// closedOrderSales returns the sales breakdown and the number of the closed orders, priced as priceOrder does.
+// Without a range every closed order is counted, otherwise only those whose ClosedAt falls within [from, to].
+func (rs *reportService) closedOrderSales(from, to time.Time) (models.TotalSales, int, error) {
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return models.TotalSales{}, 0, ErrNotValidTimeRange
+	}
+
+	orders, err := rs.orderRepository.GetClosedOrders()
+	if err != nil {
+		return models.TotalSales{}, 0, err
+	}
+
+	menuItems, err := rs.menuReposipory.GetAllMenuItems()
+	if err != nil {
+		return models.TotalSales{}, 0, err
+	}
+
+	menuMap := indexMenuItems(menuItems)
+
+	sales, count := models.TotalSales{}, 0
+	for _, order := range orders {
+		if !from.IsZero() || !to.IsZero() {
+			closedAt, err := time.Parse(time.RFC3339, order.ClosedAt)
+			if err != nil {
+				continue
+			}
+			if (!from.IsZero() && closedAt.Before(from)) || (!to.IsZero() && closedAt.After(to)) {
+				continue
+			}
+		}
+
+		priceOrder(order, menuMap, rs.taxRate).add(&sales)
+		count++
+	}
+
+	return sales, count, nil
+}
+
 func (rs *reportService) GetPopularItems() ([]models.MenuItem, error) {
 	orders, err := rs.orderRepository.GetClosedOrders()
 	if err != nil {
 		return nil, err
 	}
 
-	frequencyMap := make(map[string]int)
+	frequencyMap := make(map[string]float64)
 	for _, order := range orders {
 		for _, item := range order.Items {
 			frequencyMap[item.ProductID] += item.Quantity
@@ -45,7 +127,7 @@ func (rs *reportService) GetPopularItems() ([]models.MenuItem, error) {
 
 	type menuItemCount struct {
 		ID    string
-		Count int
+		Count float64
 	}
 
 	menuItemsCount := []menuItemCount{}
@@ -73,3 +155,241 @@ func (rs *reportService) GetPopularItems() ([]models.MenuItem, error) {
 
 	return popularItems, nil
 }
+
+// GetRevenueByItem sums the price times the quantity of every product sold in closed orders, all sizes together.
+// Items are priced at the unit price saved when their order was closed, before discounts and tax.
+// Items of orders closed before the prices were saved fall back to the current menu price.
+// Returns the products sorted by revenue in descending order.
+func (rs *reportService) GetRevenueByItem() ([]models.ItemRevenue, error) {
+	orders, err := rs.orderRepository.GetClosedOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	menuItems, err := rs.menuReposipory.GetAllMenuItems()
+	if err != nil {
+		return nil, err
+	}
+	menuMap := indexMenuItems(menuItems)
+
+	revenueMap := make(map[string]*models.ItemRevenue)
+	for _, order := range orders {
+		for _, item := range order.Items {
+			price, err := orderItemPrice(item, menuMap[item.ProductID])
+			if err != nil {
+				continue
+			}
+
+			revenue, exists := revenueMap[item.ProductID]
+			if !exists {
+				revenue = &models.ItemRevenue{ProductID: item.ProductID, Name: menuMap[item.ProductID].Name}
+				revenueMap[item.ProductID] = revenue
+			}
+			revenue.UnitsSold += item.Quantity
+			revenue.Revenue += price * item.Quantity
+		}
+	}
+
+	revenues := []models.ItemRevenue{}
+	for _, revenue := range revenueMap {
+		revenue.Revenue = roundCents(revenue.Revenue)
+		revenues = append(revenues, *revenue)
+	}
+
+	sort.Slice(revenues, func(i, j int) bool {
+		if revenues[i].Revenue == revenues[j].Revenue {
+			return revenues[i].ProductID < revenues[j].ProductID
+		}
+		return revenues[i].Revenue > revenues[j].Revenue
+	})
+
+	return revenues, nil
+}
+
+// GetOrderVolume counts the orders created per time bucket.
+// The bucket must be "hour" or "day", keys are the truncated UTC times in RFC3339 format.
+// Orders with an unparseable CreatedAt are skipped.
+// Returns ErrNotValidBucket if the bucket is not supported.
+func (rs *reportService) GetOrderVolume(bucket string) (map[string]int, error) {
+	if bucket != "hour" && bucket != "day" {
+		return nil, ErrNotValidBucket
+	}
+
+	orders, err := rs.orderRepository.GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	volume := make(map[string]int)
+	for _, order := range orders {
+		createdAt, err := time.Parse(time.RFC3339, order.CreatedAt)
+		if err != nil {
+			continue
+		}
+
+		createdAt = createdAt.UTC()
+		if bucket == "day" {
+			createdAt = time.Date(createdAt.Year(), createdAt.Month(), createdAt.Day(), 0, 0, 0, 0, time.UTC)
+		} else {
+			createdAt = createdAt.Truncate(time.Hour)
+		}
+
+		volume[createdAt.Format(time.RFC3339)]++
+	}
+
+	return volume, nil
+}
+
+// GetIngredientUsage aggregates the quantity of every ingredient consumed by closed orders,
+// using the recipes saved on the items when they were closed. Items closed before recipes were saved
+// fall back to the current menu recipes. Quantities are expressed in the unit of the inventory item.
+// Returns the usage sorted by quantity in descending order.
+func (rs *reportService) GetIngredientUsage() ([]models.IngredientUsage, error) {
+	orders, err := rs.orderRepository.GetClosedOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	menuItems, err := rs.menuReposipory.GetAllMenuItems()
+	if err != nil {
+		return nil, err
+	}
+	menuMap := indexMenuItems(menuItems)
+
+	inventoryItems, err := rs.inventoryRepository.GetAllItems()
+	if err != nil {
+		return nil, err
+	}
+	inventoryMap := make(map[string]models.InventoryItem)
+	for _, item := range inventoryItems {
+		inventoryMap[item.IngredientID] = item
+	}
+
+	usageMap := make(map[string]*models.IngredientUsage)
+	for _, order := range orders {
+		for _, orderItem := range order.Items {
+			recipe, err := orderItemRecipe(orderItem, menuMap)
+			if err != nil {
+				continue
+			}
+
+			for _, ingredient := range recipe {
+				quantity, unit := ingredient.Quantity, ingredient.Unit
+				if inventoryItem, exists := inventoryMap[ingredient.IngredientID]; exists {
+					converted, err := ConvertQuantity(ingredient.Quantity, ingredient.Unit, inventoryItem.Unit)
+					if err != nil {
+						return nil, err
+					}
+					quantity, unit = converted, inventoryItem.Unit
+				}
+
+				usage, exists := usageMap[ingredient.IngredientID]
+				if !exists {
+					usage = &models.IngredientUsage{IngredientID: ingredient.IngredientID, Unit: unit}
+					usageMap[ingredient.IngredientID] = usage
+				}
+				usage.Quantity += quantity * orderItem.Quantity
+			}
+		}
+	}
+
+	usages := []models.IngredientUsage{}
+	for _, usage := range usageMap {
+		usages = append(usages, *usage)
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Quantity == usages[j].Quantity {
+			return usages[i].IngredientID < usages[j].IngredientID
+		}
+		return usages[i].Quantity > usages[j].Quantity
+	})
+
+	return usages, nil
+}
+
+// GetAverageFulfillmentTime averages the time from creation to closing of the closed orders.
+// Closed orders with a missing or malformed CreatedAt or ClosedAt are skipped.
+// The average is 0 when no closed order has a valid fulfillment time.
+func (rs *reportService) GetAverageFulfillmentTime() (models.AverageFulfillmentTime, error) {
+	orders, err := rs.orderRepository.GetClosedOrders()
+	if err != nil {
+		return models.AverageFulfillmentTime{}, err
+	}
+
+	var total int64
+	average := models.AverageFulfillmentTime{}
+	for _, order := range orders {
+		seconds, ok := fulfillmentSeconds(order)
+		if !ok {
+			continue
+		}
+		total += seconds
+		average.ClosedOrders++
+	}
+
+	if average.ClosedOrders > 0 {
+		average.AverageSeconds = roundCents(float64(total) / float64(average.ClosedOrders))
+	}
+
+	return average, nil
+}
+
+// GetOrderCounts counts the open and closed orders.
+func (rs *reportService) GetOrderCounts() (models.OrderCounts, error) {
+	orders, err := rs.orderRepository.GetAllOrders()
+	if err != nil {
+		return models.OrderCounts{}, err
+	}
+
+	counts := models.OrderCounts{}
+	for _, order := range orders {
+		switch order.Status.Normalize() {
+		case models.StatusOpen:
+			counts.Open++
+		case models.StatusClosed:
+			counts.Closed++
+		}
+	}
+	counts.Total = counts.Open + counts.Closed
+
+	return counts, nil
+}
+
+// GetInventoryValue sums the quantity times the cost per unit of every inventory item.
+// Items without a cost per unit are excluded from the total and listed in UncostedItems,
+// items with a cost of zero are counted at no value.
+// Both lists are sorted by ingredient ID.
+func (rs *reportService) GetInventoryValue() (models.InventoryValue, error) {
+	items, err := rs.inventoryRepository.GetAllItems()
+	if err != nil {
+		return models.InventoryValue{}, err
+	}
+
+	value := models.InventoryValue{Items: []models.InventoryItemValue{}, UncostedItems: []string{}}
+	var total float64
+	for _, item := range items {
+		if item.CostPerUnit == nil {
+			value.UncostedItems = append(value.UncostedItems, item.IngredientID)
+			continue
+		}
+
+		itemValue := item.Quantity * *item.CostPerUnit
+		total += itemValue
+		value.Items = append(value.Items, models.InventoryItemValue{
+			IngredientID: item.IngredientID,
+			Quantity:     item.Quantity,
+			Unit:         item.Unit,
+			CostPerUnit:  *item.CostPerUnit,
+			Value:        roundCents(itemValue),
+		})
+	}
+	value.TotalValue = roundCents(total)
+
+	sort.Slice(value.Items, func(i, j int) bool {
+		return value.Items[i].IngredientID < value.Items[j].IngredientID
+	})
+	sort.Strings(value.UncostedItems)
+
+	return value, nil
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// newTestReportService reports on the repositories of the order service.
+func newTestReportService(s *orderService) *reportService {
+	return NewReportService(s.OrderRepository, s.MenuRepository, s.InventoryRepository, s.ReportRepository, s.taxRate)
+}
+
+// setMenuPrice changes the price of a menu item in the repository, without pinning the prices of open orders.
+func setMenuPrice(t *testing.T, s *orderService, id string, price float64) {
+	t.Helper()
+
+	menuItems, err := s.MenuRepository.GetAllMenuItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range menuItems {
+		if menuItems[i].ID == id {
+			menuItems[i].Price = price
+		}
+	}
+	if err := s.MenuRepository.SaveMenuItems(menuItems); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetRevenueByItemUsesClosePrices(t *testing.T) {
+	tests := []struct {
+		name        string
+		orders      []models.Order
+		close       bool
+		newPrice    float64
+		wantRevenue float64
+	}{
+		{
+			name:        "closed before the price change",
+			orders:      []models.Order{{CustomerName: "Alice", Items: []models.OrderItem{{ProductID: "latte", Quantity: 2}}}},
+			close:       true,
+			newPrice:    5,
+			wantRevenue: 7,
+		},
+		{
+			name: "closed before prices were saved",
+			orders: []models.Order{{
+				ID: "orders1", Status: models.StatusClosed, CreatedAt: "2026-10-14T08:00:00Z",
+				Items: []models.OrderItem{{ProductID: "latte", Quantity: 2}},
+			}},
+			newPrice:    5,
+			wantRevenue: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s *orderService
+			if tt.close {
+				s = newTestOrderService(0)
+				for _, order := range tt.orders {
+					created, err := s.AddOrder(order)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := s.CloseOrder(created.ID); err != nil {
+						t.Fatal(err)
+					}
+				}
+			} else {
+				s = newTestOrderService(0, tt.orders...)
+			}
+			setMenuPrice(t, s, "latte", tt.newPrice)
+
+			revenues, err := newTestReportService(s).GetRevenueByItem()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(revenues) != 1 || revenues[0].Revenue != tt.wantRevenue {
+				t.Errorf("GetRevenueByItem() = %+v, want latte revenue %g", revenues, tt.wantRevenue)
+			}
+		})
+	}
+}
+
+func TestGetRevenueByItem(t *testing.T) {
+	order := func(id string, status models.OrderStatus, items ...models.OrderItem) models.Order {
+		return models.Order{ID: id, CustomerName: "Alice", Status: status, CreatedAt: "2024-01-02T10:00:00Z", Items: items}
+	}
+	item := func(productID string, quantity, unitPrice float64) models.OrderItem {
+		return models.OrderItem{ProductID: productID, Quantity: quantity, UnitPrice: unitPrice}
+	}
+
+	tests := []struct {
+		name   string
+		orders []models.Order
+		want   []models.ItemRevenue
+	}{
+		{
+			name:   "no closed orders",
+			orders: []models.Order{order("orders1", models.StatusOpen, item("latte", 2, 3.5))},
+			want:   []models.ItemRevenue{},
+		},
+		{
+			name: "summed across orders, highest first",
+			orders: []models.Order{
+				order("orders1", models.StatusClosed, item("latte", 2, 3.5), item("cappuccino", 1, 4)),
+				order("orders2", models.StatusClosed, item("latte", 1, 3.5)),
+				order("orders3", models.StatusOpen, item("cappuccino", 5, 4)),
+			},
+			want: []models.ItemRevenue{
+				{ProductID: "latte", Name: "Latte", UnitsSold: 3, Revenue: 10.5},
+				{ProductID: "cappuccino", Name: "Cappuccino", UnitsSold: 1, Revenue: 4},
+			},
+		},
+		{
+			name: "different close prices of one product",
+			orders: []models.Order{
+				order("orders1", models.StatusClosed, item("latte", 1, 3.5)),
+				order("orders2", models.StatusClosed, item("latte", 2, 3.75)),
+			},
+			want: []models.ItemRevenue{{ProductID: "latte", Name: "Latte", UnitsSold: 3, Revenue: 11}},
+		},
+		{
+			name: "equal revenue sorted by product ID",
+			orders: []models.Order{
+				order("orders1", models.StatusClosed, item("latte", 8, 3.5), item("cappuccino", 7, 4)),
+			},
+			want: []models.ItemRevenue{
+				{ProductID: "cappuccino", Name: "Cappuccino", UnitsSold: 7, Revenue: 28},
+				{ProductID: "latte", Name: "Latte", UnitsSold: 8, Revenue: 28},
+			},
+		},
+		{
+			name:   "product no longer on the menu",
+			orders: []models.Order{order("orders1", models.StatusClosed, item("mocha", 2, 5))},
+			want:   []models.ItemRevenue{{ProductID: "mocha", UnitsSold: 2, Revenue: 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0, tt.orders...)
+
+			revenues, err := newTestReportService(s).GetRevenueByItem()
+			if err != nil {
+				t.Fatalf("GetRevenueByItem() error = %v", err)
+			}
+			if !slices.Equal(revenues, tt.want) {
+				t.Errorf("GetRevenueByItem() = %+v, want %+v", revenues, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetIngredientUsageUsesCloseRecipes(t *testing.T) {
+	tests := []struct {
+		name string
+		// close closes a latte order through the service, saving its recipe, instead of storing it closed
+		close      bool
+		changeMenu func(menuItems []models.MenuItem) []models.MenuItem
+		wantMilk   float64
+	}{
+		{
+			name:  "recipe changed after close",
+			close: true,
+			changeMenu: func(menuItems []models.MenuItem) []models.MenuItem {
+				menuItems[0].Ingredients = []models.MenuItemIngredient{{IngredientID: "milk", Quantity: 300}, {IngredientID: "espresso", Quantity: 1}}
+				return menuItems
+			},
+			wantMilk: 400,
+		},
+		{
+			name:  "product removed after close",
+			close: true,
+			changeMenu: func(menuItems []models.MenuItem) []models.MenuItem {
+				return menuItems[1:]
+			},
+			wantMilk: 400,
+		},
+		{
+			name: "closed before recipes were saved",
+			changeMenu: func(menuItems []models.MenuItem) []models.MenuItem {
+				menuItems[0].Ingredients = []models.MenuItemIngredient{{IngredientID: "milk", Quantity: 300}, {IngredientID: "espresso", Quantity: 1}}
+				return menuItems
+			},
+			wantMilk: 600,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := models.Order{CustomerName: "Alice", Items: []models.OrderItem{{ProductID: "latte", Quantity: 2}}}
+			var s *orderService
+			if tt.close {
+				s = newTestOrderService(0)
+				created, err := s.AddOrder(order)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := s.CloseOrder(created.ID); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				order.ID, order.Status, order.CreatedAt = "orders1", models.StatusClosed, "2024-01-02T10:00:00Z"
+				s = newTestOrderService(0, order)
+			}
+
+			menuItems, err := s.MenuRepository.GetAllMenuItems()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.MenuRepository.SaveMenuItems(tt.changeMenu(menuItems)); err != nil {
+				t.Fatal(err)
+			}
+
+			usage, err := newTestReportService(s).GetIngredientUsage()
+			if err != nil {
+				t.Fatalf("GetIngredientUsage() error = %v", err)
+			}
+			milk := slices.IndexFunc(usage, func(u models.IngredientUsage) bool { return u.IngredientID == "milk" })
+			if milk < 0 || usage[milk].Quantity != tt.wantMilk {
+				t.Errorf("GetIngredientUsage() = %+v, want %g ml of milk", usage, tt.wantMilk)
+			}
+		})
+	}
+}
+
+func TestGetInventoryValue(t *testing.T) {
+	free, cost := 0.0, 0.5
+	tests := []struct {
+		name         string
+		cost         *float64
+		wantTotal    float64
+		wantItems    int
+		wantUncosted []string
+	}{
+		{name: "no cost per unit", wantItems: 0, wantUncosted: []string{"milk"}},
+		{name: "zero cost per unit", cost: &free, wantItems: 1, wantUncosted: []string{}},
+		{name: "cost per unit", cost: &cost, wantTotal: 500, wantItems: 1, wantUncosted: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			milk := models.InventoryItem{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml", CostPerUnit: tt.cost}
+			rs := NewReportService(
+				dal.NewInMemoryOrderRepository(),
+				dal.NewInMemoryMenuRepository(),
+				dal.NewInMemoryInventoryRepository(milk),
+				dal.NewInMemoryReportRepository(0),
+				0,
+			)
+
+			value, err := rs.GetInventoryValue()
+			if err != nil {
+				t.Fatalf("GetInventoryValue() error = %v", err)
+			}
+			if value.TotalValue != tt.wantTotal || len(value.Items) != tt.wantItems || !slices.Equal(value.UncostedItems, tt.wantUncosted) {
+				t.Errorf("GetInventoryValue() = %+v, want total %g, %d items, uncosted %v", value, tt.wantTotal, tt.wantItems, tt.wantUncosted)
+			}
+		})
+	}
+}
+
+func TestGetAverageOrderValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		orders     []models.Order
+		wantCount  int
+		wantAmount float64
+	}{
+		{name: "no orders"},
+		{
+			name:   "only open orders",
+			orders: []models.Order{{ID: "orders1", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}}},
+		},
+		{
+			name: "closed orders",
+			orders: []models.Order{
+				{ID: "orders1", Status: models.StatusClosed, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}},
+				{ID: "orders2", Status: models.StatusClosed, CreatedAt: "2024-01-02T10:00:00Z", Items: []models.OrderItem{{ProductID: "cappuccino", Quantity: 2}}},
+			},
+			wantCount:  2,
+			wantAmount: 5.75,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0, tt.orders...)
+
+			average, err := newTestReportService(s).GetAverageOrderValue(time.Time{}, time.Time{})
+			if err != nil {
+				t.Fatalf("GetAverageOrderValue() error = %v", err)
+			}
+			if average.ClosedOrders != tt.wantCount || average.AverageOrderValue != tt.wantAmount {
+				t.Errorf("GetAverageOrderValue() = %+v, want %d orders averaging %g", average, tt.wantCount, tt.wantAmount)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"hot-coffee/models"
+	"hot-coffee/pkg/logger"
+)
+
+// ReserveInventory holds the ingredients of an open order in the inventory, so other orders can not use them.
+// The reserved stock stays on hand until the order is closed, when it is deducted, or deleted.
+// Reserving an order again replaces its reservation with one for its current items.
+// The following errors may be returned:
+// - ErrNoOrder if the order is not found.
+// - ErrOrderClosed if the order is closed.
+// - ErrNotEnoughInventoryQuantity if the stock not reserved by other orders can not fulfill the order.
+// - ErrOrderProductNotFound, ErrOrderSizeNotFound or ErrInventoryItemNotFound if the order can not be resolved.
+func (s *orderService) ReserveInventory(orderID string) (models.InventoryReservation, error) {
+	order, err := s.OrderRepository.GetOrderById(orderID)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return models.InventoryReservation{}, ErrNoOrder
+		}
+		return models.InventoryReservation{}, err
+	}
+
+	if err := ValidateStatus(order.Status); err != nil {
+		return models.InventoryReservation{}, err
+	}
+	if order.Status.Normalize() == models.StatusClosed {
+		return models.InventoryReservation{}, ErrOrderClosed
+	}
+
+	reservation, err := s.reserveOrder(order)
+	if err != nil {
+		if errors.Is(err, ErrNotEnoughInventoryQuantity) {
+			inventoryRejections.Inc()
+		}
+		return models.InventoryReservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// reserveOrder saves a reservation of the current items of the order, replacing its earlier reservation.
+func (s *orderService) reserveOrder(order models.Order) (models.InventoryReservation, error) {
+	return s.reserveItems(order.ID, order.Items, order.ID)
+}
+
+// BackfillReservations reserves the stock of the open orders that have no reservation, e.g. orders saved
+// before reservations were introduced, in queue order. Orders the stock not reserved by other orders can not
+// fulfill are left without a reservation and logged. Returns the number of orders reserved.
+func (s *orderService) BackfillReservations() (int, error) {
+	orders, err := s.OrderRepository.GetOpenOrders()
+	if err != nil {
+		return 0, err
+	}
+	sortByQueue(orders)
+
+	reservations, err := s.ReservationRepository.GetAllReservations()
+	if err != nil {
+		return 0, err
+	}
+	reservedOrders := make(map[string]bool)
+	for _, reservation := range reservations {
+		reservedOrders[reservation.OrderID] = true
+	}
+
+	reservedCount := 0
+	for _, order := range orders {
+		if reservedOrders[order.ID] {
+			continue
+		}
+
+		if _, err := s.reserveOrder(order); err != nil {
+			logger.LOGGER.PrintWarnMsg("Failed to reserve the inventory of open order %s: %v", order.ID, err)
+			continue
+		}
+		reservedCount++
+	}
+
+	return reservedCount, nil
+}
+
+// ReleaseInventory drops the reservation of an order, returning its stock to the other orders.
+// The following errors may be returned:
+// - ErrNoOrder if the order is not found.
+// - ErrNoReservation if the order has no reservation.
+func (s *orderService) ReleaseInventory(orderID string) error {
+	if exists, err := s.OrderRepository.OrderExists(models.Order{ID: orderID}); err != nil {
+		return err
+	} else if !exists {
+		return ErrNoOrder
+	}
+
+	released, err := s.ReservationRepository.DeleteReservation(orderID)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return ErrNoReservation
+	}
+
+	return nil
+}
+
+// reserveItems reserves the order items for the order with the given ID and saves the reservation.
+// The reservation of the order replacedID, which may be the same order, is replaced and not counted against the stock.
+// Returns ErrNotEnoughInventoryQuantity, with nothing saved, if the stock not reserved by other orders is not enough.
+func (s *orderService) reserveItems(orderID string, orderItems []models.OrderItem, replacedID string) (models.InventoryReservation, error) {
+	inventoryMap, menuMap, err := s.inventoryAndMenu()
+	if err != nil {
+		return models.InventoryReservation{}, err
+	}
+
+	required, ingredientIDs, err := sumIngredients(orderItems, menuMap, inventoryMap)
+	if err != nil {
+		return models.InventoryReservation{}, err
+	}
+
+	return s.saveReservation(orderID, replacedID, func(reserved map[string]float64) ([]models.ReservedIngredient, error) {
+		ingredients := []models.ReservedIngredient{}
+		for _, id := range ingredientIDs {
+			if required[id] > inventoryMap[id].Quantity-reserved[id] {
+				return nil, ErrNotEnoughInventoryQuantity
+			}
+			ingredients = append(ingredients, models.ReservedIngredient{IngredientID: id, Quantity: required[id]})
+		}
+		return ingredients, nil
+	})
+}
+
+// reserveRemaining reserves the items of the order left over by a partial close of the order closedID,
+// replacing the reservation of the closed order. The stock the closed items need is kept free for them.
+// The remaining items are short of stock by definition, so every ingredient is reserved up to the stock left
+// instead of failing, the order holds what there is until it is restocked and reserved again.
+func (s *orderService) reserveRemaining(remainingOrder models.Order, closedID string, closedItems []models.OrderItem) (models.InventoryReservation, error) {
+	inventoryMap, menuMap, err := s.inventoryAndMenu()
+	if err != nil {
+		return models.InventoryReservation{}, err
+	}
+
+	required, ingredientIDs, err := sumIngredients(remainingOrder.Items, menuMap, inventoryMap)
+	if err != nil {
+		return models.InventoryReservation{}, err
+	}
+	closing, _, err := sumIngredients(closedItems, menuMap, inventoryMap)
+	if err != nil {
+		return models.InventoryReservation{}, err
+	}
+
+	return s.saveReservation(remainingOrder.ID, closedID, func(reserved map[string]float64) ([]models.ReservedIngredient, error) {
+		ingredients := []models.ReservedIngredient{}
+		for _, id := range ingredientIDs {
+			quantity := min(required[id], inventoryMap[id].Quantity-reserved[id]-closing[id])
+			if quantity > 0 {
+				ingredients = append(ingredients, models.ReservedIngredient{IngredientID: id, Quantity: quantity})
+			}
+		}
+		return ingredients, nil
+	})
+}
+
+// saveReservation saves the reservation of the order with the given ID, replacing the reservation of the order
+// replacedID, which may be the same order. The ingredients are chosen by reserve from the quantities reserved by
+// the other open orders, leaving out replacedID. Reading the other reservations and the write are one locked
+// update, so two orders reserved at the same time can not both hold the same stock.
+// Nothing is saved if reserve returns an error.
+func (s *orderService) saveReservation(orderID, replacedID string, reserve func(reserved map[string]float64) ([]models.ReservedIngredient, error)) (models.InventoryReservation, error) {
+	var reservation models.InventoryReservation
+	err := s.ReservationRepository.UpdateReservations(func(reservations []models.InventoryReservation) ([]models.InventoryReservation, error) {
+		// The open orders are read under the lock too, a reservation saved meanwhile belongs to a saved order
+		orders, err := s.OrderRepository.GetAllOrders()
+		if err != nil {
+			return nil, err
+		}
+
+		ingredients, err := reserve(sumReservations(reservations, orders, replacedID))
+		if err != nil {
+			return nil, err
+		}
+
+		reservation = models.InventoryReservation{
+			OrderID:     orderID,
+			Ingredients: ingredients,
+			ReservedAt:  time.Now().Format(time.RFC3339),
+		}
+		return replaceReservation(reservations, replacedID, reservation), nil
+	})
+	if err != nil {
+		return models.InventoryReservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// inventoryAndMenu returns the inventory and menu items by their ID.
+func (s *orderService) inventoryAndMenu() (map[string]models.InventoryItem, map[string]models.MenuItem, error) {
+	inventoryItems, err := s.InventoryRepository.GetAllItems()
+	if err != nil {
+		return nil, nil, err
+	}
+	inventoryMap := make(map[string]models.InventoryItem)
+	for _, item := range inventoryItems {
+		inventoryMap[item.IngredientID] = item
+	}
+
+	menuMap, err := s.menuItemsByID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return inventoryMap, menuMap, nil
+}
+
+// replaceReservation returns the reservations with the reservation of replacedID and any earlier reservation
+// of the same order dropped and the reservation appended.
+func replaceReservation(reservations []models.InventoryReservation, replacedID string, reservation models.InventoryReservation) []models.InventoryReservation {
+	reservations = slices.DeleteFunc(reservations, func(existing models.InventoryReservation) bool {
+		return existing.OrderID == replacedID || existing.OrderID == reservation.OrderID
+	})
+	return append(reservations, reservation)
+}
+
+// findReservation returns the reservation of an order, or nil if the order has none.
+func (s *orderService) findReservation(orderID string) (*models.InventoryReservation, error) {
+	reservations, err := s.ReservationRepository.GetAllReservations()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, reservation := range reservations {
+		if reservation.OrderID == orderID {
+			return &reservation, nil
+		}
+	}
+	return nil, nil
+}
+
+// restoreReservation puts back the reservation an order had before a failed change, dropping
+// the reservation saved for the change under orderID. Failures are logged, the stale reservations
+// of orders that are not open are ignored on read.
+func (s *orderService) restoreReservation(orderID string, previous *models.InventoryReservation) {
+	err := s.ReservationRepository.UpdateReservations(func(reservations []models.InventoryReservation) ([]models.InventoryReservation, error) {
+		reservations = slices.DeleteFunc(reservations, func(existing models.InventoryReservation) bool {
+			return existing.OrderID == orderID
+		})
+		if previous != nil {
+			reservations = replaceReservation(reservations, previous.OrderID, *previous)
+		}
+		return reservations, nil
+	})
+	if err != nil {
+		logger.LOGGER.PrintErrorMsg("Failed to restore the inventory reservation of order %s: %v", orderID, err)
+	}
+}
+
+// reservedQuantities sums the reserved quantity of every ingredient, leaving out the reservation of exceptID.
+// Reservations left behind by closed or deleted orders are ignored.
+func (s *orderService) reservedQuantities(exceptID string) (map[string]float64, error) {
+	reservations, err := s.ReservationRepository.GetAllReservations()
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.OrderRepository.GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	return sumReservations(reservations, orders, exceptID), nil
+}
+
+// sumReservations sums the reserved quantity of every ingredient over the reservations of the open orders,
+// leaving out the reservation of exceptID.
+func sumReservations(reservations []models.InventoryReservation, orders []models.Order, exceptID string) map[string]float64 {
+	openOrders := make(map[string]bool)
+	for _, order := range orders {
+		if order.Status.Normalize() != models.StatusClosed {
+			openOrders[order.ID] = true
+		}
+	}
+
+	reserved := make(map[string]float64)
+	for _, reservation := range reservations {
+		if reservation.OrderID == exceptID || !openOrders[reservation.OrderID] {
+			continue
+		}
+		for _, ingredient := range reservation.Ingredients {
+			reserved[ingredient.IngredientID] += ingredient.Quantity
+		}
+	}
+
+	return reserved
+}
+
+// releaseReservation drops the reservation of a closed or deleted order.
+// The order change is already saved at this point and stale reservations are ignored on read,
+// so a failure is logged instead of failing the request.
+func (s *orderService) releaseReservation(orderID string) {
+	if _, err := s.ReservationRepository.DeleteReservation(orderID); err != nil {
+		logger.LOGGER.PrintErrorMsg("Failed to release the inventory reservation of order %s: %v", orderID, err)
+	}
+}
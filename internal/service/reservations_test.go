@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+// addLatteOrder creates an order of lattes, which reserves 200 ml of milk for each latte.
+func addLatteOrder(s *orderService, lattes float64) (models.Order, error) {
+	return s.AddOrder(models.Order{CustomerName: "Alice", Items: []models.OrderItem{{ProductID: "latte", Quantity: lattes}}})
+}
+
+func TestReservations(t *testing.T) {
+	tests := []struct {
+		name string
+		// run changes the service after an order of 3 lattes, reserving 600 ml of milk, was created
+		run          func(s *orderService, first models.Order) error
+		wantErr      error
+		wantReserved float64
+		wantMilk     float64
+	}{
+		{
+			name:         "reserved on create",
+			run:          func(s *orderService, first models.Order) error { return nil },
+			wantReserved: 600, wantMilk: 1000,
+		},
+		{
+			name: "other orders can not take reserved stock",
+			run: func(s *orderService, first models.Order) error {
+				_, err := addLatteOrder(s, 3)
+				return err
+			},
+			wantErr: ErrNotEnoughInventoryQuantity, wantReserved: 600, wantMilk: 1000,
+		},
+		{
+			name: "released stock goes to other orders",
+			run: func(s *orderService, first models.Order) error {
+				if err := s.ReleaseInventory(first.ID); err != nil {
+					return err
+				}
+				_, err := addLatteOrder(s, 3)
+				return err
+			},
+			wantReserved: 600, wantMilk: 1000,
+		},
+		{
+			name: "release twice",
+			run: func(s *orderService, first models.Order) error {
+				if err := s.ReleaseInventory(first.ID); err != nil {
+					return err
+				}
+				return s.ReleaseInventory(first.ID)
+			},
+			wantErr: ErrNoReservation, wantReserved: 0, wantMilk: 1000,
+		},
+		{
+			name:         "committed on close",
+			run:          func(s *orderService, first models.Order) error { return s.CloseOrder(first.ID) },
+			wantReserved: 0, wantMilk: 400,
+		},
+		{
+			name:         "released on delete",
+			run:          func(s *orderService, first models.Order) error { return s.DeleteOrder(first.ID) },
+			wantReserved: 0, wantMilk: 1000,
+		},
+		{
+			name: "reserved again after release",
+			run: func(s *orderService, first models.Order) error {
+				if err := s.ReleaseInventory(first.ID); err != nil {
+					return err
+				}
+				_, err := s.ReserveInventory(first.ID)
+				return err
+			},
+			wantReserved: 600, wantMilk: 1000,
+		},
+		{
+			name: "closed order can not be reserved",
+			run: func(s *orderService, first models.Order) error {
+				if err := s.CloseOrder(first.ID); err != nil {
+					return err
+				}
+				_, err := s.ReserveInventory(first.ID)
+				return err
+			},
+			wantErr: ErrOrderClosed, wantReserved: 0, wantMilk: 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrderService(0)
+			first, err := addLatteOrder(s, 3)
+			if err != nil {
+				t.Fatalf("AddOrder() error = %v", err)
+			}
+
+			if err := tt.run(s, first); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			reserved, err := s.reservedQuantities("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reserved["milk"] != tt.wantReserved {
+				t.Errorf("reserved milk = %g, want %g", reserved["milk"], tt.wantReserved)
+			}
+			milk, err := s.InventoryRepository.GetItemById("milk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if milk.Quantity != tt.wantMilk {
+				t.Errorf("milk on hand = %g, want %g", milk.Quantity, tt.wantMilk)
+			}
+		})
+	}
+}
+
+func TestClosePartialOrderReservesRemaining(t *testing.T) {
+	s := newTestOrderService(0, models.Order{
+		ID: "orders1", CustomerName: "Alice", Status: models.StatusOpen, CreatedAt: "2024-01-02T10:00:00Z",
+		Items: []models.OrderItem{{ProductID: "latte", Quantity: 1}, {ProductID: "cappuccino", Quantity: 1}},
+	})
+	// The milk makes the latte, the cappuccino waits on the remaining order
+	if err := s.InventoryRepository.UpdateItems(func(items []models.InventoryItem) ([]models.InventoryItem, error) {
+		for i := range items {
+			if items[i].IngredientID == "milk" {
+				items[i].Quantity = 300
+			}
+		}
+		return items, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.ClosePartialOrder("orders1")
+	if err != nil {
+		t.Fatalf("ClosePartialOrder() error = %v", err)
+	}
+	if result.RemainingOrderID == "" {
+		t.Fatal("ClosePartialOrder() left no remaining order")
+	}
+
+	reservation, err := s.findReservation(result.RemainingOrderID)
+	if err != nil || reservation == nil {
+		t.Fatalf("remaining order reservation = %v, %v, want one", reservation, err)
+	}
+
+	// The milk left is held for the remaining order, a new latte can not take it
+	if _, err := addLatteOrder(s, 1); !errors.Is(err, ErrNotEnoughInventoryQuantity) {
+		t.Errorf("AddOrder() error = %v, want %v", err, ErrNotEnoughInventoryQuantity)
+	}
+}
+
+func TestBackfillReservations(t *testing.T) {
+	lattes := func(id string, status models.OrderStatus, createdAt string) models.Order {
+		return models.Order{ID: id, CustomerName: "Alice", Status: status, CreatedAt: createdAt, Items: []models.OrderItem{{ProductID: "latte", Quantity: 3}}}
+	}
+	s := newTestOrderService(0,
+		lattes("orders1", models.StatusOpen, "2024-01-02T10:05:00Z"),
+		lattes("orders2", models.StatusOpen, "2024-01-02T10:00:00Z"),
+		lattes("orders3", models.StatusClosed, "2024-01-02T09:00:00Z"),
+	)
+
+	tests := []struct {
+		name         string
+		wantReserved int
+	}{
+		// The older open order is reserved first, the stock left is not enough for the other one
+		{name: "first start", wantReserved: 1},
+		{name: "second start", wantReserved: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reserved, err := s.BackfillReservations()
+			if err != nil {
+				t.Fatalf("BackfillReservations() error = %v", err)
+			}
+			if reserved != tt.wantReserved {
+				t.Errorf("BackfillReservations() = %d, want %d", reserved, tt.wantReserved)
+			}
+
+			if reservation, err := s.findReservation("orders2"); err != nil || reservation == nil {
+				t.Errorf("orders2 reservation = %v, %v, want one", reservation, err)
+			}
+			if reservation, err := s.findReservation("orders1"); err != nil || reservation != nil {
+				t.Errorf("orders1 reservation = %v, %v, want none", reservation, err)
+			}
+		})
+	}
+}
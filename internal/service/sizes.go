@@ -0,0 +1,69 @@
+package service
+
+import (
+	"strings"
+
+	"hot-coffee/models"
+)
+
+// menuItemVariant returns the price and recipe of the requested size of a menu item.
+// An empty size selects the base price and ingredients of the item.
+// Returns ErrOrderSizeNotFound if the item has no size with the given name.
+func menuItemVariant(item models.MenuItem, size string) (float64, []models.MenuItemIngredient, error) {
+	size = strings.TrimSpace(size)
+	if size == "" {
+		return item.Price, item.Ingredients, nil
+	}
+
+	for _, variant := range item.Sizes {
+		if strings.EqualFold(variant.Name, size) {
+			return variant.Price, variant.Ingredients, nil
+		}
+	}
+
+	return 0, nil, ErrOrderSizeNotFound
+}
+
+// orderItemPrice returns the price of one order item: the pinned unit price if set,
+// otherwise the current menu price of the selected size.
+func orderItemPrice(item models.OrderItem, menuItem models.MenuItem) (float64, error) {
+	if item.UnitPrice > 0 {
+		return item.UnitPrice, nil
+	}
+
+	price, _, err := menuItemVariant(menuItem, item.Size)
+	return price, err
+}
+
+// clearManagedFields drops client supplied unit prices and recipe snapshots,
+// they are only set when menu prices change and when the order is closed.
+func clearManagedFields(items []models.OrderItem) {
+	for i := range items {
+		items[i].UnitPrice = 0
+		items[i].RecipeSnapshot = nil
+	}
+}
+
+// validateMenuSizes checks that every size variant has a unique name, a positive price and a valid recipe.
+func validateMenuSizes(sizes []models.MenuItemSize) error {
+	for k, size := range sizes {
+		if strings.TrimSpace(size.Name) == "" {
+			return ErrNotValidMenuSize
+		}
+
+		for l, size2 := range sizes {
+			if strings.EqualFold(size.Name, size2.Name) && k != l {
+				return ErrNotValidMenuSize
+			}
+		}
+
+		if size.Price <= 0 {
+			return ErrNotValidPrice
+		}
+
+		if err := ValidateMenuIngredient(size.Ingredients); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+type unitInfo struct {
+	base   string
+	factor float64
+}
+
+// knownUnits maps every convertible unit to its canonical base unit
+var knownUnits = map[string]unitInfo{
+	"g":  {base: "g", factor: 1},
+	"kg": {base: "g", factor: 1000},
+	"ml": {base: "ml", factor: 1},
+	"l":  {base: "ml", factor: 1000},
+}
+
+// allowedUnits are the units inventory items can be stocked in
+var allowedUnits = []string{"g", "kg", "ml", "l", "shots", "units"}
+
+// ValidateUnit checks that the unit, in any casing, is one of the allowed inventory units.
+// Returns ErrNotValidUnit wrapped with the list of allowed units otherwise.
+func ValidateUnit(unit string) error {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	for _, allowed := range allowedUnits {
+		if unit == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: must be one of %s", ErrNotValidUnit, strings.Join(allowedUnits, ", "))
+}
+
+// ConvertQuantity converts a quantity from one unit to another.
+// An empty source unit means the quantity is already expressed in the target unit.
+// Returns ErrIncompatibleUnit if the units can not be converted to each other.
+func ConvertQuantity(quantity float64, from, to string) (float64, error) {
+	from = strings.ToLower(strings.TrimSpace(from))
+	to = strings.ToLower(strings.TrimSpace(to))
+
+	if from == "" || from == to {
+		return quantity, nil
+	}
+
+	fromUnit, fromKnown := knownUnits[from]
+	toUnit, toKnown := knownUnits[to]
+	if !fromKnown || !toKnown || fromUnit.base != toUnit.base {
+		return 0, ErrIncompatibleUnit
+	}
+
+	return quantity * fromUnit.factor / toUnit.factor, nil
+}
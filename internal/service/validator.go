@@ -0,0 +1,69 @@
+package service
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"hot-coffee/models"
+)
+
+// fieldValidator collects the invalid fields of a value. Each validation rule is declared once
+// and serves both the validation returning the first error and the one listing every invalid field.
+type fieldValidator struct {
+	invalid []invalidField
+}
+
+type invalidField struct {
+	field string
+	err   error
+}
+
+// check records err for the field if the field is not valid.
+func (v *fieldValidator) check(valid bool, field string, err error) {
+	if !valid {
+		v.invalid = append(v.invalid, invalidField{field: field, err: err})
+	}
+}
+
+// checkErr records err for the field if it is not nil.
+func (v *fieldValidator) checkErr(field string, err error) {
+	v.check(err == nil, field, err)
+}
+
+// err returns the error of the first invalid field, or nil if every field is valid.
+func (v *fieldValidator) err() error {
+	if len(v.invalid) == 0 {
+		return nil
+	}
+	return v.invalid[0].err
+}
+
+// fieldErrors returns every invalid field with its error message, in the order they were checked.
+func (v *fieldValidator) fieldErrors() []models.FieldError {
+	fieldErrors := []models.FieldError{}
+	for _, invalid := range v.invalid {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: invalid.field, Message: invalid.err.Error()})
+	}
+	return fieldErrors
+}
+
+// isValidID reports whether the ID is not empty and has no spaces.
+func isValidID(id string) bool {
+	return id != "" && !strings.Contains(id, " ")
+}
+
+// isNonNegative reports whether x is a finite number of at least zero.
+func isNonNegative(x float64) bool {
+	return x >= 0 && !math.IsInf(x, 0)
+}
+
+// isPositive reports whether x is a finite number greater than zero.
+func isPositive(x float64) bool {
+	return x > 0 && !math.IsInf(x, 0)
+}
+
+// isAtMost reports whether the text has at most maxLength characters.
+func isAtMost(text string, maxLength int) bool {
+	return utf8.RuneCountInString(text) <= maxLength
+}
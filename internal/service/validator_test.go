@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestIsAtMost(t *testing.T) {
+	tests := []struct {
+		text      string
+		maxLength int
+		want      bool
+	}{
+		{text: "", maxLength: 0, want: true},
+		{text: "latte", maxLength: 5, want: true},
+		{text: "lattes", maxLength: 5, want: false},
+		{text: "café", maxLength: 4, want: true},
+		{text: strings.Repeat("é", maxTagLength), maxLength: maxTagLength, want: true},
+		{text: strings.Repeat("é", maxTagLength+1), maxLength: maxTagLength, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isAtMost(tt.text, tt.maxLength); got != tt.want {
+			t.Errorf("isAtMost(%q, %d) = %v, want %v", tt.text, tt.maxLength, got, tt.want)
+		}
+	}
+}
+
+func TestNumberRules(t *testing.T) {
+	tests := []struct {
+		x               float64
+		wantNonNegative bool
+		wantPositive    bool
+	}{
+		{x: 1, wantNonNegative: true, wantPositive: true},
+		{x: 0.5, wantNonNegative: true, wantPositive: true},
+		{x: 0, wantNonNegative: true, wantPositive: false},
+		{x: -1, wantNonNegative: false, wantPositive: false},
+		{x: math.Inf(1), wantNonNegative: false, wantPositive: false},
+		{x: math.NaN(), wantNonNegative: false, wantPositive: false},
+	}
+
+	for _, tt := range tests {
+		if got := isNonNegative(tt.x); got != tt.wantNonNegative {
+			t.Errorf("isNonNegative(%g) = %v, want %v", tt.x, got, tt.wantNonNegative)
+		}
+		if got := isPositive(tt.x); got != tt.wantPositive {
+			t.Errorf("isPositive(%g) = %v, want %v", tt.x, got, tt.wantPositive)
+		}
+	}
+}
+
+// The validation returning the first error and the one listing every field share their rules,
+// so the first listed field must carry the error the other returns.
+func TestValidateItemParity(t *testing.T) {
+	valid := models.InventoryItem{IngredientID: "milk", Name: "Milk", Quantity: 1000, Unit: "ml"}
+	negative := -1.0
+
+	tests := []struct {
+		name      string
+		change    func(i *models.InventoryItem)
+		wantErr   error
+		wantField string
+	}{
+		{name: "valid", change: func(i *models.InventoryItem) {}},
+		{name: "empty ID", change: func(i *models.InventoryItem) { i.IngredientID = "" }, wantErr: ErrNotValidIngredientID, wantField: "ingredient_id"},
+		{name: "empty name", change: func(i *models.InventoryItem) { i.Name = "" }, wantErr: ErrNotValidIngredientName, wantField: "name"},
+		{name: "negative quantity", change: func(i *models.InventoryItem) { i.Quantity = -1 }, wantErr: ErrNotValidQuantity, wantField: "quantity"},
+		{name: "unknown unit", change: func(i *models.InventoryItem) { i.Unit = "cups" }, wantErr: ErrNotValidUnit, wantField: "unit"},
+		{name: "negative reorder level", change: func(i *models.InventoryItem) { i.ReorderLevel = -1 }, wantErr: ErrNotValidReorderLevel, wantField: "reorder_level"},
+		{name: "negative cost", change: func(i *models.InventoryItem) { i.CostPerUnit = &negative }, wantErr: ErrNotValidCostPerUnit, wantField: "cost_per_unit"},
+		{
+			name:      "several fields",
+			change:    func(i *models.InventoryItem) { i.Name, i.Quantity = "", -1 },
+			wantErr:   ErrNotValidIngredientName,
+			wantField: "name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := valid
+			tt.change(&item)
+
+			err := ValidateItem(item)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateItem() error = %v, want %v", err, tt.wantErr)
+			}
+			assertParity(t, err, ValidateItemCollecting(item), tt.wantField)
+		})
+	}
+}
+
+func TestValidateOrderItemsParity(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     []models.OrderItem
+		wantField string
+	}{
+		{name: "valid", items: []models.OrderItem{{ProductID: "latte", Quantity: 1}}},
+		{name: "no items", wantField: "items"},
+		{name: "invalid product ID", items: []models.OrderItem{{ProductID: "flat white", Quantity: 1}}, wantField: "items[0].product_id"},
+		{name: "negative quantity", items: []models.OrderItem{{ProductID: "latte", Quantity: -1}}, wantField: "items[0].quantity"},
+		{
+			name:      "long instructions",
+			items:     []models.OrderItem{{ProductID: "latte", Quantity: 1, Instructions: strings.Repeat("a", maxNoteLength+1)}},
+			wantField: "items[0].instructions",
+		},
+		{name: "repeated item", items: []models.OrderItem{{ProductID: "latte", Quantity: 1}, {ProductID: "latte", Quantity: 1}}, wantField: "items[1]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := models.Order{CustomerName: "Alice", Items: tt.items}
+			assertParity(t, ValidateOrderItems(tt.items), ValidateOrderCollecting(order), tt.wantField)
+		})
+	}
+}
+
+// assertParity checks that the first field error matches err, and that there are none when err is nil.
+func assertParity(t *testing.T, err error, fieldErrors []models.FieldError, wantField string) {
+	t.Helper()
+
+	if err == nil {
+		if len(fieldErrors) != 0 {
+			t.Errorf("field errors = %+v, want none", fieldErrors)
+		}
+		return
+	}
+	if len(fieldErrors) == 0 {
+		t.Fatalf("no field errors, want %s: %v", wantField, err)
+	}
+	if fieldErrors[0].Field != wantField || fieldErrors[0].Message != err.Error() {
+		t.Errorf("first field error = %+v, want %s: %v", fieldErrors[0], wantField, err)
+	}
+}
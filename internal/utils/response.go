@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
+	"strings"
 
 	"hot-coffee/models"
 	"hot-coffee/pkg/logger"
@@ -21,40 +24,154 @@ func WriteRawJSONResponse(statusCode int, jsonResponse any, w http.ResponseWrite
 	}
 }
 
-// WriteJSONResponse writes a formatted JSON response to the HTTP response writer with a given status code.
-// It sets the Content-Type header to "application/json" and formats the provided jsonResponse with indentation.
+// WriteJSONResponse writes a JSON response to the HTTP response writer with a given status code.
+// It sets the Content-Type header to "application/json" and encodes the provided jsonResponse,
+// compact by default or indented if the request asks for it, see PrettyRequested.
 // If there is an error during JSON formatting, it writes an error response with the internal server error status code.
 func WriteJSONResponse(statusCode int, jsonResponse any, w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-
-	formattedJSON, err := json.MarshalIndent(jsonResponse, "", " ")
+	var data []byte
+	var err error
+	if PrettyRequested(r) {
+		data, err = json.MarshalIndent(jsonResponse, "", " ")
+	} else {
+		data, err = json.Marshal(jsonResponse)
+	}
 	if err != nil {
 		WriteErrorResponse(http.StatusInternalServerError, err, w, r)
 		return
 	}
 
-	w.Write(formattedJSON)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(data)
+}
+
+// WriteJSONBytes writes already encoded JSON to the HTTP response writer with a given status code.
+// The data is written as is, or indented if the request asks for pretty output.
+func WriteJSONBytes(statusCode int, data []byte, w http.ResponseWriter, r *http.Request) {
+	if PrettyRequested(r) {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, data, "", " "); err == nil {
+			data = indented.Bytes()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(data); err != nil {
+		logger.LOGGER.PrintErrorMsg("Failed to write response for %s %s: %v", r.Method, r.URL.Path, err)
+	}
+}
+
+// PrettyRequested reports whether the request asks for indented JSON with the "pretty=true" query parameter.
+// Responses are compact otherwise.
+func PrettyRequested(r *http.Request) bool {
+	return r != nil && r.URL.Query().Get("pretty") == "true"
+}
+
+// errorCoder maps an error to its stable code, see SetErrorCoder.
+var errorCoder = func(err error) string { return "" }
+
+// SetErrorCoder sets the function used by WriteErrorResponse to map errors to their codes.
+// Errors the function returns an empty code for get a code derived from the status code.
+func SetErrorCoder(coder func(err error) string) {
+	errorCoder = coder
+}
+
+// errorCode returns the code of the error, falling back to the upper snake case status text, e.g. "NOT_FOUND".
+func errorCode(statusCode int, err error) string {
+	if code := errorCoder(err); code != "" {
+		return code
+	}
+	return strings.ToUpper(strings.ReplaceAll(http.StatusText(statusCode), " ", "_"))
 }
 
 // WriteErrorResponse writes an error response in JSON format to the HTTP response writer.
 // It logs the error message based on the provided status code and returns a JSON object
-// with the error message in the response body.
+// with the error code and message in the response body.
+// Server errors are logged as errors, conflicts and oversized requests as warnings, the rest as debug.
 func WriteErrorResponse(statusCode int, err error, w http.ResponseWriter, r *http.Request) {
-	if statusCode/100 >= 5 {
-		logger.LOGGER.PrintErrorMsg(err.Error())
-	} else {
-		logger.LOGGER.PrintDebugMsg(err.Error())
+	switch {
+	case statusCode/100 >= 5:
+		logger.LOGGER.PrintErrorMsg("%s", err.Error())
+	case statusCode == http.StatusConflict, statusCode == http.StatusRequestEntityTooLarge:
+		logger.LOGGER.PrintWarnMsg("%s", err.Error())
+	default:
+		logger.LOGGER.PrintDebugMsg("%s", err.Error())
 	}
 
-	errorJSON := &models.ErrorResponse{Error: err.Error()}
+	errorJSON := &models.ErrorResponse{Code: errorCode(statusCode, err), Error: err.Error()}
 
 	WriteJSONResponse(statusCode, errorJSON, w, r)
 }
 
+// WriteValidationErrorResponse writes a 400 Bad Request response listing every invalid field of the request body.
+func WriteValidationErrorResponse(fieldErrors []models.FieldError, w http.ResponseWriter, r *http.Request) {
+	logger.LOGGER.PrintDebugMsg("Request %s %s has %d invalid fields", r.Method, r.URL.Path, len(fieldErrors))
+
+	errorsJSON := &models.ValidationErrorResponse{Errors: fieldErrors}
+	WriteJSONResponse(http.StatusBadRequest, errorsJSON, w, r)
+}
+
 func WriteInfoResponse(statusCode int, message string, w http.ResponseWriter, r *http.Request) {
-	logger.LOGGER.PrintDebugMsg(message)
+	logger.LOGGER.PrintDebugMsg("%s", message)
 
 	infoJSON := &models.InfoResponse{Message: message}
 	WriteJSONResponse(statusCode, infoJSON, w, r)
 }
+
+// WriteJSONArrayStream writes the items as a JSON array, encoding and writing one item at a time
+// instead of buffering the whole array. The output is indented like WriteJSONResponse if the request asks for it.
+// Errors after the status code is sent can only be logged, as the response is already partially written.
+func WriteJSONArrayStream[T any](statusCode int, items []T, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := writeJSONArray(w, items, PrettyRequested(r)); err != nil {
+		logger.LOGGER.PrintErrorMsg("Failed to stream JSON response for %s %s: %v", r.Method, r.URL.Path, err)
+	}
+}
+
+func writeJSONArray[T any](w io.Writer, items []T, pretty bool) error {
+	if len(items) == 0 {
+		_, err := io.WriteString(w, "[]")
+		return err
+	}
+
+	prefix, separator, suffix := "[", ",", "]"
+	if pretty {
+		prefix, separator, suffix = "[\n ", ",\n ", "\n]"
+	}
+
+	if _, err := io.WriteString(w, prefix); err != nil {
+		return err
+	}
+
+	// One buffer is reused for every item, so the memory used does not grow with the number of items
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	if pretty {
+		encoder.SetIndent(" ", " ")
+	}
+
+	for i, item := range items {
+		if i > 0 {
+			if _, err := io.WriteString(w, separator); err != nil {
+				return err
+			}
+		}
+
+		buf.Reset()
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+
+		// Encode terminates every value with a newline, the separators already lay out the array
+		if _, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, suffix)
+	return err
+}
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hot-coffee/models"
+	"hot-coffee/pkg/logger"
+)
+
+func TestWriteJSONArray(t *testing.T) {
+	items := []models.OrderItem{{ProductID: "latte", Quantity: 2}, {ProductID: "muffin", Quantity: 1}}
+
+	tests := []struct {
+		name   string
+		items  []models.OrderItem
+		pretty bool
+	}{
+		{name: "empty", items: []models.OrderItem{}},
+		{name: "nil", items: nil},
+		{name: "compact", items: items},
+		{name: "pretty", items: items, pretty: true},
+		{name: "pretty single", items: items[:1], pretty: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeJSONArray(&buf, tt.items, tt.pretty); err != nil {
+				t.Fatalf("writeJSONArray() error = %v", err)
+			}
+
+			// The stream matches the buffered encoding of WriteJSONResponse byte for byte
+			want := []byte("[]")
+			if len(tt.items) > 0 {
+				var err error
+				if tt.pretty {
+					want, err = json.MarshalIndent(tt.items, "", " ")
+				} else {
+					want, err = json.Marshal(tt.items)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("writeJSONArray() = %s, want %s", buf.Bytes(), want)
+			}
+		})
+	}
+}
+
+// discardResponseWriter is a ResponseWriter dropping the body, so the benchmarks measure the encoding only.
+// It keeps the size of the largest write, which is the most response data held in memory at once.
+type discardResponseWriter struct {
+	header   http.Header
+	maxWrite int
+}
+
+func (w *discardResponseWriter) Header() http.Header        { return w.header }
+func (w *discardResponseWriter) WriteHeader(statusCode int) {}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	w.maxWrite = max(w.maxWrite, len(b))
+	return io.Discard.Write(b)
+}
+
+// BenchmarkOrderListing compares the memory of writing 10k orders as one buffered document
+// with streaming them one order at a time, compact and pretty. Besides the allocations,
+// max-write-B reports the largest piece of the response held in memory at once.
+func BenchmarkOrderListing(b *testing.B) {
+	orders := make([]models.Order, 10000)
+	for i := range orders {
+		orders[i] = models.Order{
+			ID:           fmt.Sprintf("orders%d", i+1),
+			CustomerName: "Customer",
+			Items:        []models.OrderItem{{ProductID: "latte", Quantity: 2}, {ProductID: "muffin", Quantity: 1}},
+			Status:       models.StatusOpen,
+			CreatedAt:    "2026-10-14T08:00:00Z",
+		}
+	}
+
+	for _, query := range []string{"", "?pretty=true"} {
+		r := httptest.NewRequest(http.MethodGet, "/orders"+query, nil)
+
+		b.Run("buffered"+query, func(b *testing.B) {
+			b.ReportAllocs()
+			w := &discardResponseWriter{header: http.Header{}}
+			for i := 0; i < b.N; i++ {
+				WriteJSONResponse(http.StatusOK, orders, w, r)
+			}
+			b.ReportMetric(float64(w.maxWrite), "max-write-B")
+		})
+
+		b.Run("stream"+query, func(b *testing.B) {
+			b.ReportAllocs()
+			w := &discardResponseWriter{header: http.Header{}}
+			for i := 0; i < b.N; i++ {
+				WriteJSONArrayStream(http.StatusOK, orders, w, r)
+			}
+			b.ReportMetric(float64(w.maxWrite), "max-write-B")
+		})
+	}
+}
+
+func TestPrettyResponses(t *testing.T) {
+	value := map[string]int{"quantity": 2}
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{name: "compact by default", target: "/orders", want: `{"quantity":2}`},
+		{name: "pretty", target: "/orders?pretty=true", want: "{\n \"quantity\": 2\n}"},
+		{name: "pretty false", target: "/orders?pretty=false", want: `{"quantity":2}`},
+		{name: "pretty without value", target: "/orders?pretty", want: `{"quantity":2}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+
+			w := httptest.NewRecorder()
+			WriteJSONResponse(http.StatusOK, value, w, r)
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("WriteJSONResponse() wrote %q, want %q", got, tt.want)
+			}
+
+			w = httptest.NewRecorder()
+			WriteJSONBytes(http.StatusOK, []byte(`{"quantity":2}`), w, r)
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("WriteJSONBytes() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteErrorResponseLogsMessageVerbatim(t *testing.T) {
+	original := logger.LOGGER
+	t.Cleanup(func() { logger.LOGGER = original })
+
+	// Percent signs in IDs or user input must not be read as format verbs
+	message := "inventory item '100%_arabica' not found"
+	for _, statusCode := range []int{http.StatusInternalServerError, http.StatusConflict, http.StatusNotFound} {
+		t.Run(http.StatusText(statusCode), func(t *testing.T) {
+			var logs bytes.Buffer
+			logger.LOGGER = logger.New(slog.LevelDebug, logger.FormatText, &logs)
+
+			r := httptest.NewRequest(http.MethodGet, "/inventory/100%25_arabica", nil)
+			WriteErrorResponse(statusCode, errors.New(message), httptest.NewRecorder(), r)
+
+			if !strings.Contains(logs.String(), message) {
+				t.Errorf("log = %q, want it to contain %q", logs.String(), message)
+			}
+		})
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 )
@@ -163,14 +162,45 @@ func CustomUsage() {
 	fmt.Println(`Coffee Shop Management System
 
 Usage:
-  hot-coffee [--port <N>] [--dir <S>] [--cfg <S>]
+  hot-coffee [--port <N>] [--dir <S> | --data-dir <S>] [--cfg <S>] [--max-body <N>] [--log-format <S>]
+             [--log-level <S>] [--log-file <S>] [--log-max-size <N>] [--log-max-backups <N>]
+             [--cors-origins <S>] [--api-key <S>] [--rate-limit <N>] [--rate-burst <N>] [--tax-rate <N>]
+             [--public-metrics] [--read-timeout <D>] [--write-timeout <D>] [--idle-timeout <D>]
   hot-coffee --help
 
 Options:
   --help       Show this screen.
   --port N     Port number.
-  --dir S      Path to the data directory.
-  --cfg S      Path to the config file.`)
+  --dir S      Path to the data directory (default ./data, or $DATA_DIR).
+  --data-dir S Alias of --dir.
+  --cfg S      Path to the config file.
+  --max-body N Maximum request body size in bytes (default 1048576).
+  --log-format S
+               Log output format: text or json (default text).
+  --log-level S
+               Minimum level of the logged messages: error, warn, info or debug (default info).
+  --log-file S Path of a file the logs are also written to (default none).
+  --log-max-size N
+               Size in megabytes at which the log file is rotated (default 10).
+  --log-max-backups N
+               Number of rotated log files to keep (default 5).
+  --cors-origins S
+               Comma separated list of allowed CORS origins (default none).
+  --api-key S  API key required in the X-API-Key header (or $API_KEY).
+               Authentication is disabled when empty.
+  --rate-limit N
+               Requests per second allowed for each client IP (default 10, 0 disables).
+  --rate-burst N
+               Maximum burst of requests for each client IP (default 20).
+  --tax-rate N Tax rate in percent applied to order subtotals (default 0, or $TAX_RATE).
+  --public-metrics
+               Serve GET /metrics without the API key (default false).
+  --read-timeout D
+               Maximum time to read a request, including its body (default 10s, 0 disables).
+  --write-timeout D
+               Maximum time to write a response (default 30s, 0 disables).
+  --idle-timeout D
+               Maximum time an idle keep-alive connection is kept open (default 2m, 0 disables).`)
 }
 
 // ValidatePort checks if the provided port string is a valid number
@@ -189,46 +219,14 @@ func ValidatePort(port string) error {
 	return nil
 }
 
-// ValidateDirName() checks the name of a single directory element.
-func ValidateDirName(name string) error {
-	validName := regexp.MustCompile(`^[a-z0-9_-]+$`)
-
-	if !validName.MatchString(name) {
-		return errors.New("invalid directory name: use only lowercase letters, digits, hyphens, and underscores")
-	}
-
-	for _, r := range name {
-		if r > 127 {
-			return errors.New("invalid characters in directory name: use ASCII (Latin) characters only")
-		}
-	}
-
-	return nil
-}
-
-// ValidatePath checks the entire path by validating each directory element.
-func ValidatePath(path string) error {
-	cleanPath := filepath.Clean(path)
-
-	dirs := strings.Split(cleanPath, "/")
-	for _, dir := range dirs {
-		if dir == "" {
-			continue
-		}
-
-		if err := ValidateDirName(dir); err != nil {
-			return fmt.Errorf("invalid path element '%s': %w", dir, err)
-		}
-	}
-
-	return nil
-}
-
-// ValidateDir checks if the directory exists and creates it if necessary.
+// ValidateDir checks that the path is a directory, creating it and its parents if it does not exist.
+// Any path the OS accepts is allowed, e.g. a volume mounted at /mnt/Data.1; the writability
+// is checked by ValidateDirWritable.
 func ValidateDir(dir string) error {
-	if err := ValidatePath(dir); err != nil {
-		return fmt.Errorf("path validation error: %w", err)
+	if strings.TrimSpace(dir) == "" {
+		return errors.New("data directory can not be empty")
 	}
+	dir = filepath.Clean(dir)
 
 	info, err := os.Stat(dir)
 	if err != nil {
@@ -242,7 +240,24 @@ func ValidateDir(dir string) error {
 	}
 
 	if !info.IsDir() {
-		return errors.New("the specified path is not a directory")
+		return fmt.Errorf("the specified path '%s' is not a directory", dir)
+	}
+
+	return nil
+}
+
+// ValidateDirWritable checks that files can be created in the given directory.
+func ValidateDirWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("data directory '%s' is not writable: %w", dir, err)
+	}
+
+	name := file.Name()
+	file.Close()
+
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("data directory '%s' is not writable: %w", dir, err)
 	}
 
 	return nil
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateDir(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{
+		{name: "existing directory", dir: root},
+		{name: "created with its parents", dir: filepath.Join(root, "a", "b")},
+		{name: "uppercase and dots", dir: filepath.Join(root, "Mnt", "Data.1")},
+		{name: "spaces", dir: filepath.Join(root, "coffee data")},
+		{name: "unclean path", dir: root + "/x/../y//"},
+		{name: "file", dir: file, wantErr: true},
+		{name: "below a file", dir: filepath.Join(file, "data"), wantErr: true},
+		{name: "empty", dir: "", wantErr: true},
+		{name: "blank", dir: "  ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDir(tt.dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateDir(%q) error = %v, want error %v", tt.dir, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// The server uses the cleaned path, as main does
+			dir := filepath.Clean(tt.dir)
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				t.Errorf("%q is not a directory after ValidateDir: %v", dir, err)
+			}
+			if err := ValidateDirWritable(dir); err != nil {
+				t.Errorf("ValidateDirWritable(%q) error = %v", dir, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+package models
+
+type AverageFulfillmentTime struct {
+	AverageSeconds float64 `json:"average_fulfillment_seconds"`
+	ClosedOrders   int     `json:"closed_orders"`
+}
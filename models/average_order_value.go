@@ -0,0 +1,6 @@
+package models
+
+type AverageOrderValue struct {
+	AverageOrderValue float64 `json:"average_order_value"`
+	ClosedOrders      int     `json:"closed_orders"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// Backup describes a copy of the data files taken by POST /admin/backup.
+type Backup struct {
+	Path      string   `json:"path"`
+	Files     []string `json:"files"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// Restore reports the backup the data files were restored from and the records loaded afterwards.
+type Restore struct {
+	From string `json:"from"`
+
+	// PreviousBackup is the backup of the data that was replaced by the restore
+	PreviousBackup string        `json:"previous_backup"`
+	Loaded         ReloadSummary `json:"loaded"`
+}
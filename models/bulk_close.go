@@ -0,0 +1,24 @@
+package models
+
+// Results of closing an order in a bulk close
+const (
+	BulkCloseClosed       = "closed"
+	BulkCloseInsufficient = "insufficient"
+	BulkCloseFailed       = "failed"
+)
+
+type BulkClose struct {
+	Closed       int               `json:"closed"`
+	Insufficient int               `json:"insufficient"`
+	Failed       int               `json:"failed"`
+	Orders       []BulkCloseResult `json:"orders"`
+}
+
+type BulkCloseResult struct {
+	OrderID string `json:"order_id"`
+	Status  string `json:"status"`
+
+	// Code and Error describe why a failed order could not be closed
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+}
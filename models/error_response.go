@@ -1,5 +1,7 @@
 package models
 
 type ErrorResponse struct {
+	// Code is a stable machine-readable identifier of the error, e.g. "ORDER_NOT_FOUND"
+	Code  string `json:"code"`
 	Error string `json:"error"`
 }
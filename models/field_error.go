@@ -0,0 +1,11 @@
+package models
+
+// FieldError describes a single invalid field of a request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationErrorResponse struct {
+	Errors []FieldError `json:"errors"`
+}
@@ -0,0 +1,13 @@
+package models
+
+type ImportSummary struct {
+	Inserted int           `json:"inserted"`
+	Updated  int           `json:"updated"`
+	Failed   int           `json:"failed"`
+	Errors   []ImportError `json:"errors"`
+}
+
+type ImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
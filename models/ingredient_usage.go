@@ -0,0 +1,14 @@
+package models
+
+type IngredientUsage struct {
+	IngredientID string  `json:"ingredient_id"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"`
+}
+
+// MenuIngredientUsage lists the menu items whose recipes use an ingredient.
+type MenuIngredientUsage struct {
+	IngredientID  string   `json:"ingredient_id"`
+	MenuItemCount int      `json:"menu_item_count"`
+	MenuItems     []string `json:"menu_items"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// InventoryAdjustment is an audit record of a manual stock change.
+type InventoryAdjustment struct {
+	IngredientID  string  `json:"ingredient_id"`
+	Delta         float64 `json:"delta"`
+	Reason        string  `json:"reason"`
+	QuantityAfter float64 `json:"quantity_after"`
+	CreatedAt     string  `json:"created_at"`
+}
+
+type InventoryAdjustmentRequest struct {
+	Delta  *float64 `json:"delta"`
+	Reason string   `json:"reason"`
+}
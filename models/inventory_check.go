@@ -0,0 +1,17 @@
+package models
+
+type InventoryCheck struct {
+	Sufficient bool       `json:"sufficient"`
+	Shortages  []Shortage `json:"shortages"`
+
+	// ProjectedInventory is the inventory left available after closing the order, without the stock
+	// reserved by open orders, set only when it is sufficient
+	ProjectedInventory []InventoryItem `json:"projected_inventory,omitempty"`
+}
+
+type Shortage struct {
+	IngredientID string  `json:"ingredient_id"`
+	Required     float64 `json:"required"`
+	Available    float64 `json:"available"`
+	Missing      float64 `json:"missing"`
+}
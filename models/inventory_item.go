@@ -1,8 +1,14 @@
 package models
 
 type InventoryItem struct {
+	// IngredientID is matched exactly on lookups, but must be unique ignoring case,
+	// so "milk" and "Milk" can not both be in the inventory
 	IngredientID string  `json:"ingredient_id"`
 	Name         string  `json:"name"`
 	Quantity     float64 `json:"quantity"`
 	Unit         string  `json:"unit"`
+	ReorderLevel float64 `json:"reorder_level,omitempty"`
+	Category     string  `json:"category,omitempty"`
+	// CostPerUnit is nil for items that have no cost set, a cost of zero is a free item
+	CostPerUnit *float64 `json:"cost_per_unit,omitempty"`
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInventoryItemCostPerUnit(t *testing.T) {
+	free, cost := 0.0, 0.5
+	tests := []struct {
+		name     string
+		data     string
+		wantCost *float64
+	}{
+		{name: "unset", data: `{"ingredient_id":"milk"}`},
+		{name: "zero", data: `{"ingredient_id":"milk","cost_per_unit":0}`, wantCost: &free},
+		{name: "set", data: `{"ingredient_id":"milk","cost_per_unit":0.5}`, wantCost: &cost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var item InventoryItem
+			if err := json.Unmarshal([]byte(tt.data), &item); err != nil {
+				t.Fatal(err)
+			}
+
+			// The cost must survive a round trip through the saved JSON
+			data, err := json.Marshal(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var saved InventoryItem
+			if err := json.Unmarshal(data, &saved); err != nil {
+				t.Fatal(err)
+			}
+
+			if (saved.CostPerUnit == nil) != (tt.wantCost == nil) || saved.CostPerUnit != nil && *saved.CostPerUnit != *tt.wantCost {
+				t.Errorf("CostPerUnit = %v, want %v", saved.CostPerUnit, tt.wantCost)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+// Inventory lookup statuses
+const (
+	LookupFound    = "found"
+	LookupNotFound = "not_found"
+)
+
+type InventoryLookup struct {
+	IngredientID string         `json:"ingredient_id"`
+	Status       string         `json:"status"`
+	Item         *InventoryItem `json:"item,omitempty"`
+}
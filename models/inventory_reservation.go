@@ -0,0 +1,14 @@
+package models
+
+// InventoryReservation is the stock held for an open order until the order is closed or deleted.
+// The quantities are in the units of the inventory items.
+type InventoryReservation struct {
+	OrderID     string               `json:"order_id"`
+	Ingredients []ReservedIngredient `json:"ingredients"`
+	ReservedAt  string               `json:"reserved_at"`
+}
+
+type ReservedIngredient struct {
+	IngredientID string  `json:"ingredient_id"`
+	Quantity     float64 `json:"quantity"`
+}
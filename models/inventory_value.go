@@ -0,0 +1,16 @@
+package models
+
+// InventoryValue is the value of the stock at cost, items without a cost per unit are left out of the total.
+type InventoryValue struct {
+	TotalValue    float64              `json:"total_value"`
+	Items         []InventoryItemValue `json:"items"`
+	UncostedItems []string             `json:"uncosted_items"`
+}
+
+type InventoryItemValue struct {
+	IngredientID string  `json:"ingredient_id"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"`
+	CostPerUnit  float64 `json:"cost_per_unit"`
+	Value        float64 `json:"value"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// ItemRevenue is the revenue of a product across the closed orders, before discounts and tax.
+// Name is empty for products no longer on the menu.
+type ItemRevenue struct {
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name,omitempty"`
+	UnitsSold float64 `json:"units_sold"`
+	Revenue   float64 `json:"revenue"`
+}
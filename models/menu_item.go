@@ -1,14 +1,77 @@
 package models
 
+import "encoding/json"
+
 type MenuItem struct {
 	ID          string               `json:"product_id"`
 	Name        string               `json:"name"`
 	Description string               `json:"description"`
 	Price       float64              `json:"price"`
 	Ingredients []MenuItemIngredient `json:"ingredients"`
+	Available   bool                 `json:"available"`
+	Sizes       []MenuItemSize       `json:"sizes,omitempty"`
+	Components  []MenuItemComponent  `json:"components,omitempty"`
+	// SoldByWeight allows the item to be ordered in fractional quantities
+	SoldByWeight bool `json:"sold_by_weight,omitempty"`
+}
+
+// IsCombo reports whether the menu item is a combo of other menu items.
+func (m MenuItem) IsCombo() bool {
+	return len(m.Components) > 0
+}
+
+// UnmarshalJSON decodes a menu item, treating items without the "available" field as available.
+func (m *MenuItem) UnmarshalJSON(data []byte) error {
+	type menuItem MenuItem
+
+	item := menuItem{Available: true}
+	if err := json.Unmarshal(data, &item); err != nil {
+		return err
+	}
+
+	*m = MenuItem(item)
+	return nil
 }
 
 type MenuItemIngredient struct {
 	IngredientID string  `json:"ingredient_id"`
 	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit,omitempty"`
+}
+
+// MenuItemSize is a size variant of a menu item with its own price and recipe.
+type MenuItemSize struct {
+	Name        string               `json:"name"`
+	Price       float64              `json:"price"`
+	Ingredients []MenuItemIngredient `json:"ingredients"`
+}
+
+// MenuItemComponent is a menu item included in a combo, Quantity times.
+type MenuItemComponent struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// MenuPriceUpdate is a single entry of a bulk menu price update.
+type MenuPriceUpdate struct {
+	ID    string  `json:"id"`
+	Price float64 `json:"price"`
+}
+
+type MenuPriceUpdateResult struct {
+	ID       string  `json:"id"`
+	OldPrice float64 `json:"old_price,omitempty"`
+	NewPrice float64 `json:"new_price"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// MenuPriceUpdateResponse reports the outcome of a bulk price update.
+// Updated is false if any entry was rejected, in which case no prices were changed.
+type MenuPriceUpdateResponse struct {
+	Updated bool                    `json:"updated"`
+	Results []MenuPriceUpdateResult `json:"results"`
+}
+
+type MenuItemAvailability struct {
+	Available *bool `json:"available"`
 }
@@ -1,14 +1,100 @@
 package models
 
+import "strings"
+
+// OrderStatus is the lifecycle state of an order
+type OrderStatus string
+
+const (
+	StatusOpen   OrderStatus = "open"
+	StatusClosed OrderStatus = "closed"
+)
+
+// Normalize returns the status in its canonical lower case form.
+func (s OrderStatus) Normalize() OrderStatus {
+	return OrderStatus(strings.ToLower(strings.TrimSpace(string(s))))
+}
+
+// IsValid reports whether the status, in any casing, is a known order status.
+func (s OrderStatus) IsValid() bool {
+	switch s.Normalize() {
+	case StatusOpen, StatusClosed:
+		return true
+	}
+	return false
+}
+
 type Order struct {
 	ID           string      `json:"order_id"`
 	CustomerName string      `json:"customer_name"`
 	Items        []OrderItem `json:"items"`
-	Status       string      `json:"status"`
+	Status       OrderStatus `json:"status"`
 	CreatedAt    string      `json:"created_at"`
+	UpdatedAt    string      `json:"updated_at,omitempty"`
+	ClosedAt     string      `json:"closed_at,omitempty"`
+	Notes        string      `json:"notes,omitempty"`
+	Tags         []string    `json:"tags,omitempty"`
+	Priority     int         `json:"priority,omitempty"`
+	Discount     *Discount   `json:"discount,omitempty"`
+
+	// HistoryID is the stable key of the order history, set on create and kept when the order is renamed,
+	// so the history follows the order. Orders created before it have their history keyed by their ID.
+	// It is managed by the server and ignored on input.
+	HistoryID string `json:"history_id,omitempty"`
+
+	// TaxRate is the tax in percent the order was closed with, so a later change of the tax rate
+	// does not change its totals. It is unset for open orders and for orders closed before the rate
+	// was recorded, the current rate applies to them. It is managed by the server and ignored on input.
+	TaxRate *float64 `json:"tax_rate,omitempty"`
+
+	// The price breakdown is derived from the menu prices on read and never persisted.
+	// TotalPrice is the Subtotal minus the DiscountAmount plus the Tax.
+	Subtotal       float64 `json:"subtotal,omitempty"`
+	DiscountAmount float64 `json:"discount_amount,omitempty"`
+	Tax            float64 `json:"tax,omitempty"`
+	TotalPrice     float64 `json:"total_price,omitempty"`
+
+	// FulfillmentSeconds is the time from CreatedAt to ClosedAt, set on read for closed orders only
+	FulfillmentSeconds *int64 `json:"fulfillment_seconds,omitempty"`
+}
+
+// DiscountType selects how the discount value is applied to the order subtotal
+type DiscountType string
+
+const (
+	DiscountPercentage DiscountType = "percentage"
+	DiscountFixed      DiscountType = "fixed"
+)
+
+// Discount is a reduction of the order subtotal, either a percentage of it or a fixed amount.
+type Discount struct {
+	Type  DiscountType `json:"type"`
+	Value float64      `json:"value"`
 }
 
 type OrderItem struct {
-	ProductID string `json:"product_id"`
-	Quantity  int    `json:"quantity"`
+	ProductID    string  `json:"product_id"`
+	Quantity     float64 `json:"quantity"`
+	Size         string  `json:"size,omitempty"`
+	Instructions string  `json:"instructions,omitempty"`
+
+	// UnitPrice pins the price of one item when the menu price changes after the order was placed,
+	// and is saved when the order is closed so later price changes do not change the reports.
+	// Zero means the current menu price applies. It is managed by the server and ignored on input.
+	UnitPrice float64 `json:"unit_price,omitempty"`
+
+	// RecipeSnapshot is the recipe the item was made with, combos resolved, saved when the order is closed
+	// so later recipe changes do not change the reports. It is managed by the server and ignored on input.
+	RecipeSnapshot []MenuItemIngredient `json:"recipe_snapshot,omitempty"`
+}
+
+// OrderPatch is a partial update of an order, only the fields that are set are changed.
+// ID, Status and CreatedAt are decoded only to reject attempts to change them.
+type OrderPatch struct {
+	CustomerName *string `json:"customer_name"`
+	Notes        *string `json:"notes"`
+
+	ID        *string      `json:"order_id"`
+	Status    *OrderStatus `json:"status"`
+	CreatedAt *string      `json:"created_at"`
 }
@@ -0,0 +1,32 @@
+package models
+
+import "encoding/json"
+
+// Order audit actions
+const (
+	AuditCreated = "created"
+	AuditUpdated = "updated"
+	AuditClosed  = "closed"
+	AuditDeleted = "deleted"
+)
+
+// OrderAuditEntry is an audit record of a change to an order.
+// OrderID is the ID of the order at the time of the change, HistoryID the key of its history,
+// which stays the same when the order is renamed. Entries recorded before the key are keyed by OrderID.
+// Actor is who made the change, the X-Actor header of the request or the client address.
+type OrderAuditEntry struct {
+	OrderID   string             `json:"order_id"`
+	HistoryID string             `json:"history_id,omitempty"`
+	Action    string             `json:"action"`
+	Actor     string             `json:"actor,omitempty"`
+	Changes   []OrderFieldChange `json:"changes"`
+	CreatedAt string             `json:"created_at"`
+}
+
+// OrderFieldChange is the old and new JSON value of a changed order field.
+// Old is omitted for fields set by the change, New for fields removed by it.
+type OrderFieldChange struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old,omitempty"`
+	New   json.RawMessage `json:"new,omitempty"`
+}
@@ -0,0 +1,7 @@
+package models
+
+type OrderCounts struct {
+	Open   int `json:"open"`
+	Closed int `json:"closed"`
+	Total  int `json:"total"`
+}
@@ -0,0 +1,9 @@
+package models
+
+type OrderExport struct {
+	ID           string  `json:"order_id"`
+	CustomerName string  `json:"customer_name"`
+	Status       string  `json:"status"`
+	CreatedAt    string  `json:"created_at"`
+	TotalPrice   float64 `json:"total_price"`
+}
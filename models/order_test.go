@@ -0,0 +1,31 @@
+package models
+
+import "testing"
+
+func TestOrderStatus(t *testing.T) {
+	tests := []struct {
+		status    OrderStatus
+		want      OrderStatus
+		wantValid bool
+	}{
+		{status: "open", want: StatusOpen, wantValid: true},
+		{status: "Open", want: StatusOpen, wantValid: true},
+		{status: " OPEN ", want: StatusOpen, wantValid: true},
+		{status: "closed", want: StatusClosed, wantValid: true},
+		{status: "Closed", want: StatusClosed, wantValid: true},
+		{status: "cLoSeD", want: StatusClosed, wantValid: true},
+		{status: "pending", want: "pending", wantValid: false},
+		{status: "", want: "", wantValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			if got := tt.status.Normalize(); got != tt.want {
+				t.Errorf("Normalize() = %q, want %q", got, tt.want)
+			}
+			if got := tt.status.IsValid(); got != tt.wantValid {
+				t.Errorf("IsValid() = %v, want %v", got, tt.wantValid)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+package models
+
+type OrderVolume struct {
+	Period string `json:"period"`
+	Count  int    `json:"count"`
+}
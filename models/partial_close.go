@@ -0,0 +1,6 @@
+package models
+
+type PartialClose struct {
+	ClosedOrderID    string `json:"closed_order_id"`
+	RemainingOrderID string `json:"remaining_order_id,omitempty"`
+}
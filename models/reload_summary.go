@@ -0,0 +1,11 @@
+package models
+
+// ReloadSummary reports the number of records loaded from each data file.
+type ReloadSummary struct {
+	InventoryItems int `json:"inventory_items"`
+	MenuItems      int `json:"menu_items"`
+	Orders         int `json:"orders"`
+	Adjustments    int `json:"adjustments"`
+	OrderHistory   int `json:"order_history"`
+	Reservations   int `json:"reservations"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// RequirementsRequest selects the orders and order items to build a prep list for.
+type RequirementsRequest struct {
+	OrderIDs []string    `json:"order_ids"`
+	Items    []OrderItem `json:"items"`
+}
+
+type Requirements struct {
+	Sufficient  bool                    `json:"sufficient"`
+	Ingredients []IngredientRequirement `json:"ingredients"`
+}
+
+type IngredientRequirement struct {
+	IngredientID string  `json:"ingredient_id"`
+	Unit         string  `json:"unit"`
+	Required     float64 `json:"required"`
+	Available    float64 `json:"available"`
+	Short        bool    `json:"short"`
+	Missing      float64 `json:"missing,omitempty"`
+}
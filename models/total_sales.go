@@ -1,5 +1,9 @@
 package models
 
+// TotalSales is the revenue of closed orders, TotalSales is the Subtotal minus the Discounts plus the Tax.
 type TotalSales struct {
+	Subtotal   float64 `json:"subtotal"`
+	Discounts  float64 `json:"discounts"`
+	Tax        float64 `json:"tax"`
 	TotalSales float64 `json:"total_sales"`
 }
@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// bracketHandler is a slog.Handler writing the text format: the local time, the bracketed level
+// and the message, followed by the attributes as key=value pairs.
+//
+//	2024/01/02 10:00:00 [INFO] Starting server on port 8080
+type bracketHandler struct {
+	out   io.Writer
+	mu    *sync.Mutex
+	level slog.Leveler
+	attrs []byte
+	group string
+}
+
+func newBracketHandler(out io.Writer, opts *slog.HandlerOptions) *bracketHandler {
+	h := &bracketHandler{out: out, mu: &sync.Mutex{}, level: slog.LevelInfo}
+	if opts != nil && opts.Level != nil {
+		h.level = opts.Level
+	}
+	return h
+}
+
+func (h *bracketHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *bracketHandler) Handle(_ context.Context, r slog.Record) error {
+	buf := bytes.Buffer{}
+	if !r.Time.IsZero() {
+		buf.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	}
+	buf.WriteString(levelPrefix(r.Level))
+	buf.WriteByte(' ')
+	buf.WriteString(r.Message)
+	buf.Write(h.attrs)
+	r.Attrs(func(attr slog.Attr) bool {
+		h.appendAttr(&buf, h.group, attr)
+		return true
+	})
+	buf.WriteByte('\n')
+
+	// One write per record, so concurrent records are never interleaved
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.out.Write(buf.Bytes())
+	return err
+}
+
+func (h *bracketHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	buf := bytes.NewBuffer(bytes.Clone(h.attrs))
+	for _, attr := range attrs {
+		h.appendAttr(buf, h.group, attr)
+	}
+
+	scoped := *h
+	scoped.attrs = buf.Bytes()
+	return &scoped
+}
+
+func (h *bracketHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	scoped := *h
+	scoped.group = joinGroup(h.group, name)
+	return &scoped
+}
+
+// appendAttr writes the attribute as " key=value", with the key prefixed by its groups.
+func (h *bracketHandler) appendAttr(buf *bytes.Buffer, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, member := range attr.Value.Group() {
+			h.appendAttr(buf, joinGroup(group, attr.Key), member)
+		}
+		return
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(joinGroup(group, attr.Key))
+	buf.WriteByte('=')
+	buf.WriteString(attr.Value.String())
+}
+
+func joinGroup(group, key string) string {
+	if group == "" {
+		return key
+	}
+	if key == "" {
+		return group
+	}
+	return group + "." + key
+}
+
+// levelPrefix returns the bracketed name of the level, e.g. [INFO].
+func levelPrefix(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "[ERROR]"
+	case level >= slog.LevelWarn:
+		return "[WARN]"
+	case level >= slog.LevelInfo:
+		return "[INFO]"
+	default:
+		return "[DEBUG]"
+	}
+}
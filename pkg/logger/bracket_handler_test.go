@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBracketHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		level slog.Level
+		log   func(l *Logger)
+		want  []string
+	}{
+		{
+			name:  "formatted message",
+			level: slog.LevelInfo,
+			log:   func(l *Logger) { l.PrintInfoMsg("Starting server on port %s", "8080") },
+			want:  []string{"[INFO] Starting server on port 8080"},
+		},
+		{
+			name:  "every level",
+			level: slog.LevelDebug,
+			log: func(l *Logger) {
+				l.PrintDebugMsg("debug")
+				l.PrintInfoMsg("info")
+				l.PrintWarnMsg("warn")
+				l.PrintErrorMsg("error")
+			},
+			want: []string{"[DEBUG] debug", "[INFO] info", "[WARN] warn", "[ERROR] error"},
+		},
+		{
+			name:  "below the level",
+			level: slog.LevelWarn,
+			log: func(l *Logger) {
+				l.PrintInfoMsg("dropped")
+				l.PrintWarnMsg("kept")
+			},
+			want: []string{"[WARN] kept"},
+		},
+		{
+			name:  "attributes",
+			level: slog.LevelInfo,
+			log: func(l *Logger) {
+				l.slogger.With("request_id", "abc").WithGroup("http").Info("done", "status", 200)
+			},
+			want: []string{"[INFO] done request_id=abc http.status=200"},
+		},
+	}
+
+	timestamp := regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := &Logger{level: tt.level, slogger: slog.New(newBracketHandler(&buf, &slog.HandlerOptions{Level: tt.level}))}
+			tt.log(l)
+
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if buf.Len() == 0 {
+				lines = nil
+			}
+			if len(lines) != len(tt.want) {
+				t.Fatalf("logged %d lines, want %d:\n%s", len(lines), len(tt.want), buf.String())
+			}
+			for i, line := range lines {
+				if !timestamp.MatchString(line) {
+					t.Errorf("line %q does not start with a timestamp", line)
+				}
+				if got := timestamp.ReplaceAllString(line, ""); got != tt.want[i] {
+					t.Errorf("line %d = %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBracketHandlerConcurrent(t *testing.T) {
+	var buf bytes.Buffer
+	l := &Logger{level: slog.LevelInfo, slogger: slog.New(newBracketHandler(&buf, nil))}
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.PrintInfoMsg("message")
+		}()
+	}
+	wg.Wait()
+
+	if got := strings.Count(buf.String(), "[INFO] message\n"); got != writers {
+		t.Errorf("logged %d complete lines, want %d", got, writers)
+	}
+}
@@ -1,12 +1,21 @@
 package logger
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 )
 
-// ? TODO: Save logs to the ./logs/triple-s.log path (OPTIONAL)
+// Supported output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
 
 type iLogger interface {
 	PrintInfoMsg(mes string, args ...interface{})
@@ -17,60 +26,94 @@ type iLogger interface {
 }
 
 type Logger struct {
-	debugMode    bool
-	bracketsMode bool
+	level   slog.Level
+	slogger *slog.Logger
 }
 
 var LOGGER *Logger
 
-func InitLogger(enableDebug, enableInfo bool) {
-	LOGGER = NewLogger(enableDebug, enableInfo)
+func InitLogger(level slog.Level, format string, outputs ...io.Writer) {
+	LOGGER = New(level, format, outputs...)
 }
 
-func NewLogger(debugMode bool, bracketsMode bool) *Logger {
-	return &Logger{
-		debugMode:    debugMode,
-		bracketsMode: bracketsMode,
+// ParseLevel parses a log level name: error, warn, info or debug, in any casing.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "error":
+		return slog.LevelError, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
 	}
+	return 0, fmt.Errorf("unknown log level '%s': must be error, warn, info or debug", name)
 }
 
-func printfMsg(level string, mes string, args ...interface{}) {
-	log.Printf(level+" "+mes, args...)
+// New creates a logger writing the messages of the given level and above in the given format to stdout.
+// The "json" format writes machine-parseable records through slog.NewJSONHandler,
+// any other value falls back to the "text" format, written by a slog handler with bracketed level prefixes.
+// The messages are also written to every additional output, such as a RotatingFile.
+func New(level slog.Level, format string, outputs ...io.Writer) *Logger {
+	out := io.MultiWriter(append([]io.Writer{os.Stdout}, outputs...)...)
+	opts := &slog.HandlerOptions{Level: level}
+
+	if format == FormatJSON {
+		return &Logger{level: level, slogger: slog.New(slog.NewJSONHandler(out, opts))}
+	}
+	return &Logger{level: level, slogger: slog.New(newBracketHandler(out, opts))}
 }
 
-func (l *Logger) PrintInfoMsg(mes string, args ...interface{}) {
-	if l.bracketsMode {
-		printfMsg("[INFO]", mes, args...)
+// NewLogger creates a logger at the debug level if debugMode is set and at the info level otherwise.
+//
+// Deprecated: use New with a log level.
+func NewLogger(debugMode bool, bracketsMode bool) *Logger {
+	level := slog.LevelInfo
+	if debugMode {
+		level = slog.LevelDebug
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	if bracketsMode {
+		return &Logger{level: level, slogger: slog.New(newBracketHandler(os.Stdout, opts))}
+	}
+	return &Logger{level: level, slogger: slog.New(slog.NewTextHandler(os.Stdout, opts))}
+}
+
+// printMsg formats the message and writes it through the slog handler of the format.
+// Messages below the level of the logger are dropped before they are formatted.
+func (l *Logger) printMsg(level slog.Level, mes string, args ...interface{}) {
+	if level < l.level {
 		return
 	}
-	slog.Info(mes, args...)
+	l.slogger.Log(context.Background(), level, fmt.Sprintf(mes, args...))
+}
+
+func (l *Logger) PrintInfoMsg(mes string, args ...interface{}) {
+	l.printMsg(slog.LevelInfo, mes, args...)
 }
 
 func (l *Logger) PrintDebugMsg(mes string, args ...interface{}) {
-	if l.debugMode {
-		printfMsg("[DEBUG]", mes, args...)
-	}
+	l.printMsg(slog.LevelDebug, mes, args...)
 }
 
 func (l *Logger) PrintErrorMsg(mes string, args ...interface{}) {
-	if l.bracketsMode {
-		printfMsg("[ERROR]", mes, args...)
-		return
-	}
-	slog.Error(mes, args...)
+	l.printMsg(slog.LevelError, mes, args...)
 }
 
 func (l *Logger) PrintWarnMsg(mes string, args ...interface{}) {
-	if l.bracketsMode {
-		printfMsg("[WARN]", mes, args...)
-		return
-	}
-	slog.Warn(mes, args...)
+	l.printMsg(slog.LevelWarn, mes, args...)
 }
 
+// LogRequestMiddleware logs the method, path, response status and latency of every request.
 func (l *Logger) LogRequestMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("[INFO] Request %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
-		next.ServeHTTP(w, r)
+		start := time.Now()
+		recorder := newStatusRecorder(w)
+
+		next.ServeHTTP(recorder, r)
+
+		l.PrintInfoMsg("Request %s %s %d %s from %s", r.Method, r.URL.Path, recorder.Status(), time.Since(start), r.RemoteAddr)
 	})
 }
@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: FormatText, want: "[INFO] order created"},
+		{format: FormatJSON, want: `"level":"INFO","msg":"order created"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			// Both formats go to stdout, which is replaced by a pipe for the test
+			stdout := os.Stdout
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			os.Stdout = w
+			defer func() { os.Stdout = stdout }()
+
+			var extra bytes.Buffer
+			l := New(slog.LevelInfo, tt.format, &extra)
+			l.PrintInfoMsg("order %s", "created")
+			l.PrintDebugMsg("not written")
+
+			w.Close()
+			written, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, got := range map[string]string{"stdout": string(written), "output": extra.String()} {
+				if !strings.Contains(got, tt.want) || strings.Contains(got, "not written") {
+					t.Errorf("%s = %q, want one record containing %q", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{name: "error", want: slog.LevelError},
+		{name: "warn", want: slog.LevelWarn},
+		{name: "info", want: slog.LevelInfo},
+		{name: "debug", want: slog.LevelDebug},
+		{name: " DEBUG ", want: slog.LevelDebug},
+		{name: "Warn", want: slog.LevelWarn},
+		{name: "warning", wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLevel(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  []string
+	}{
+		{level: slog.LevelError, want: []string{"ERROR"}},
+		{level: slog.LevelWarn, want: []string{"WARN", "ERROR"}},
+		{level: slog.LevelInfo, want: []string{"INFO", "WARN", "ERROR"}},
+		{level: slog.LevelDebug, want: []string{"DEBUG", "INFO", "WARN", "ERROR"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			l := &Logger{level: tt.level, slogger: slog.New(newBracketHandler(&buf, &slog.HandlerOptions{Level: tt.level}))}
+
+			l.PrintDebugMsg("message")
+			l.PrintInfoMsg("message")
+			l.PrintWarnMsg("message")
+			l.PrintErrorMsg("message")
+
+			got := []string{}
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				start, end := strings.Index(line, "["), strings.Index(line, "]")
+				if start >= 0 && end > start {
+					got = append(got, line[start+1:end])
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("written levels = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLoggerDebugMode(t *testing.T) {
+	tests := []struct {
+		debugMode bool
+		want      slog.Level
+	}{
+		{debugMode: true, want: slog.LevelDebug},
+		{debugMode: false, want: slog.LevelInfo},
+	}
+
+	for _, tt := range tests {
+		if got := NewLogger(tt.debugMode, true).level; got != tt.want {
+			t.Errorf("NewLogger(%v) level = %v, want %v", tt.debugMode, got, tt.want)
+		}
+	}
+}
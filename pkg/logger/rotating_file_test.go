@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	line := strings.Repeat("x", 9) + "\n"
+
+	tests := []struct {
+		name        string
+		maxBackups  int
+		writes      int
+		wantFiles   []string
+		wantMissing []string
+	}{
+		{name: "under the limit", maxBackups: 2, writes: 3, wantFiles: []string{"app.log"}, wantMissing: []string{"app.log.1"}},
+		{name: "one rotation", maxBackups: 2, writes: 5, wantFiles: []string{"app.log", "app.log.1"}, wantMissing: []string{"app.log.2"}},
+		{name: "oldest removed", maxBackups: 2, writes: 16, wantFiles: []string{"app.log", "app.log.1", "app.log.2"}, wantMissing: []string{"app.log.3"}},
+		{name: "no backups kept", maxBackups: 0, writes: 5, wantFiles: []string{"app.log"}, wantMissing: []string{"app.log.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "logs", "app.log")
+
+			// Four lines fit in the file before it is rotated
+			f, err := NewRotatingFile(path, 40, tt.maxBackups)
+			if err != nil {
+				t.Fatalf("NewRotatingFile() error = %v", err)
+			}
+			for i := 0; i < tt.writes; i++ {
+				if _, err := f.Write([]byte(line)); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := f.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			for _, name := range tt.wantFiles {
+				info, err := os.Stat(filepath.Join(dir, "logs", name))
+				if err != nil {
+					t.Errorf("%s: %v", name, err)
+				} else if info.Size() > 40 {
+					t.Errorf("%s is %d bytes, over the 40 byte limit", name, info.Size())
+				}
+			}
+			for _, name := range tt.wantMissing {
+				if _, err := os.Stat(filepath.Join(dir, "logs", name)); !os.IsNotExist(err) {
+					t.Errorf("%s exists, want it missing", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRotatingFileClosed(t *testing.T) {
+	f, err := NewRotatingFile(filepath.Join(t.TempDir(), "app.log"), 40, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := f.Write([]byte("late\n")); err != os.ErrClosed {
+		t.Errorf("Write() after Close error = %v, want %v", err, os.ErrClosed)
+	}
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	tests := []struct {
+		name    string
+		observe func(r *Registry)
+		want    string
+	}{
+		{
+			name: "counter",
+			observe: func(r *Registry) {
+				c := r.NewCounter("orders_created_total", "Orders created.")
+				c.Inc()
+				c.Inc()
+			},
+			want: "# HELP orders_created_total Orders created.\n# TYPE orders_created_total counter\norders_created_total 2\n",
+		},
+		{
+			name: "histogram buckets are cumulative and sorted",
+			observe: func(r *Registry) {
+				h := r.NewHistogram("latency_seconds", "Latency.", []float64{1, 0.1})
+				h.Observe(0.05)
+				h.Observe(0.5)
+				h.Observe(3)
+			},
+			want: "# HELP latency_seconds Latency.\n# TYPE latency_seconds histogram\n" +
+				"latency_seconds_bucket{le=\"0.1\"} 1\nlatency_seconds_bucket{le=\"1\"} 2\nlatency_seconds_bucket{le=\"+Inf\"} 3\n" +
+				"latency_seconds_sum 3.55\nlatency_seconds_count 3\n",
+		},
+		{
+			name: "metrics in registration order",
+			observe: func(r *Registry) {
+				r.NewCounter("b_total", "B.")
+				r.NewCounter("a_total", "A.")
+			},
+			want: "# HELP b_total B.\n# TYPE b_total counter\nb_total 0\n# HELP a_total A.\n# TYPE a_total counter\na_total 0\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			tt.observe(r)
+
+			var b strings.Builder
+			if err := r.WriteText(&b); err != nil {
+				t.Fatalf("WriteText() error = %v", err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("WriteText() =\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	orders, server := NewRegistry(), NewRegistry()
+	orders.NewCounter("orders_created_total", "Orders created.").Inc()
+	server.NewCounter("http_requests_total", "Requests served.")
+
+	w := httptest.NewRecorder()
+	Handler(orders, server).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", got)
+	}
+	body := w.Body.String()
+	first, second := strings.Index(body, "orders_created_total 1\n"), strings.Index(body, "http_requests_total 0\n")
+	if first < 0 || second < first {
+		t.Errorf("GET /metrics does not list the registries in order:\n%s", body)
+	}
+}